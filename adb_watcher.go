@@ -0,0 +1,244 @@
+package maa
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+)
+
+// AdbDeviceEventType identifies the kind of change reported by WatchAdbDevices.
+type AdbDeviceEventType int
+
+const (
+	AdbDeviceEventUnknown AdbDeviceEventType = iota
+	// AdbDeviceAttached reports a device that appeared.
+	AdbDeviceAttached
+	// AdbDeviceDetached reports a device that disappeared.
+	AdbDeviceDetached
+	// AdbDeviceChanged reports a device whose name, methods or config changed.
+	AdbDeviceChanged
+	// AdbDeviceScanFailed reports a failed scan. Tracked devices are kept as they were.
+	AdbDeviceScanFailed
+)
+
+// String returns the human-readable representation of the AdbDeviceEventType.
+func (t AdbDeviceEventType) String() string {
+	switch t {
+	case AdbDeviceAttached:
+		return "attached"
+	case AdbDeviceDetached:
+		return "detached"
+	case AdbDeviceChanged:
+		return "changed"
+	case AdbDeviceScanFailed:
+		return "scan_failed"
+	default:
+		return "unknown"
+	}
+}
+
+// AdbDeviceEvent describes a change between successive ADB device scans.
+type AdbDeviceEvent struct {
+	Type AdbDeviceEventType
+	// Device is the current device, or the last known device for AdbDeviceDetached.
+	Device *AdbDevice
+	// Previous is the device before the change for AdbDeviceChanged.
+	Previous *AdbDevice
+	// Err is the scan error for AdbDeviceScanFailed.
+	Err error
+}
+
+// AdbDeviceScanner scans for ADB devices.
+// It allows WatchAdbDevices to be driven by something other than FindAdbDevices.
+type AdbDeviceScanner interface {
+	ScanAdbDevices() ([]*AdbDevice, error)
+}
+
+// AdbDeviceScannerFunc adapts a function to AdbDeviceScanner.
+type AdbDeviceScannerFunc func() ([]*AdbDevice, error)
+
+// ScanAdbDevices implements AdbDeviceScanner.
+func (f AdbDeviceScannerFunc) ScanAdbDevices() ([]*AdbDevice, error) {
+	return f()
+}
+
+var ErrInvalidInterval = errors.New("interval must be positive")
+
+type adbWatchConfig struct {
+	scanner  AdbDeviceScanner
+	debounce int
+}
+
+// AdbWatchOption configures WatchAdbDevices.
+type AdbWatchOption func(*adbWatchConfig)
+
+// WithAdbWatchScanner sets the scanner used by WatchAdbDevices.
+// Defaults to FindAdbDevices.
+func WithAdbWatchScanner(scanner AdbDeviceScanner) AdbWatchOption {
+	return func(cfg *adbWatchConfig) {
+		cfg.scanner = scanner
+	}
+}
+
+// WithAdbWatchSpecifiedAdb makes the default scanner search with the given adb path.
+// It is ignored when WithAdbWatchScanner is also provided.
+func WithAdbWatchSpecifiedAdb(adbPath string) AdbWatchOption {
+	return func(cfg *adbWatchConfig) {
+		if cfg.scanner == nil {
+			cfg.scanner = AdbDeviceScannerFunc(func() ([]*AdbDevice, error) {
+				return FindAdbDevices(adbPath)
+			})
+		}
+	}
+}
+
+// WithAdbWatchDebounce sets how many consecutive scans a device must be present
+// (or absent) before it is reported as attached (or detached).
+// Devices found by the first scan are reported immediately. Defaults to 2.
+func WithAdbWatchDebounce(scans int) AdbWatchOption {
+	return func(cfg *adbWatchConfig) {
+		cfg.debounce = scans
+	}
+}
+
+// WatchAdbDevices scans for ADB devices every interval and reports the differences
+// between successive scans on the returned channel. Devices are identified by
+// AdbPath and Address. The channel is closed once ctx is done.
+func WatchAdbDevices(ctx context.Context, interval time.Duration, opts ...AdbWatchOption) (<-chan AdbDeviceEvent, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	cfg := adbWatchConfig{debounce: 2}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if cfg.scanner == nil {
+		cfg.scanner = AdbDeviceScannerFunc(func() ([]*AdbDevice, error) {
+			return FindAdbDevices()
+		})
+	}
+
+	events := make(chan AdbDeviceEvent)
+	tracker := newAdbDeviceTracker(cfg.debounce)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			devices, err := cfg.scanner.ScanAdbDevices()
+			var batch []AdbDeviceEvent
+			if err != nil {
+				batch = []AdbDeviceEvent{{Type: AdbDeviceScanFailed, Err: err}}
+			} else {
+				batch = tracker.update(devices)
+			}
+			for _, event := range batch {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func adbDeviceKey(d *AdbDevice) string {
+	return d.AdbPath + "\x00" + d.Address
+}
+
+func adbDeviceEqual(a, b *AdbDevice) bool {
+	return a.Name == b.Name &&
+		a.ScreencapMethod == b.ScreencapMethod &&
+		a.InputMethod == b.InputMethod &&
+		a.Config == b.Config
+}
+
+// adbDeviceTracker diffs successive scans with attach/detach debouncing.
+type adbDeviceTracker struct {
+	debounce int
+	primed   bool
+	known    map[string]*AdbDevice
+	present  map[string]int // consecutive sightings of unknown devices
+	absent   map[string]int // consecutive misses of known devices
+}
+
+func newAdbDeviceTracker(debounce int) *adbDeviceTracker {
+	if debounce < 1 {
+		debounce = 1
+	}
+	return &adbDeviceTracker{
+		debounce: debounce,
+		known:    make(map[string]*AdbDevice),
+		present:  make(map[string]int),
+		absent:   make(map[string]int),
+	}
+}
+
+// update consumes a scan result and returns the resulting events in a deterministic order.
+func (t *adbDeviceTracker) update(devices []*AdbDevice) []AdbDeviceEvent {
+	seen := make(map[string]*AdbDevice, len(devices))
+	for _, d := range devices {
+		if d != nil {
+			seen[adbDeviceKey(d)] = d
+		}
+	}
+
+	var events []AdbDeviceEvent
+	for _, key := range slices.Sorted(maps.Keys(seen)) {
+		d := seen[key]
+		delete(t.absent, key)
+
+		if prev, ok := t.known[key]; ok {
+			if !adbDeviceEqual(prev, d) {
+				t.known[key] = d
+				events = append(events, AdbDeviceEvent{Type: AdbDeviceChanged, Device: d, Previous: prev})
+			}
+			continue
+		}
+
+		t.present[key]++
+		if !t.primed || t.present[key] >= t.debounce {
+			delete(t.present, key)
+			t.known[key] = d
+			events = append(events, AdbDeviceEvent{Type: AdbDeviceAttached, Device: d})
+		}
+	}
+
+	for key := range t.present {
+		if _, ok := seen[key]; !ok {
+			delete(t.present, key)
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(t.known)) {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		t.absent[key]++
+		if t.absent[key] >= t.debounce {
+			events = append(events, AdbDeviceEvent{Type: AdbDeviceDetached, Device: t.known[key]})
+			delete(t.known, key)
+			delete(t.absent, key)
+		}
+	}
+
+	t.primed = true
+	return events
+}
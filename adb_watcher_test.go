@@ -0,0 +1,126 @@
+package maa
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func adbDev(address, config string) *AdbDevice {
+	return &AdbDevice{Name: "dev", AdbPath: "adb", Address: address, Config: config}
+}
+
+func eventTypes(events []AdbDeviceEvent) []AdbDeviceEventType {
+	types := make([]AdbDeviceEventType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestAdbDeviceTracker_FirstScanIsImmediate(t *testing.T) {
+	tracker := newAdbDeviceTracker(3)
+	events := tracker.update([]*AdbDevice{adbDev("b", ""), adbDev("a", "")})
+	require.Equal(t, []AdbDeviceEventType{AdbDeviceAttached, AdbDeviceAttached}, eventTypes(events))
+	require.Equal(t, "a", events[0].Device.Address)
+	require.Equal(t, "b", events[1].Device.Address)
+}
+
+func TestAdbDeviceTracker_Debounce(t *testing.T) {
+	tracker := newAdbDeviceTracker(2)
+	require.Empty(t, tracker.update(nil))
+
+	// A device must be seen twice in a row before it is attached.
+	require.Empty(t, tracker.update([]*AdbDevice{adbDev("a", "")}))
+	require.Empty(t, tracker.update(nil))
+	require.Empty(t, tracker.update([]*AdbDevice{adbDev("a", "")}))
+	events := tracker.update([]*AdbDevice{adbDev("a", "")})
+	require.Equal(t, []AdbDeviceEventType{AdbDeviceAttached}, eventTypes(events))
+
+	// A single missed scan does not detach it.
+	require.Empty(t, tracker.update(nil))
+	require.Empty(t, tracker.update([]*AdbDevice{adbDev("a", "")}))
+	require.Empty(t, tracker.update(nil))
+	events = tracker.update(nil)
+	require.Equal(t, []AdbDeviceEventType{AdbDeviceDetached}, eventTypes(events))
+	require.Equal(t, "a", events[0].Device.Address)
+}
+
+func TestAdbDeviceTracker_Changed(t *testing.T) {
+	tracker := newAdbDeviceTracker(1)
+	tracker.update([]*AdbDevice{adbDev("a", "{}")})
+
+	events := tracker.update([]*AdbDevice{adbDev("a", `{"extras":{}}`)})
+	require.Equal(t, []AdbDeviceEventType{AdbDeviceChanged}, eventTypes(events))
+	require.Equal(t, "{}", events[0].Previous.Config)
+	require.Equal(t, `{"extras":{}}`, events[0].Device.Config)
+
+	require.Empty(t, tracker.update([]*AdbDevice{adbDev("a", `{"extras":{}}`)}))
+}
+
+func TestAdbDeviceTracker_KeyIncludesAdbPath(t *testing.T) {
+	tracker := newAdbDeviceTracker(1)
+	other := adbDev("a", "")
+	other.AdbPath = "other/adb"
+	events := tracker.update([]*AdbDevice{adbDev("a", ""), other})
+	require.Len(t, events, 2)
+}
+
+type scriptedAdbScanner struct {
+	mu    sync.Mutex
+	scans [][]*AdbDevice
+	errAt int
+}
+
+func (s *scriptedAdbScanner) ScanAdbDevices() ([]*AdbDevice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.errAt == 0 {
+		s.errAt = -1
+		return nil, errors.New("adb server down")
+	}
+	s.errAt--
+	if len(s.scans) == 0 {
+		return nil, nil
+	}
+	scan := s.scans[0]
+	if len(s.scans) > 1 {
+		s.scans = s.scans[1:]
+	}
+	return scan, nil
+}
+
+func TestWatchAdbDevices(t *testing.T) {
+	_, err := WatchAdbDevices(context.Background(), 0)
+	require.ErrorIs(t, err, ErrInvalidInterval)
+
+	scanner := &scriptedAdbScanner{
+		scans: [][]*AdbDevice{
+			{adbDev("a", "")},
+			{adbDev("a", "")},
+			{},
+		},
+		errAt: 1,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := WatchAdbDevices(ctx, time.Millisecond,
+		WithAdbWatchScanner(scanner),
+		WithAdbWatchDebounce(1),
+	)
+	require.NoError(t, err)
+
+	var got []AdbDeviceEventType
+	for e := range events {
+		got = append(got, e.Type)
+		if e.Type == AdbDeviceDetached {
+			cancel()
+		}
+	}
+	require.Equal(t, []AdbDeviceEventType{AdbDeviceAttached, AdbDeviceScanFailed, AdbDeviceDetached}, got)
+}
@@ -0,0 +1,268 @@
+package maa
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// BundleFrameworkRange bounds the MaaFramework versions a bundle supports.
+// Both bounds are inclusive and optional.
+type BundleFrameworkRange struct {
+	Min string `json:"min,omitempty"`
+	Max string `json:"max,omitempty"`
+}
+
+// BundleRequirements lists what a bundle needs besides its own pipeline and images.
+type BundleRequirements struct {
+	// CustomRecognitions must be registered on the resource.
+	CustomRecognitions []string `json:"custom_recognitions,omitempty"`
+	// CustomActions must be registered on the resource.
+	CustomActions []string `json:"custom_actions,omitempty"`
+	// OcrModels are directories, relative to the bundle root, that must hold
+	// det.onnx, rec.onnx and keys.txt, e.g. "model/ocr".
+	OcrModels []string `json:"ocr_models,omitempty"`
+	// Models are NN model files relative to the bundle root,
+	// e.g. "model/classify/digits.onnx".
+	Models []string `json:"models,omitempty"`
+}
+
+// BundleProblemKind classifies a BundleProblem.
+type BundleProblemKind int
+
+const (
+	BundleProblemUnknown BundleProblemKind = iota
+	// BundleProblemFrameworkTooOld means Version() is below Framework.Min.
+	BundleProblemFrameworkTooOld
+	// BundleProblemFrameworkTooNew means Version() is above Framework.Max.
+	BundleProblemFrameworkTooNew
+	// BundleProblemInvalidVersion means a version in the manifest, or Version() itself, cannot be parsed.
+	BundleProblemInvalidVersion
+	// BundleProblemMissingCustomRecognition means a required custom recognition is not registered.
+	BundleProblemMissingCustomRecognition
+	// BundleProblemMissingCustomAction means a required custom action is not registered.
+	BundleProblemMissingCustomAction
+	// BundleProblemMissingOcrModel means a required OCR model file is missing.
+	BundleProblemMissingOcrModel
+	// BundleProblemMissingModel means a required NN model file is missing.
+	BundleProblemMissingModel
+)
+
+// String returns the human-readable representation of the BundleProblemKind.
+func (k BundleProblemKind) String() string {
+	switch k {
+	case BundleProblemFrameworkTooOld:
+		return "framework_too_old"
+	case BundleProblemFrameworkTooNew:
+		return "framework_too_new"
+	case BundleProblemInvalidVersion:
+		return "invalid_version"
+	case BundleProblemMissingCustomRecognition:
+		return "missing_custom_recognition"
+	case BundleProblemMissingCustomAction:
+		return "missing_custom_action"
+	case BundleProblemMissingOcrModel:
+		return "missing_ocr_model"
+	case BundleProblemMissingModel:
+		return "missing_model"
+	default:
+		return "unknown"
+	}
+}
+
+// BundleProblem is a single unmet requirement of a bundle.
+type BundleProblem struct {
+	Kind BundleProblemKind
+	// Name is the version, custom component or file the problem is about.
+	Name   string
+	Detail string
+}
+
+func (p BundleProblem) String() string {
+	if p.Detail == "" {
+		return p.Kind.String() + " " + p.Name
+	}
+	return p.Kind.String() + " " + p.Name + " (" + p.Detail + ")"
+}
+
+var ErrBundleIncompatible = errors.New("bundle is incompatible")
+
+// BundleCompatibilityError lists every unmet requirement of a bundle.
+// It matches ErrBundleIncompatible with errors.Is.
+type BundleCompatibilityError struct {
+	Path     string
+	Problems []BundleProblem
+}
+
+func (e *BundleCompatibilityError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		problems[i] = p.String()
+	}
+	return fmt.Sprintf("bundle %s is incompatible: %s", e.Path, strings.Join(problems, "; "))
+}
+
+func (e *BundleCompatibilityError) Unwrap() error {
+	return ErrBundleIncompatible
+}
+
+// CheckBundle checks the manifest of the bundle at path against the running
+// framework version, the custom components registered on res and the model
+// files on disk. A bundle without a manifest passes.
+// Unmet requirements are reported as a *BundleCompatibilityError.
+func CheckBundle(res *Resource, path string) (*BundleManifest, error) {
+	manifest, err := ReadBundleManifest(path)
+	if errors.Is(err, ErrBundleManifestMissing) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return manifest, manifest.Check(res, path)
+}
+
+// Check checks the manifest of the bundle at path, see CheckBundle.
+func (m *BundleManifest) Check(res *Resource, path string) error {
+	if res == nil {
+		return ErrInvalidResource
+	}
+	recognitions, err := res.GetCustomRecognitionList()
+	if err != nil {
+		return err
+	}
+	actions, err := res.GetCustomActionList()
+	if err != nil {
+		return err
+	}
+	problems := m.check(Version(), recognitions, actions, path)
+	if len(problems) == 0 {
+		return nil
+	}
+	return &BundleCompatibilityError{Path: path, Problems: problems}
+}
+
+// PostCheckedBundle checks the bundle at path with CheckBundle and, only if it
+// passes, loads it like PostBundle.
+func (r *Resource) PostCheckedBundle(path string) (*Job, error) {
+	if _, err := CheckBundle(r, path); err != nil {
+		return nil, err
+	}
+	return r.PostBundle(path), nil
+}
+
+var ocrModelFiles = []string{"det.onnx", "rec.onnx", "keys.txt"}
+
+func (m *BundleManifest) check(framework string, recognitions, actions []string, root string) []BundleProblem {
+	var problems []BundleProblem
+
+	// The running version is only needed for the bounds, so a bundle without
+	// bounds also passes on development builds with an unparseable version.
+	if m.Framework.Min != "" || m.Framework.Max != "" {
+		current, err := parseFrameworkVersion(framework)
+		if err != nil {
+			problems = append(problems, BundleProblem{Kind: BundleProblemInvalidVersion, Name: framework, Detail: err.Error()})
+		} else {
+			bound := func(version string, tooFar BundleProblemKind, outside func(int) bool) {
+				if version == "" {
+					return
+				}
+				v, err := parseFrameworkVersion(version)
+				if err != nil {
+					problems = append(problems, BundleProblem{Kind: BundleProblemInvalidVersion, Name: version, Detail: err.Error()})
+					return
+				}
+				if outside(current.compare(v)) {
+					problems = append(problems, BundleProblem{Kind: tooFar, Name: version, Detail: "running " + framework})
+				}
+			}
+			bound(m.Framework.Min, BundleProblemFrameworkTooOld, func(c int) bool { return c < 0 })
+			bound(m.Framework.Max, BundleProblemFrameworkTooNew, func(c int) bool { return c > 0 })
+		}
+	}
+
+	for _, name := range m.Requires.CustomRecognitions {
+		if !slices.Contains(recognitions, name) {
+			problems = append(problems, BundleProblem{Kind: BundleProblemMissingCustomRecognition, Name: name})
+		}
+	}
+	for _, name := range m.Requires.CustomActions {
+		if !slices.Contains(actions, name) {
+			problems = append(problems, BundleProblem{Kind: BundleProblemMissingCustomAction, Name: name})
+		}
+	}
+	for _, dir := range m.Requires.OcrModels {
+		for _, file := range ocrModelFiles {
+			name := filepath.ToSlash(filepath.Join(dir, file))
+			if !bundleFileExists(root, name) {
+				problems = append(problems, BundleProblem{Kind: BundleProblemMissingOcrModel, Name: name})
+			}
+		}
+	}
+	for _, name := range m.Requires.Models {
+		if !bundleFileExists(root, name) {
+			problems = append(problems, BundleProblem{Kind: BundleProblemMissingModel, Name: name})
+		}
+	}
+	return problems
+}
+
+func bundleFileExists(root, name string) bool {
+	info, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// frameworkVersion is a parsed "v1.2.3-pre" version string.
+type frameworkVersion struct {
+	parts      [3]int
+	prerelease string
+}
+
+func parseFrameworkVersion(s string) (frameworkVersion, error) {
+	var v frameworkVersion
+	core := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(core, '+'); i >= 0 {
+		core = core[:i]
+	}
+	if i := strings.IndexByte(core, '-'); i >= 0 {
+		core, v.prerelease = core[:i], core[i+1:]
+	}
+	fields := strings.Split(core, ".")
+	if len(fields) > 3 || fields[0] == "" {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		v.parts[i] = n
+	}
+	return v, nil
+}
+
+// compare orders versions like semver, except that pre-release identifiers are
+// compared as plain strings. A pre-release sorts before its release.
+func (v frameworkVersion) compare(o frameworkVersion) int {
+	for i := range v.parts {
+		if v.parts[i] != o.parts[i] {
+			if v.parts[i] < o.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.prerelease == o.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case o.prerelease == "":
+		return -1
+	default:
+		return strings.Compare(v.prerelease, o.prerelease)
+	}
+}
@@ -0,0 +1,101 @@
+package maa
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrameworkVersion(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"v4.5.0", "4.5.0", 0},
+		{"v4.5.0", "v4.4.9", 1},
+		{"v4.5", "v4.5.1", -1},
+		{"v4.5.0-beta.1", "v4.5.0", -1},
+		{"v4.5.0-beta.2", "v4.5.0-beta.1", 1},
+		{"v10.0.0", "v9.9.9", 1},
+		{"v4.5.0+build", "v4.5.0", 0},
+	}
+	for _, c := range cases {
+		a, err := parseFrameworkVersion(c.a)
+		require.NoError(t, err)
+		b, err := parseFrameworkVersion(c.b)
+		require.NoError(t, err)
+		require.Equal(t, c.want, a.compare(b), "%s vs %s", c.a, c.b)
+	}
+
+	for _, s := range []string{"", "v", "x.y", "1.2.3.4", "1..2"} {
+		_, err := parseFrameworkVersion(s)
+		require.Error(t, err, s)
+	}
+}
+
+func problemKinds(problems []BundleProblem) []BundleProblemKind {
+	kinds := make([]BundleProblemKind, len(problems))
+	for i, p := range problems {
+		kinds[i] = p.Kind
+	}
+	return kinds
+}
+
+func TestBundleManifest_Check(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "model", "ocr"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "model", "ocr", "det.onnx"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "model", "ocr", "rec.onnx"), nil, 0o644))
+
+	m := &BundleManifest{
+		Version:   "1.0.0",
+		Framework: BundleFrameworkRange{Min: "v4.4.0", Max: "v4.9.0"},
+		Requires: BundleRequirements{
+			CustomRecognitions: []string{"Digits"},
+			CustomActions:      []string{"Swipe", "Tap"},
+			OcrModels:          []string{"model/ocr"},
+			Models:             []string{"model/classify/a.onnx"},
+		},
+	}
+
+	require.Empty(t, (&BundleManifest{}).check("v4.5.0", nil, nil, root))
+	require.Empty(t, (&BundleManifest{}).check("dev", nil, nil, root))
+
+	problems := m.check("v4.5.0", []string{"Digits"}, []string{"Tap"}, root)
+	require.Equal(t, []BundleProblemKind{
+		BundleProblemMissingCustomAction,
+		BundleProblemMissingOcrModel,
+		BundleProblemMissingModel,
+	}, problemKinds(problems))
+	require.Equal(t, "Swipe", problems[0].Name)
+	require.Equal(t, "model/ocr/keys.txt", problems[1].Name)
+
+	m.Requires = BundleRequirements{}
+	require.Equal(t, []BundleProblemKind{BundleProblemFrameworkTooOld}, problemKinds(m.check("v4.4.0-beta.1", nil, nil, root)))
+	require.Equal(t, []BundleProblemKind{BundleProblemFrameworkTooNew}, problemKinds(m.check("v5.0.0", nil, nil, root)))
+	require.Equal(t, []BundleProblemKind{BundleProblemInvalidVersion}, problemKinds(m.check("dev", nil, nil, root)))
+
+	err := &BundleCompatibilityError{Path: root, Problems: m.check("v5.0.0", nil, nil, root)}
+	require.True(t, errors.Is(err, ErrBundleIncompatible))
+	require.Contains(t, err.Error(), "framework_too_new v4.9.0")
+}
+
+func TestBundleManifest_MetadataIsSigned(t *testing.T) {
+	dir, pub, priv := writeSignedTestBundle(t)
+
+	manifest, err := ReadBundleManifest(dir)
+	require.NoError(t, err)
+	manifest.Framework.Min = "v4.0.0"
+	manifest.Requires.CustomActions = []string{"Tap"}
+	require.NoError(t, WriteBundleManifest(dir, manifest))
+	require.NoError(t, SignBundle(dir, priv))
+
+	v, err := VerifyBundle(dir, []ed25519.PublicKey{pub})
+	require.NoError(t, err)
+	require.Equal(t, "v4.0.0", v.Manifest.Framework.Min)
+	require.Equal(t, []string{"Tap"}, v.Manifest.Requires.CustomActions)
+}
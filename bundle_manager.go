@@ -0,0 +1,447 @@
+package maa
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BundleLayerResult reports how a single bundle layer was loaded.
+type BundleLayerResult struct {
+	// Index is the position of the layer, 0 being the base bundle.
+	Index int
+	Path  string
+	// Status is the final status of the PostBundle job.
+	Status Status
+	// Hash is the resource hash reported by the Resource.Loading event, if any.
+	Hash     string
+	Duration time.Duration
+	Err      error
+}
+
+var (
+	ErrBundleManagerNoLayers = errors.New("bundle manager needs at least one layer")
+	ErrBundleLayerLoad       = errors.New("failed to load bundle layer")
+
+	errBundleReloadPostponed = errors.New("bundle reload postponed while the tasker is running")
+)
+
+type bundleManagerConfig struct {
+	tasker  *Tasker
+	onLayer func(BundleLayerResult)
+	onError func(error)
+}
+
+// BundleManagerOption configures a BundleManager.
+type BundleManagerOption func(*bundleManagerConfig)
+
+// WithBundleManagerTasker sets the tasker whose tasks must not be interrupted by a reload.
+// Watch postpones a pending reload until the tasker is no longer running.
+func WithBundleManagerTasker(tasker *Tasker) BundleManagerOption {
+	return func(cfg *bundleManagerConfig) {
+		cfg.tasker = tasker
+	}
+}
+
+// WithBundleLayerCallback sets a callback invoked after each layer is loaded.
+func WithBundleLayerCallback(fn func(BundleLayerResult)) BundleManagerOption {
+	return func(cfg *bundleManagerConfig) {
+		cfg.onLayer = fn
+	}
+}
+
+// WithBundleWatchErrorCallback sets a callback invoked when Watch fails to scan or reload.
+func WithBundleWatchErrorCallback(fn func(error)) BundleManagerOption {
+	return func(cfg *bundleManagerConfig) {
+		cfg.onError = fn
+	}
+}
+
+// BundleManager loads an ordered list of bundles into a Resource, later layers
+// overriding earlier ones, and reloads them when their files change.
+type BundleManager struct {
+	res    *Resource
+	layers []string
+	cfg    bundleManagerConfig
+
+	mu        sync.Mutex
+	snapshots []bundleSnapshot
+	results   []BundleLayerResult
+	origin    map[string]int
+}
+
+// NewBundleManager creates a bundle manager for res.
+// paths are loaded in order: paths[0] is the base bundle and every following
+// path overrides the nodes and images of the previous ones.
+func NewBundleManager(res *Resource, paths []string, opts ...BundleManagerOption) (*BundleManager, error) {
+	if res == nil {
+		return nil, ErrInvalidResource
+	}
+	if len(paths) == 0 {
+		return nil, ErrBundleManagerNoLayers
+	}
+	m := &BundleManager{
+		res:    res,
+		layers: append([]string(nil), paths...),
+		origin: make(map[string]int),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&m.cfg)
+		}
+	}
+	return m, nil
+}
+
+// Layers returns the bundle paths in load order.
+func (m *BundleManager) Layers() []string {
+	return append([]string(nil), m.layers...)
+}
+
+// Load clears the resource and loads every layer in order.
+// The layers are first loaded into a scratch resource: if one fails, the
+// resource is left as it was and the error wraps ErrBundleLayerLoad.
+func (m *BundleManager) Load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.load(false)
+}
+
+// Reload reloads every layer if any of their files changed since the last load.
+// It reports whether a reload happened. When a tasker is configured and is
+// running, the reload is postponed and Reload returns false.
+func (m *BundleManager) Reload() (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changed, err := m.changed()
+	if err != nil || !changed {
+		return false, err
+	}
+	err = m.load(true)
+	if errors.Is(err, errBundleReloadPostponed) {
+		return false, nil
+	}
+	return true, err
+}
+
+// Changed reports whether any layer file was added, removed or modified since
+// the last load, whether it succeeded or not.
+// Files whose modification time changed but whose content did not are not reported.
+func (m *BundleManager) Changed() (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.changed()
+}
+
+// Watch polls the layers every interval until ctx is done and reloads them on change.
+// When a tasker is configured, a reload is postponed while it is running so the
+// resource is only rebuilt between tasks. Watch cannot hold back the tasks
+// posted by other goroutines: one posted while a reload is rebuilding the
+// resource sees it partly loaded, so callers that post tasks concurrently must
+// not post them while Reload runs.
+// A layer that fails to load is retried once its files change again.
+func (m *BundleManager) Watch(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return ErrInvalidInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if m.cfg.tasker != nil && m.cfg.tasker.Running() {
+			continue
+		}
+		if _, err := m.Reload(); err != nil && m.cfg.onError != nil {
+			m.cfg.onError(err)
+		}
+	}
+}
+
+// Results returns the per-layer results of the last load, or of the scratch
+// load that failed.
+func (m *BundleManager) Results() []BundleLayerResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]BundleLayerResult(nil), m.results...)
+}
+
+// NodeLayer returns the index and path of the layer that last defined or overrode the node.
+func (m *BundleManager) NodeLayer(name string) (int, string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	index, ok := m.origin[name]
+	if !ok {
+		return -1, "", false
+	}
+	return index, m.layers[index], true
+}
+
+// NodeLayers returns the layer index of every loaded node.
+func (m *BundleManager) NodeLayers() map[string]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]int, len(m.origin))
+	for k, v := range m.origin {
+		out[k] = v
+	}
+	return out
+}
+
+// load checks that the layers load into a scratch resource, with the inference
+// settings of res, before it clears res, so a broken layer leaves res as it
+// was. The snapshots of a failed load are kept too, so an unchanged broken
+// bundle is not reloaded on every poll.
+// When postpone is set and the tasker is running, res is left untouched and
+// errBundleReloadPostponed is returned.
+func (m *BundleManager) load(postpone bool) error {
+	snapshots := make([]bundleSnapshot, len(m.layers))
+	for i, path := range m.layers {
+		snapshot, err := snapshotBundle(path)
+		if err != nil {
+			return err
+		}
+		snapshots[i] = snapshot
+	}
+
+	scratch, err := m.res.newLike()
+	if err != nil {
+		return err
+	}
+	results, _, err := m.loadLayers(scratch, m.cfg.onLayer)
+	scratch.Destroy()
+	if err != nil {
+		m.results = results
+		m.snapshots = snapshots
+		return err
+	}
+
+	// Checked as late as possible: tasks posted from now on see res reloading.
+	if postpone && m.cfg.tasker != nil && m.cfg.tasker.Running() {
+		return errBundleReloadPostponed
+	}
+	if err := m.res.Clear(); err != nil {
+		return err
+	}
+	results, origin, err := m.loadLayers(m.res, nil)
+	m.results = results
+	m.snapshots = snapshots
+	if err == nil {
+		m.origin = origin
+	}
+	return err
+}
+
+// loadLayers loads every layer into res in order and attributes the nodes to
+// the layers. It stops at the first layer that fails.
+func (m *BundleManager) loadLayers(res *Resource, onLayer func(BundleLayerResult)) ([]BundleLayerResult, map[string]int, error) {
+	var (
+		hashMu sync.Mutex
+		hashes = make(map[string]string)
+	)
+	sinkID := res.OnResourceLoading(func(status EventStatus, detail ResourceLoadingDetail) {
+		if status != EventStatusSucceeded {
+			return
+		}
+		hashMu.Lock()
+		hashes[filepath.Clean(detail.Path)] = detail.Hash
+		hashMu.Unlock()
+	})
+	defer res.RemoveSink(sinkID)
+
+	results := make([]BundleLayerResult, 0, len(m.layers))
+	origin := make(map[string]int)
+	nodes := make(map[string]string)
+
+	for i, path := range m.layers {
+		start := time.Now()
+		status := res.PostBundle(path).Wait().Status()
+		result := BundleLayerResult{
+			Index:    i,
+			Path:     path,
+			Status:   status,
+			Duration: time.Since(start),
+		}
+		hashMu.Lock()
+		result.Hash = hashes[filepath.Clean(path)]
+		hashMu.Unlock()
+
+		if !status.Success() {
+			result.Err = fmt.Errorf("%w %d (%s): %s", ErrBundleLayerLoad, i, path, status)
+		} else if after, err := nodeSnapshot(res); err != nil {
+			result.Err = err
+		} else {
+			attributeNodes(nodes, after, i, origin)
+			nodes = after
+		}
+
+		results = append(results, result)
+		if onLayer != nil {
+			onLayer(result)
+		}
+		if result.Err != nil {
+			return results, origin, result.Err
+		}
+	}
+	return results, origin, nil
+}
+
+func nodeSnapshot(res *Resource) (map[string]string, error) {
+	names, err := res.GetNodeList()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]string, len(names))
+	for _, name := range names {
+		raw, err := res.GetNodeJSON(name)
+		if err != nil {
+			return nil, err
+		}
+		nodes[name] = raw
+	}
+	return nodes, nil
+}
+
+func (m *BundleManager) changed() (bool, error) {
+	if len(m.snapshots) != len(m.layers) {
+		return true, nil
+	}
+	for i, path := range m.layers {
+		changed, err := m.snapshots[i].changed(path)
+		if err != nil || changed {
+			return changed, err
+		}
+	}
+	return false, nil
+}
+
+// attributeNodes records layer as the origin of every node that is new or
+// whose definition changed between before and after.
+func attributeNodes(before, after map[string]string, layer int, origin map[string]int) {
+	for name, raw := range after {
+		if prev, ok := before[name]; !ok || prev != raw {
+			origin[name] = layer
+		}
+	}
+	for name := range origin {
+		if _, ok := after[name]; !ok {
+			delete(origin, name)
+		}
+	}
+}
+
+type bundleFileStamp struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+// bundleSnapshot records every regular file of a bundle by its slash-separated relative path.
+type bundleSnapshot map[string]bundleFileStamp
+
+func snapshotBundle(root string) (bundleSnapshot, error) {
+	snapshot := make(bundleSnapshot)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		snapshot[filepath.ToSlash(rel)] = bundleFileStamp{
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			hash:    hash,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan bundle %s: %w", root, err)
+	}
+	return snapshot, nil
+}
+
+// changed compares the snapshot against the files currently under root.
+// Only files whose size or modification time changed are hashed again.
+func (s bundleSnapshot) changed(root string) (bool, error) {
+	seen := 0
+	changed := false
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		stamp, ok := s[filepath.ToSlash(rel)]
+		if !ok {
+			changed = true
+			return fs.SkipAll
+		}
+		seen++
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() == stamp.size && info.ModTime().Equal(stamp.modTime) {
+			return nil
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		if hash != stamp.hash {
+			changed = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to scan bundle %s: %w", root, err)
+	}
+	return changed || seen != len(s), nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,170 @@
+package maa
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/native"
+	"github.com/stretchr/testify/require"
+)
+
+func writeBundleFile(t *testing.T, root, rel, content string) string {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestBundleSnapshot_Changed(t *testing.T) {
+	root := t.TempDir()
+	pipeline := writeBundleFile(t, root, "pipeline/main.json", `{"A":{}}`)
+	writeBundleFile(t, root, "image/a.png", "png")
+
+	snapshot, err := snapshotBundle(root)
+	require.NoError(t, err)
+	require.Len(t, snapshot, 2)
+	require.Contains(t, snapshot, "pipeline/main.json")
+
+	changed, err := snapshot.changed(root)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// Touching a file without changing its content is not a change.
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(pipeline, later, later))
+	changed, err = snapshot.changed(root)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	writeBundleFile(t, root, "pipeline/main.json", `{"B":{}}`)
+	changed, err = snapshot.changed(root)
+	require.NoError(t, err)
+	require.True(t, changed)
+}
+
+func TestBundleSnapshot_AddedAndRemoved(t *testing.T) {
+	root := t.TempDir()
+	writeBundleFile(t, root, "pipeline/main.json", `{}`)
+	extra := writeBundleFile(t, root, "pipeline/extra.json", `{}`)
+
+	snapshot, err := snapshotBundle(root)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(extra))
+	changed, err := snapshot.changed(root)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	writeBundleFile(t, root, "pipeline/extra.json", `{}`)
+	writeBundleFile(t, root, "pipeline/new.json", `{}`)
+	changed, err = snapshot.changed(root)
+	require.NoError(t, err)
+	require.True(t, changed)
+}
+
+func TestSnapshotBundle_MissingPath(t *testing.T) {
+	_, err := snapshotBundle(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
+func TestAttributeNodes(t *testing.T) {
+	origin := make(map[string]int)
+
+	base := map[string]string{"A": "a0", "B": "b0"}
+	attributeNodes(nil, base, 0, origin)
+	require.Equal(t, map[string]int{"A": 0, "B": 0}, origin)
+
+	region := map[string]string{"A": "a0", "B": "b1", "C": "c1"}
+	attributeNodes(base, region, 1, origin)
+	require.Equal(t, map[string]int{"A": 0, "B": 1, "C": 1}, origin)
+
+	event := map[string]string{"A": "a2", "B": "b1", "C": "c1"}
+	attributeNodes(region, event, 2, origin)
+	require.Equal(t, map[string]int{"A": 2, "B": 1, "C": 1}, origin)
+}
+
+func TestNewBundleManager(t *testing.T) {
+	_, err := NewBundleManager(nil, []string{"a"})
+	require.ErrorIs(t, err, ErrInvalidResource)
+
+	_, err = NewBundleManager(&Resource{}, nil)
+	require.ErrorIs(t, err, ErrBundleManagerNoLayers)
+
+	paths := []string{"base", "region"}
+	m, err := NewBundleManager(&Resource{}, paths)
+	require.NoError(t, err)
+	paths[0] = "mutated"
+	require.Equal(t, []string{"base", "region"}, m.Layers())
+
+	_, _, ok := m.NodeLayer("A")
+	require.False(t, ok)
+}
+
+func TestBundleManager_LoadKeepsResourceOnFailure(t *testing.T) {
+	fake := useFakeBackend(t)
+	base, broken := t.TempDir(), t.TempDir()
+	writeBundleFile(t, base, "main.json", `{}`)
+	writeBundleFile(t, broken, "main.json", `{}`)
+	require.NoError(t, fake.AddBundle("bundle", map[string]any{"Start": map[string]any{}}))
+	require.NoError(t, fake.AddBundle(base, map[string]any{"Base": map[string]any{}}))
+
+	res, err := NewResource()
+	require.NoError(t, err)
+	defer res.Destroy()
+	require.True(t, res.PostBundle("bundle").Wait().Success())
+
+	// The fake has no bundle at broken, so its layer fails to load.
+	m, err := NewBundleManager(res, []string{base, broken})
+	require.NoError(t, err)
+	require.ErrorIs(t, m.Load(), ErrBundleLayerLoad)
+	nodes, err := res.GetNodeList()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Start"}, nodes, "the resource is left as it was")
+	require.Len(t, m.Results(), 2)
+
+	reloaded, err := m.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded, "an unchanged broken bundle is not retried")
+
+	require.NoError(t, fake.AddBundle(broken, map[string]any{"Broken": map[string]any{}}))
+	writeBundleFile(t, broken, "main.json", `{"Broken":{}}`)
+	reloaded, err = m.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	nodes, err = res.GetNodeList()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Base", "Broken"}, nodes)
+	index, _, ok := m.NodeLayer("Broken")
+	require.True(t, ok)
+	require.Equal(t, 1, index)
+}
+
+func TestResource_NewLikeKeepsInference(t *testing.T) {
+	fake := useFakeBackend(t)
+	res, err := NewResource()
+	require.NoError(t, err)
+	defer res.Destroy()
+
+	scratch, err := res.newLike()
+	require.NoError(t, err)
+	_, ok := fake.ResourceOption(scratch.handle, native.MaaResOption_InferenceDevice)
+	require.False(t, ok, "nothing is set when the resource uses the defaults")
+	scratch.Destroy()
+
+	require.NoError(t, res.UseDirectml(InferenceDevice1))
+	scratch, err = res.newLike()
+	require.NoError(t, err)
+	defer scratch.Destroy()
+	for key, want := range map[native.MaaResOption]int32{
+		native.MaaResOption_InferenceExecutionProvider: native.MaaInferenceExecutionProvider_DirectML,
+		native.MaaResOption_InferenceDevice:            int32(InferenceDevice1),
+	} {
+		value, ok := fake.ResourceOption(scratch.handle, key)
+		require.True(t, ok)
+		require.Equal(t, want, int32(binary.NativeEndian.Uint32(value)))
+	}
+}
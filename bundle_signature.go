@@ -0,0 +1,330 @@
+package maa
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// BundleManifestFile is the manifest file name at the root of a bundle.
+	BundleManifestFile = "maa_bundle.json"
+	// BundleSignatureFile is the detached ed25519 signature of BundleManifestFile.
+	BundleSignatureFile = "maa_bundle.sig"
+)
+
+var (
+	ErrBundleManifestMissing  = errors.New("bundle manifest not found")
+	ErrBundleUnsigned         = errors.New("bundle signature not found")
+	ErrBundleSignatureInvalid = errors.New("bundle signature does not match any trusted key")
+	ErrBundleNoTrustedKeys    = errors.New("no trusted public keys provided")
+	ErrBundleResourceHash     = errors.New("loaded resource hash does not match the bundle manifest")
+	ErrBundleIrregularFile    = errors.New("bundle contains a symlink or other non-regular file")
+)
+
+// BundleManifest describes the content of a bundle.
+// Files maps every file of the bundle, by slash-separated path relative to the
+// bundle root, to its hex-encoded SHA-256 digest.
+// The metadata fields are checked by CheckBundle and are covered by the signature too.
+type BundleManifest struct {
+	// Version is the version of the bundle itself.
+	Version string `json:"version,omitempty"`
+	// Framework bounds the MaaFramework versions the bundle works with.
+	Framework BundleFrameworkRange `json:"framework,omitzero"`
+	// Requires lists what must be available before the bundle can run.
+	Requires BundleRequirements `json:"requires,omitzero"`
+
+	Files map[string]string `json:"files,omitempty"`
+	// ResourceHash is the Resource.GetHash value observed when the bundle was
+	// loaded at signing time. It is optional.
+	ResourceHash string `json:"resource_hash,omitempty"`
+}
+
+// BundleFileMismatchError lists the files that differ from the bundle manifest.
+type BundleFileMismatchError struct {
+	Missing  []string
+	Modified []string
+	Extra    []string
+}
+
+func (e *BundleFileMismatchError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Modified) > 0 {
+		parts = append(parts, "modified: "+strings.Join(e.Modified, ", "))
+	}
+	if len(e.Extra) > 0 {
+		parts = append(parts, "unlisted: "+strings.Join(e.Extra, ", "))
+	}
+	return "bundle files do not match manifest (" + strings.Join(parts, "; ") + ")"
+}
+
+// BundleVerification is the result of a successful VerifyBundle.
+type BundleVerification struct {
+	Path     string
+	Manifest *BundleManifest
+	// KeyIndex is the index of the public key that verified the signature.
+	KeyIndex int
+	// ManifestDigest is the hex-encoded SHA-256 of the signed manifest bytes.
+	ManifestDigest string
+}
+
+// BuildBundleManifest hashes every file under path, except the manifest and
+// signature files themselves.
+// A symlink or other non-regular entry inside the bundle fails with
+// ErrBundleIrregularFile, since it could point outside the signed content.
+func BuildBundleManifest(path string) (*BundleManifest, error) {
+	root, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash bundle %s: %w", path, err)
+	}
+	files := make(map[string]string)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%w: %s", ErrBundleIrregularFile, rel)
+		}
+		if rel == BundleManifestFile || rel == BundleSignatureFile {
+			return nil
+		}
+		hash, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash bundle %s: %w", path, err)
+	}
+	return &BundleManifest{Files: files}, nil
+}
+
+// ReadBundleManifest reads the manifest at the root of the bundle at path.
+func ReadBundleManifest(path string) (*BundleManifest, error) {
+	data, err := os.ReadFile(filepath.Join(path, BundleManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBundleManifestMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseBundleManifest(data)
+}
+
+func parseBundleManifest(data []byte) (*BundleManifest, error) {
+	var manifest BundleManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// WriteBundleManifest writes manifest to the root of the bundle at path.
+// Any existing signature is removed since it no longer matches.
+func WriteBundleManifest(path string, manifest *BundleManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(path, BundleManifestFile), append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(path, BundleSignatureFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// SignBundle signs the manifest of the bundle at path with key and writes the
+// detached signature next to it. If the bundle has no manifest yet, one is
+// built from the current files first.
+func SignBundle(path string, key ed25519.PrivateKey) error {
+	manifestPath := filepath.Join(path, BundleManifestFile)
+	data, err := os.ReadFile(manifestPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		manifest, buildErr := BuildBundleManifest(path)
+		if buildErr != nil {
+			return buildErr
+		}
+		if err := WriteBundleManifest(path, manifest); err != nil {
+			return err
+		}
+		data, err = os.ReadFile(manifestPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	sig := ed25519.Sign(key, data)
+	encoded := base64.StdEncoding.EncodeToString(sig) + "\n"
+	return os.WriteFile(filepath.Join(path, BundleSignatureFile), []byte(encoded), 0o644)
+}
+
+// VerifyBundle checks that the manifest of the bundle at path is signed by one of
+// pubkeys and that every file matches the manifest. Files that are not listed in
+// the manifest are reported as a mismatch.
+func VerifyBundle(path string, pubkeys []ed25519.PublicKey) (*BundleVerification, error) {
+	if len(pubkeys) == 0 {
+		return nil, ErrBundleNoTrustedKeys
+	}
+
+	data, err := os.ReadFile(filepath.Join(path, BundleManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBundleManifestMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := os.ReadFile(filepath.Join(path, BundleSignatureFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBundleUnsigned
+	}
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBundleSignatureInvalid, err)
+	}
+
+	keyIndex := -1
+	for i, key := range pubkeys {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, data, sig) {
+			keyIndex = i
+			break
+		}
+	}
+	if keyIndex < 0 {
+		return nil, ErrBundleSignatureInvalid
+	}
+
+	manifest, err := parseBundleManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := BuildBundleManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if mismatch := compareBundleFiles(manifest.Files, actual.Files); mismatch != nil {
+		return nil, mismatch
+	}
+
+	digest := sha256.Sum256(data)
+	return &BundleVerification{
+		Path:           path,
+		Manifest:       manifest,
+		KeyIndex:       keyIndex,
+		ManifestDigest: hex.EncodeToString(digest[:]),
+	}, nil
+}
+
+// MatchResource compares the hash of a resource the bundle was loaded into with
+// the hash recorded in the manifest. It returns nil when the manifest records no hash.
+func (v *BundleVerification) MatchResource(res *Resource) error {
+	if v.Manifest.ResourceHash == "" {
+		return nil
+	}
+	hash, err := res.GetHash()
+	if err != nil {
+		return err
+	}
+	if hash != v.Manifest.ResourceHash {
+		return fmt.Errorf("%w: got %s, want %s", ErrBundleResourceHash, hash, v.Manifest.ResourceHash)
+	}
+	return nil
+}
+
+// PostVerifiedBundle verifies the bundle at path with VerifyBundle and, only if
+// it passes, loads it like PostBundle.
+func (r *Resource) PostVerifiedBundle(path string, pubkeys []ed25519.PublicKey) (*Job, *BundleVerification, error) {
+	verification, err := VerifyBundle(path, pubkeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.PostBundle(path), verification, nil
+}
+
+// ParseBundlePublicKey parses a PEM encoded PKIX ed25519 public key.
+func ParseBundlePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not ed25519")
+	}
+	return pub, nil
+}
+
+// ParseBundlePrivateKey parses a PEM encoded PKCS #8 ed25519 private key.
+func ParseBundlePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not ed25519")
+	}
+	return priv, nil
+}
+
+func compareBundleFiles(want, got map[string]string) error {
+	mismatch := &BundleFileMismatchError{}
+	for name, hash := range want {
+		actual, ok := got[name]
+		switch {
+		case !ok:
+			mismatch.Missing = append(mismatch.Missing, name)
+		case !strings.EqualFold(actual, hash):
+			mismatch.Modified = append(mismatch.Modified, name)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			mismatch.Extra = append(mismatch.Extra, name)
+		}
+	}
+	if len(mismatch.Missing)+len(mismatch.Modified)+len(mismatch.Extra) == 0 {
+		return nil
+	}
+	slices.Sort(mismatch.Missing)
+	slices.Sort(mismatch.Modified)
+	slices.Sort(mismatch.Extra)
+	return mismatch
+}
@@ -0,0 +1,133 @@
+package maa
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeSignedTestBundle(t *testing.T) (string, ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "pipeline"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "image"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pipeline", "main.json"), []byte(`{"A":{}}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image", "a.png"), []byte("png"), 0o644))
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, SignBundle(dir, priv))
+	return dir, pub, priv
+}
+
+func TestBundleSignature_RoundTrip(t *testing.T) {
+	dir, pub, _ := writeSignedTestBundle(t)
+
+	manifest, err := ReadBundleManifest(dir)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 2)
+	require.Contains(t, manifest.Files, "pipeline/main.json")
+
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	v, err := VerifyBundle(dir, []ed25519.PublicKey{other, pub})
+	require.NoError(t, err)
+	require.Equal(t, 1, v.KeyIndex)
+	require.Len(t, v.ManifestDigest, 64)
+}
+
+func TestBundleSignature_Rejects(t *testing.T) {
+	dir, pub, _ := writeSignedTestBundle(t)
+	keys := []ed25519.PublicKey{pub}
+
+	_, err := VerifyBundle(dir, nil)
+	require.ErrorIs(t, err, ErrBundleNoTrustedKeys)
+
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = VerifyBundle(dir, []ed25519.PublicKey{other})
+	require.ErrorIs(t, err, ErrBundleSignatureInvalid)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image", "a.png"), []byte("tampered"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image", "b.png"), []byte("new"), 0o644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "pipeline", "main.json")))
+	_, err = VerifyBundle(dir, keys)
+	var mismatch *BundleFileMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	require.Equal(t, []string{"pipeline/main.json"}, mismatch.Missing)
+	require.Equal(t, []string{"image/a.png"}, mismatch.Modified)
+	require.Equal(t, []string{"image/b.png"}, mismatch.Extra)
+
+	// Editing the manifest invalidates the signature.
+	manifest, err := BuildBundleManifest(dir)
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, BundleSignatureFile))
+	require.NoError(t, err)
+	require.NoError(t, WriteBundleManifest(dir, manifest))
+	_, err = VerifyBundle(dir, keys)
+	require.ErrorIs(t, err, ErrBundleUnsigned)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, BundleSignatureFile), data, 0o644))
+	_, err = VerifyBundle(dir, keys)
+	require.ErrorIs(t, err, ErrBundleSignatureInvalid)
+
+	_, err = VerifyBundle(t.TempDir(), keys)
+	require.ErrorIs(t, err, ErrBundleManifestMissing)
+}
+
+func TestBundleSignature_RejectsSymlinks(t *testing.T) {
+	dir, pub, _ := writeSignedTestBundle(t)
+	keys := []ed25519.PublicKey{pub}
+
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "main.json"), []byte(`{"A":{"action":"Click"}}`), 0o644))
+
+	// A symlinked file in place of a signed one.
+	target := filepath.Join(dir, "pipeline", "main.json")
+	require.NoError(t, os.Remove(target))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "main.json"), target))
+	_, err := VerifyBundle(dir, keys)
+	require.ErrorIs(t, err, ErrBundleIrregularFile)
+
+	// A symlinked directory that is not in the manifest.
+	require.NoError(t, os.Remove(target))
+	require.NoError(t, os.WriteFile(target, []byte(`{"A":{}}`), 0o644))
+	_, err = VerifyBundle(dir, keys)
+	require.NoError(t, err)
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "extra")))
+	_, err = VerifyBundle(dir, keys)
+	require.ErrorIs(t, err, ErrBundleIrregularFile)
+
+	// A symlink to the bundle root itself is fine.
+	link := filepath.Join(t.TempDir(), "bundle")
+	require.NoError(t, os.Remove(filepath.Join(dir, "extra")))
+	require.NoError(t, os.Symlink(dir, link))
+	_, err = VerifyBundle(link, keys)
+	require.NoError(t, err)
+}
+
+func TestParseBundleKeys(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	gotPub, err := ParseBundlePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	require.NoError(t, err)
+	require.True(t, pub.Equal(gotPub))
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	gotPriv, err := ParseBundlePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}))
+	require.NoError(t, err)
+	require.True(t, priv.Equal(gotPriv))
+
+	_, err = ParseBundlePublicKey([]byte("garbage"))
+	require.Error(t, err)
+}
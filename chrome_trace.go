@@ -0,0 +1,408 @@
+package maa
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ChromeTraceExporter turns tasker and controller events into Chrome Trace Event
+// JSON, which can be opened in chrome://tracing or https://ui.perfetto.dev.
+//
+// Every attached tasker and controller gets its own track. Pipeline nodes contain
+// their next-list recognitions, the pre phase (delays and wait-freezes before the
+// action), the action, and the post phase. Wait-freezes are recorded from the
+// Node.WaitFreezes events. Custom recognitions and actions wrapped
+// with WrapCustomRecognition and WrapCustomAction appear nested in the slice that
+// called them.
+type ChromeTraceExporter struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	origin  time.Time
+	tracks  []string
+	spans   []chromeTraceSpan
+	open    map[string]int
+	nodes   map[string]*chromeTraceNode
+	done    []*chromeTraceNode
+	taskTID map[uint64]int
+}
+
+type chromeTraceSpan struct {
+	name  string
+	cat   string
+	tid   int
+	start time.Time
+	end   time.Time
+	args  map[string]any
+}
+
+// chromeTraceNode records the phases of a pipeline node used to derive the
+// pre and post phases.
+type chromeTraceNode struct {
+	tid         int
+	taskID      uint64
+	name        string
+	start       time.Time
+	end         time.Time
+	nextEnd     time.Time
+	actionStart time.Time
+	actionEnd   time.Time
+}
+
+// NewChromeTraceExporter creates an empty exporter.
+func NewChromeTraceExporter() *ChromeTraceExporter {
+	return &ChromeTraceExporter{
+		now:     time.Now,
+		open:    make(map[string]int),
+		nodes:   make(map[string]*chromeTraceNode),
+		taskTID: make(map[uint64]int),
+	}
+}
+
+// Attach registers a track named name for tasker and returns the tasker and
+// context sink IDs.
+func (e *ChromeTraceExporter) Attach(tasker *Tasker, name string) (sinkID, contextSinkID int64) {
+	track := e.track(name)
+	return tasker.AddSink(track), tasker.AddContextSink(track)
+}
+
+// AttachController registers a track named name for ctrl and returns the sink ID.
+func (e *ChromeTraceExporter) AttachController(ctrl *Controller, name string) int64 {
+	return ctrl.AddSink(e.track(name))
+}
+
+func (e *ChromeTraceExporter) track(name string) *chromeTraceTrack {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tracks = append(e.tracks, name)
+	return &chromeTraceTrack{e: e, tid: len(e.tracks)}
+}
+
+// chromeTraceTrack is the sink registered for a single tasker or controller.
+type chromeTraceTrack struct {
+	e   *ChromeTraceExporter
+	tid int
+}
+
+// OnTaskerTask implements TaskerEventSink.
+func (t *chromeTraceTrack) OnTaskerTask(tasker *Tasker, status EventStatus, detail TaskerTaskDetail) {
+	t.e.event(t.tid, "task", fmt.Sprintf("task:%d", detail.TaskID), detail.Entry, status,
+		map[string]any{"task_id": detail.TaskID, "entry": detail.Entry})
+}
+
+// OnNodePipelineNode implements ContextEventSink.
+func (t *chromeTraceTrack) OnNodePipelineNode(ctx *Context, status EventStatus, detail NodePipelineNodeDetail) {
+	t.e.pipelineNode(t.tid, status, detail)
+}
+
+// OnNodeRecognitionNode implements ContextEventSink.
+func (t *chromeTraceTrack) OnNodeRecognitionNode(ctx *Context, status EventStatus, detail NodeRecognitionNodeDetail) {
+	t.e.event(t.tid, "node", fmt.Sprintf("reco_node:%d:%s", detail.TaskID, detail.Name), detail.Name, status,
+		map[string]any{"task_id": detail.TaskID, "node_id": detail.NodeID})
+}
+
+// OnNodeActionNode implements ContextEventSink.
+func (t *chromeTraceTrack) OnNodeActionNode(ctx *Context, status EventStatus, detail NodeActionNodeDetail) {
+	t.e.event(t.tid, "node", fmt.Sprintf("action_node:%d:%s", detail.TaskID, detail.Name), detail.Name, status,
+		map[string]any{"task_id": detail.TaskID, "node_id": detail.NodeID})
+}
+
+// OnNodeNextList implements ContextEventSink.
+func (t *chromeTraceTrack) OnNodeNextList(ctx *Context, status EventStatus, detail NodeNextListDetail) {
+	names := make([]string, len(detail.List))
+	for i, item := range detail.List {
+		names[i] = item.Name
+	}
+	now := t.e.event(t.tid, "next_list", fmt.Sprintf("next:%d:%s", detail.TaskID, detail.Name), "next "+detail.Name, status,
+		map[string]any{"task_id": detail.TaskID, "list": names})
+
+	if status != EventStatusStarting {
+		t.e.mu.Lock()
+		if node := t.e.nodes[chromeTraceNodeKey(t.tid, detail.TaskID)]; node != nil {
+			node.nextEnd = now
+		}
+		t.e.mu.Unlock()
+	}
+}
+
+// OnNodeRecognition implements ContextEventSink.
+func (t *chromeTraceTrack) OnNodeRecognition(ctx *Context, status EventStatus, detail NodeRecognitionDetail) {
+	t.e.event(t.tid, "recognition", fmt.Sprintf("reco:%d:%s", detail.TaskID, detail.Name), detail.Name, status,
+		map[string]any{"task_id": detail.TaskID, "reco_id": detail.RecognitionID})
+}
+
+// OnNodeAction implements ContextEventSink.
+func (t *chromeTraceTrack) OnNodeAction(ctx *Context, status EventStatus, detail NodeActionDetail) {
+	now := t.e.event(t.tid, "action", fmt.Sprintf("action:%d:%s", detail.TaskID, detail.Name), detail.Name, status,
+		map[string]any{"task_id": detail.TaskID, "action_id": detail.ActionID})
+
+	t.e.mu.Lock()
+	if node := t.e.nodes[chromeTraceNodeKey(t.tid, detail.TaskID)]; node != nil {
+		if status == EventStatusStarting {
+			node.actionStart = now
+		} else {
+			node.actionEnd = now
+		}
+	}
+	t.e.mu.Unlock()
+}
+
+// OnNodeWaitFreezes implements NodeWaitFreezesEventSink.
+func (t *chromeTraceTrack) OnNodeWaitFreezes(ctx *Context, status EventStatus, detail NodeWaitFreezesDetail) {
+	t.e.event(t.tid, "wait_freezes", fmt.Sprintf("wf:%d", detail.WaitFreezesID), "wait_freezes "+detail.Phase, status,
+		map[string]any{"task_id": detail.TaskID, "wf_id": detail.WaitFreezesID, "node": detail.Name})
+}
+
+// OnControllerAction implements ControllerEventSink.
+func (t *chromeTraceTrack) OnControllerAction(ctrl *Controller, status EventStatus, detail ControllerActionDetail) {
+	t.e.event(t.tid, "controller", fmt.Sprintf("ctrl:%d", detail.CtrlID), detail.Action, status,
+		map[string]any{"ctrl_id": detail.CtrlID, "param": detail.Param})
+}
+
+func chromeTraceNodeKey(tid int, taskID uint64) string {
+	return fmt.Sprintf("%d/%d", tid, taskID)
+}
+
+func (e *ChromeTraceExporter) pipelineNode(tid int, status EventStatus, detail NodePipelineNodeDetail) {
+	now := e.event(tid, "node", fmt.Sprintf("node:%d:%s", detail.TaskID, detail.Name), detail.Name, status,
+		map[string]any{"task_id": detail.TaskID, "node_id": detail.NodeID})
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	key := chromeTraceNodeKey(tid, detail.TaskID)
+	if status == EventStatusStarting {
+		e.nodes[key] = &chromeTraceNode{tid: tid, taskID: detail.TaskID, name: detail.Name, start: now}
+		return
+	}
+	node := e.nodes[key]
+	if node == nil {
+		return
+	}
+	delete(e.nodes, key)
+	node.end = now
+	e.done = append(e.done, node)
+
+	if !node.actionStart.IsZero() {
+		preStart := node.nextEnd
+		if preStart.IsZero() {
+			preStart = node.start
+		}
+		e.addSpan(chromeTraceSpan{name: "pre", cat: "delay", tid: tid, start: preStart, end: node.actionStart})
+	}
+	if !node.actionEnd.IsZero() {
+		e.addSpan(chromeTraceSpan{name: "post", cat: "delay", tid: tid, start: node.actionEnd, end: node.end})
+	}
+}
+
+// event opens a span on Starting and closes the span with the same key otherwise.
+// It returns the time of the event.
+func (e *ChromeTraceExporter) event(tid int, cat, key, name string, status EventStatus, args map[string]any) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if taskID, ok := args["task_id"].(uint64); ok {
+		e.taskTID[taskID] = tid
+	}
+	key = fmt.Sprintf("%d/%s", tid, key)
+	if status == EventStatusStarting {
+		e.open[key] = e.addSpan(chromeTraceSpan{name: name, cat: cat, tid: tid, start: now, args: args})
+		return now
+	}
+	i, ok := e.open[key]
+	if !ok {
+		// The Starting event was missed; record an instant slice.
+		i = e.addSpan(chromeTraceSpan{name: name, cat: cat, tid: tid, start: now, args: args})
+	}
+	delete(e.open, key)
+	e.spans[i].end = now
+	maps.Copy(e.spans[i].args, args)
+	e.spans[i].args["status"] = status.String()
+	return now
+}
+
+// addSpan must be called with e.mu held.
+func (e *ChromeTraceExporter) addSpan(span chromeTraceSpan) int {
+	if e.origin.IsZero() || span.start.Before(e.origin) {
+		e.origin = span.start
+	}
+	if span.args == nil {
+		span.args = make(map[string]any)
+	}
+	e.spans = append(e.spans, span)
+	return len(e.spans) - 1
+}
+
+// custom records a custom callback span on the track of taskID.
+func (e *ChromeTraceExporter) custom(cat, name string, taskID int64, run func() bool) bool {
+	e.mu.Lock()
+	tid := e.taskTID[uint64(taskID)]
+	i := e.addSpan(chromeTraceSpan{name: name, cat: cat, tid: tid, start: e.now(),
+		args: map[string]any{"task_id": taskID}})
+	e.mu.Unlock()
+
+	ok := run()
+
+	e.mu.Lock()
+	e.spans[i].end = e.now()
+	e.spans[i].args["success"] = ok
+	e.mu.Unlock()
+	return ok
+}
+
+// WrapCustomAction returns a runner that records every call of action as a slice.
+func (e *ChromeTraceExporter) WrapCustomAction(action CustomActionRunner) CustomActionRunner {
+	return &chromeTraceCustomAction{e: e, action: action}
+}
+
+// WrapCustomRecognition returns a runner that records every call of recognition as a slice.
+func (e *ChromeTraceExporter) WrapCustomRecognition(recognition CustomRecognitionRunner) CustomRecognitionRunner {
+	return &chromeTraceCustomRecognition{e: e, recognition: recognition}
+}
+
+type chromeTraceCustomAction struct {
+	e      *ChromeTraceExporter
+	action CustomActionRunner
+}
+
+func (a *chromeTraceCustomAction) Run(ctx *Context, arg *CustomActionArg) bool {
+	return a.e.custom("custom_action", arg.CustomActionName, arg.TaskID, func() bool {
+		return a.action.Run(ctx, arg)
+	})
+}
+
+type chromeTraceCustomRecognition struct {
+	e           *ChromeTraceExporter
+	recognition CustomRecognitionRunner
+}
+
+func (r *chromeTraceCustomRecognition) Run(ctx *Context, arg *CustomRecognitionArg) (*CustomRecognitionResult, bool) {
+	var result *CustomRecognitionResult
+	ok := r.e.custom("custom_recognition", arg.CustomRecognitionName, arg.TaskID, func() bool {
+		var hit bool
+		result, hit = r.recognition.Run(ctx, arg)
+		return hit
+	})
+	return result, ok
+}
+
+// RecordWaitFreezes adds a wait-freezes slice from a detail returned by
+// Tasker.GetWaitFreezesDetail, for wait-freezes whose Node.WaitFreezes events
+// were not seen by an attached tasker, e.g. the wf_id of a NodeWaitFreezesDetail
+// received by another sink. The slice is placed in the last finished node named
+// detail.NodeName: a "pre" phase ends when its action starts, other phases start
+// when its action ends. It reports whether such a node was found.
+func (e *ChromeTraceExporter) RecordWaitFreezes(detail *WaitFreezesDetail) bool {
+	if detail == nil {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, node := range slices.Backward(e.done) {
+		if node.name != detail.NodeName {
+			continue
+		}
+		elapsed := time.Duration(detail.ElapsedMs) * time.Millisecond
+		var start time.Time
+		switch {
+		case detail.Phase == "pre" && !node.actionStart.IsZero():
+			start = node.actionStart.Add(-elapsed)
+		case !node.actionEnd.IsZero():
+			start = node.actionEnd
+		default:
+			start = node.start
+		}
+		e.addSpan(chromeTraceSpan{
+			name:  "wait_freezes " + detail.Phase,
+			cat:   "wait_freezes",
+			tid:   node.tid,
+			start: start,
+			end:   start.Add(elapsed),
+			args:  map[string]any{"wf_id": detail.ID, "success": detail.Success, "elapsed_ms": detail.ElapsedMs},
+		})
+		return true
+	}
+	return false
+}
+
+// chromeTraceEvent is a single entry of the Trace Event Format.
+type chromeTraceEvent struct {
+	Name string         `json:"name"`
+	Cat  string         `json:"cat,omitempty"`
+	Ph   string         `json:"ph"`
+	Ts   int64          `json:"ts"`
+	Dur  *int64         `json:"dur,omitempty"`
+	Pid  int            `json:"pid"`
+	Tid  int            `json:"tid"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// MarshalJSON encodes the recorded slices in the Trace Event Format.
+// Slices that are still open end at the time of the call.
+func (e *ChromeTraceExporter) MarshalJSON() ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	events := make([]chromeTraceEvent, 0, len(e.tracks)+len(e.spans)+1)
+	events = append(events, chromeTraceEvent{
+		Name: "process_name", Ph: "M", Pid: 1,
+		Args: map[string]any{"name": "MaaFramework"},
+	})
+	for i, name := range e.tracks {
+		events = append(events, chromeTraceEvent{
+			Name: "thread_name", Ph: "M", Pid: 1, Tid: i + 1,
+			Args: map[string]any{"name": name},
+		})
+	}
+
+	now := e.now()
+	spans := slices.Clone(e.spans)
+	// Parents must come before their children when timestamps are equal.
+	slices.SortStableFunc(spans, func(a, b chromeTraceSpan) int {
+		if c := a.start.Compare(b.start); c != 0 {
+			return c
+		}
+		return spanEnd(b, now).Compare(spanEnd(a, now))
+	})
+	for _, span := range spans {
+		dur := spanEnd(span, now).Sub(span.start).Microseconds()
+		events = append(events, chromeTraceEvent{
+			Name: span.name,
+			Cat:  span.cat,
+			Ph:   "X",
+			Ts:   span.start.Sub(e.origin).Microseconds(),
+			Dur:  &dur,
+			Pid:  1,
+			Tid:  span.tid,
+			Args: span.args,
+		})
+	}
+
+	return json.Marshal(struct {
+		TraceEvents     []chromeTraceEvent `json:"traceEvents"`
+		DisplayTimeUnit string             `json:"displayTimeUnit"`
+	}{events, "ms"})
+}
+
+func spanEnd(span chromeTraceSpan, now time.Time) time.Time {
+	if span.end.IsZero() {
+		return now
+	}
+	return span.end
+}
+
+// WriteTo writes the Trace Event JSON to w.
+func (e *ChromeTraceExporter) WriteTo(w io.Writer) (int64, error) {
+	data, err := e.MarshalJSON()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
@@ -0,0 +1,125 @@
+package maa
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubCustomAction struct {
+	run func()
+}
+
+func (a stubCustomAction) Run(*Context, *CustomActionArg) bool {
+	if a.run != nil {
+		a.run()
+	}
+	return true
+}
+
+type decodedChromeTrace struct {
+	TraceEvents []struct {
+		Name string         `json:"name"`
+		Cat  string         `json:"cat"`
+		Ph   string         `json:"ph"`
+		Ts   int64          `json:"ts"`
+		Dur  int64          `json:"dur"`
+		Tid  int            `json:"tid"`
+		Args map[string]any `json:"args"`
+	} `json:"traceEvents"`
+}
+
+func TestChromeTraceExporter(t *testing.T) {
+	e := NewChromeTraceExporter()
+	clock := time.Unix(0, 0)
+	e.now = func() time.Time { return clock }
+	step := func(ms int) { clock = clock.Add(time.Duration(ms) * time.Millisecond) }
+
+	track := e.track("emulator-5554")
+	ctrlTrack := e.track("controller")
+
+	track.OnTaskerTask(nil, EventStatusStarting, TaskerTaskDetail{TaskID: 1, Entry: "Start"})
+	track.OnNodePipelineNode(nil, EventStatusStarting, NodePipelineNodeDetail{TaskID: 1, Name: "Start"})
+	track.OnNodeNextList(nil, EventStatusStarting, NodeNextListDetail{TaskID: 1, Name: "Start"})
+	track.OnNodeRecognition(nil, EventStatusStarting, NodeRecognitionDetail{TaskID: 1, RecognitionID: 3, Name: "A"})
+	step(10)
+	track.OnNodeRecognition(nil, EventStatusSucceeded, NodeRecognitionDetail{TaskID: 1, RecognitionID: 3, Name: "A"})
+	track.OnNodeNextList(nil, EventStatusSucceeded, NodeNextListDetail{TaskID: 1, Name: "Start"})
+	step(200) // pre_delay
+	track.OnNodeAction(nil, EventStatusStarting, NodeActionDetail{TaskID: 1, Name: "A"})
+
+	wrapped := e.WrapCustomAction(stubCustomAction{run: func() {
+		step(5)
+		ctrlTrack.OnControllerAction(nil, EventStatusStarting, ControllerActionDetail{CtrlID: 9, Action: "click"})
+		step(20)
+		ctrlTrack.OnControllerAction(nil, EventStatusSucceeded, ControllerActionDetail{CtrlID: 9, Action: "click"})
+	}})
+	require.True(t, wrapped.Run(nil, &CustomActionArg{TaskID: 1, CustomActionName: "MyAction"}))
+
+	step(5)
+	track.OnNodeAction(nil, EventStatusSucceeded, NodeActionDetail{TaskID: 1, ActionID: 4, Name: "A"})
+	track.OnNodeWaitFreezes(nil, EventStatusStarting, NodeWaitFreezesDetail{TaskID: 1, WaitFreezesID: 6, Name: "Start", Phase: "post"})
+	step(100)
+	track.OnNodeWaitFreezes(nil, EventStatusSucceeded, NodeWaitFreezesDetail{TaskID: 1, WaitFreezesID: 6, Name: "Start", Phase: "post"})
+	step(200) // post_delay
+	track.OnNodePipelineNode(nil, EventStatusSucceeded, NodePipelineNodeDetail{TaskID: 1, NodeID: 2, Name: "Start"})
+	track.OnTaskerTask(nil, EventStatusSucceeded, TaskerTaskDetail{TaskID: 1, Entry: "Start"})
+
+	require.True(t, e.RecordWaitFreezes(&WaitFreezesDetail{ID: 5, NodeName: "Start", Phase: "pre", ElapsedMs: 50}))
+	require.False(t, e.RecordWaitFreezes(&WaitFreezesDetail{NodeName: "Missing"}))
+
+	data, err := json.Marshal(e)
+	require.NoError(t, err)
+	var decoded decodedChromeTrace
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	type slice struct {
+		tid       int
+		ts, dur   int64
+		cat, name string
+	}
+	var slices []slice
+	threads := map[int]string{}
+	for _, ev := range decoded.TraceEvents {
+		switch ev.Ph {
+		case "M":
+			if ev.Name == "thread_name" {
+				threads[ev.Tid] = ev.Args["name"].(string)
+			}
+		case "X":
+			slices = append(slices, slice{ev.Tid, ev.Ts / 1000, ev.Dur / 1000, ev.Cat, ev.Name})
+		}
+	}
+	require.Equal(t, map[int]string{1: "emulator-5554", 2: "controller"}, threads)
+	require.Equal(t, []slice{
+		{1, 0, 540, "task", "Start"},
+		{1, 0, 540, "node", "Start"},
+		{1, 0, 10, "next_list", "next Start"},
+		{1, 0, 10, "recognition", "A"},
+		{1, 10, 200, "delay", "pre"},
+		{1, 160, 50, "wait_freezes", "wait_freezes pre"},
+		{1, 210, 30, "action", "A"},
+		{1, 210, 25, "custom_action", "MyAction"},
+		{2, 215, 20, "controller", "click"},
+		{1, 240, 300, "delay", "post"},
+		{1, 240, 100, "wait_freezes", "wait_freezes post"},
+	}, slices)
+}
+
+func TestEventCallback_NodeWaitFreezes(t *testing.T) {
+	var got []NodeWaitFreezesDetail
+	cb := &eventCallback{sink: &contextEventSinkAdapter{
+		onNodeWaitFreezes: func(_ *Context, status EventStatus, detail NodeWaitFreezesDetail) {
+			require.Equal(t, EventStatusSucceeded, status)
+			got = append(got, detail)
+		},
+	}}
+	cb.handleRaw(1, "Node.WaitFreezes.Succeeded", []byte(`{"task_id":1,"wf_id":6,"name":"Start","phase":"post"}`))
+
+	// Sinks without OnNodeWaitFreezes ignore the event.
+	(&eventCallback{sink: &taskerEventSinkAdapter{}}).handleRaw(1, "Node.WaitFreezes.Succeeded", []byte(`{}`))
+
+	require.Equal(t, []NodeWaitFreezesDetail{{TaskID: 1, WaitFreezesID: 6, Name: "Start", Phase: "post"}}, got)
+}
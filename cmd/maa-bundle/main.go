@@ -0,0 +1,186 @@
+// Command maa-bundle signs and verifies resource bundles.
+//
+//	maa-bundle keygen -out key
+//	maa-bundle sign -key key.pem [-record-hash] <bundle>
+//	maa-bundle verify -pub key.pub.pem [-pub other.pub.pem] <bundle>
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(os.Args[2:])
+	case "sign":
+		err = sign(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "maa-bundle:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: maa-bundle <keygen|sign|verify> [flags]")
+}
+
+func keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := fs.String("out", "bundle", "output prefix; writes <out>.pem and <out>.pub.pem")
+	fs.Parse(args)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out+".pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(*out+".pub.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o644)
+}
+
+func sign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyPath := fs.String("key", "", "PEM encoded ed25519 private key")
+	recordHash := fs.Bool("record-hash", false, "load the bundle with MaaFramework and record Resource.GetHash in the manifest")
+	libDir := fs.String("lib-dir", "", "directory containing the MaaFramework libraries, used with -record-hash")
+	fs.Parse(args)
+	if *keyPath == "" || fs.NArg() != 1 {
+		return errors.New("usage: maa-bundle sign -key key.pem [-record-hash] <bundle>")
+	}
+	bundle := fs.Arg(0)
+
+	data, err := os.ReadFile(*keyPath)
+	if err != nil {
+		return err
+	}
+	key, err := maa.ParseBundlePrivateKey(data)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := maa.ReadBundleManifest(bundle)
+	if errors.Is(err, maa.ErrBundleManifestMissing) {
+		manifest = &maa.BundleManifest{}
+	} else if err != nil {
+		return err
+	}
+	built, err := maa.BuildBundleManifest(bundle)
+	if err != nil {
+		return err
+	}
+	manifest.Files = built.Files
+
+	if *recordHash {
+		hash, err := resourceHash(bundle, *libDir)
+		if err != nil {
+			return err
+		}
+		manifest.ResourceHash = hash
+	}
+
+	if err := maa.WriteBundleManifest(bundle, manifest); err != nil {
+		return err
+	}
+	if err := maa.SignBundle(bundle, key); err != nil {
+		return err
+	}
+	fmt.Printf("signed %d files in %s\n", len(manifest.Files), bundle)
+	return nil
+}
+
+func resourceHash(bundle, libDir string) (string, error) {
+	var opts []maa.InitOption
+	if libDir != "" {
+		opts = append(opts, maa.WithLibDir(libDir))
+	}
+	if err := maa.Init(opts...); err != nil {
+		return "", err
+	}
+	defer maa.Release()
+
+	res, err := maa.NewResource()
+	if err != nil {
+		return "", err
+	}
+	defer res.Destroy()
+
+	if !res.PostBundle(bundle).Wait().Success() {
+		return "", fmt.Errorf("failed to load bundle %s", bundle)
+	}
+	return res.GetHash()
+}
+
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	var pubPaths stringList
+	fs.Var(&pubPaths, "pub", "PEM encoded ed25519 public key; may be repeated")
+	fs.Parse(args)
+	if len(pubPaths) == 0 || fs.NArg() != 1 {
+		return errors.New("usage: maa-bundle verify -pub key.pub.pem [-pub ...] <bundle>")
+	}
+
+	pubkeys := make([]ed25519.PublicKey, 0, len(pubPaths))
+	for _, path := range pubPaths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key, err := maa.ParseBundlePublicKey(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		pubkeys = append(pubkeys, key)
+	}
+
+	verification, err := maa.VerifyBundle(fs.Arg(0), pubkeys)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ok: %d files, signed by %s, manifest %s\n",
+		len(verification.Manifest.Files), pubPaths[verification.KeyIndex], verification.ManifestDigest)
+	if verification.Manifest.ResourceHash != "" {
+		fmt.Println("resource hash:", verification.Manifest.ResourceHash)
+	}
+	return nil
+}
+
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+	"github.com/MaaXYZ/maa-framework-go/v4/controller/adb"
+	"github.com/MaaXYZ/maa-framework-go/v4/controller/win32"
+	"github.com/MaaXYZ/maa-framework-go/v4/maatest"
+)
+
+// controllerSpec is a parsed -ctrl value.
+type controllerSpec struct {
+	kind string
+	arg  string
+}
+
+func parseControllerSpec(spec string) (controllerSpec, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "blank":
+		if arg != "" {
+			return controllerSpec{}, usageError("blank takes no argument")
+		}
+	case "adb", "win32", "replay", "images":
+		if arg == "" {
+			return controllerSpec{}, usageError(fmt.Sprintf("%s needs an argument, e.g. %s:...", kind, kind))
+		}
+	default:
+		return controllerSpec{}, usageError(fmt.Sprintf("unknown controller %q, want adb, win32, replay, images or blank", kind))
+	}
+	return controllerSpec{kind: kind, arg: arg}, nil
+}
+
+func newController(spec string) (*maa.Controller, error) {
+	s, err := parseControllerSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.kind {
+	case "adb":
+		return newAdbController(s.arg)
+	case "win32":
+		title, err := regexp.Compile(s.arg)
+		if err != nil {
+			return nil, usageError(fmt.Sprintf("invalid window title pattern: %v", err))
+		}
+		win, err := maa.FindDesktopWindow(maa.WindowQuery{Title: title})
+		if err != nil {
+			return nil, err
+		}
+		return maa.NewWin32Controller(win.Handle, win32.ScreencapBackground, win32.InputSendMessage, win32.InputSendMessage)
+	case "replay":
+		return maa.NewReplayController(s.arg)
+	case "images":
+		return maatest.ImageDir(s.arg)()
+	default:
+		return maa.NewBlankController()
+	}
+}
+
+// newAdbController uses the device found by FindAdbDevices for address, so that
+// the adb path, methods and emulator config match, and falls back to the adb on
+// PATH with the default methods.
+func newAdbController(address string) (*maa.Controller, error) {
+	devices, _ := maa.FindAdbDevices()
+	for _, dev := range devices {
+		if dev.Address == address {
+			return maa.NewAdbController(dev.AdbPath, dev.Address, dev.ScreencapMethod, dev.InputMethod, dev.Config, "")
+		}
+	}
+	return maa.NewAdbController("adb", address, adb.ScreencapDefault, adb.InputDefault, "{}", "")
+}
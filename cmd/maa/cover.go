@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+)
+
+// cover merges the coverage profiles written by run -coverprofile and reports
+// them against the nodes of the bundles.
+func cover(args []string) error {
+	fs := flag.NewFlagSet("cover", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	var bundles stringList
+	fs.Var(&bundles, "bundle", "resource bundle to load; may be repeated")
+	ignore := fs.String("ignore", "", "leave out the nodes whose name matches this regular expression")
+	out := fs.String("o", "", "also write the merged profile to this file")
+	fs.Parse(args)
+
+	if len(bundles) == 0 {
+		return usageError("at least one -bundle is required")
+	}
+	if fs.NArg() == 0 {
+		return usageError("at least one coverage profile is required")
+	}
+	var opts []maa.CoverageOption
+	if *ignore != "" {
+		pattern, err := regexp.Compile(*ignore)
+		if err != nil {
+			return usageError("-ignore: " + err.Error())
+		}
+		opts = append(opts, maa.WithCoverageIgnore(pattern))
+	}
+
+	profile, err := readCoverProfiles(fs.Args())
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		if err := writeCoverProfile(*out, profile); err != nil {
+			return err
+		}
+	}
+
+	if err := common.init(); err != nil {
+		return err
+	}
+	defer maa.Release()
+
+	res, err := loadResource(bundles)
+	if err != nil {
+		return err
+	}
+	defer res.Destroy()
+
+	report, err := maa.NewCoverageReport(profile, res, opts...)
+	if err != nil {
+		return err
+	}
+	return report.WriteText(os.Stdout)
+}
+
+// readCoverProfiles reads and merges the profiles at paths.
+func readCoverProfiles(paths []string) (*maa.CoverageProfile, error) {
+	merged := maa.NewCoverageProfile()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		profile, err := maa.ReadCoverageProfile(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		merged.Merge(profile)
+	}
+	return merged, nil
+}
+
+func writeCoverProfile(path string, profile *maa.CoverageProfile) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := profile.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,445 @@
+// Command maa runs MaaFramework pipelines from the command line.
+//
+//	maa run -bundle ./resource -ctrl adb:127.0.0.1:5555 -entry Startup [-override '{...}'] [-json summary.json] [-coverprofile cover.json]
+//	maa cover -bundle ./resource [-ignore REGEXP] [-o merged.json] cover.json...
+//	maa nodes -bundle ./resource [node ...]
+//	maa devices [-windows] [-json]
+//	maa screenshot -ctrl adb:127.0.0.1:5555 -out screen.png
+//
+// Controllers are given as -ctrl specs:
+//
+//	adb:ADDRESS     an ADB device, using the adb path and methods found by FindAdbDevices
+//	win32:TITLE     the desktop window whose title matches the regular expression TITLE
+//	replay:FILE     a recording written by a record controller
+//	images:DIR      the PNG/JPEG files of DIR in name order; each input action shows the next one
+//	blank           a controller that does nothing
+//
+// run exits with 0 when the task succeeded, 1 when it failed, 2 on usage errors
+// and 3 when the task could not be started.
+//
+// cover merges the pipeline coverage profiles written by run -coverprofile,
+// for example by several replay runs, and prints which nodes and branches of
+// the bundles were never exercised.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+)
+
+const (
+	exitSuccess = 0
+	exitFailure = 1
+	exitUsage   = 2
+	exitError   = 3
+)
+
+// usageError is reported with exitUsage.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	var (
+		code int
+		err  error
+	)
+	switch os.Args[1] {
+	case "run":
+		code, err = run(os.Args[2:])
+	case "cover":
+		err = cover(os.Args[2:])
+	case "nodes":
+		err = nodes(os.Args[2:])
+	case "devices":
+		err = devices(os.Args[2:])
+	case "screenshot":
+		err = screenshot(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	default:
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	var usageErr usageError
+	switch {
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(os.Stderr, "maa:", err)
+		os.Exit(exitUsage)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "maa:", err)
+		os.Exit(exitError)
+	}
+	os.Exit(code)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: maa <run|cover|nodes|devices|screenshot> [flags]")
+}
+
+// commonFlags are the flags shared by the subcommands that use MaaFramework.
+type commonFlags struct {
+	libDir string
+	logDir string
+	debug  bool
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.libDir, "lib-dir", "", "directory containing the MaaFramework libraries")
+	fs.StringVar(&c.logDir, "log-dir", "", "directory MaaFramework writes its log to")
+	fs.BoolVar(&c.debug, "debug", false, "enable debug mode, which keeps recognition images")
+}
+
+func (c *commonFlags) init() error {
+	opts := []maa.InitOption{maa.WithStdoutLevel(maa.LoggingLevelOff)}
+	if c.libDir != "" {
+		opts = append(opts, maa.WithLibDir(c.libDir))
+	}
+	if c.logDir != "" {
+		opts = append(opts, maa.WithLogDir(c.logDir))
+	}
+	if c.debug {
+		opts = append(opts, maa.WithDebugMode(true))
+	}
+	return maa.Init(opts...)
+}
+
+func loadResource(bundles []string) (*maa.Resource, error) {
+	if len(bundles) == 0 {
+		return nil, usageError("at least one -bundle is required")
+	}
+	res, err := maa.NewResource()
+	if err != nil {
+		return nil, err
+	}
+	for _, bundle := range bundles {
+		if !res.PostBundle(bundle).Wait().Success() {
+			res.Destroy()
+			return nil, fmt.Errorf("failed to load bundle %s", bundle)
+		}
+	}
+	return res, nil
+}
+
+func connectController(spec string) (*maa.Controller, error) {
+	if spec == "" {
+		return nil, usageError("-ctrl is required")
+	}
+	ctrl, err := newController(spec)
+	if err != nil {
+		return nil, err
+	}
+	if !ctrl.PostConnect().Wait().Success() {
+		ctrl.Destroy()
+		return nil, fmt.Errorf("failed to connect controller %s", spec)
+	}
+	return ctrl, nil
+}
+
+func run(args []string) (int, error) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	var bundles stringList
+	fs.Var(&bundles, "bundle", "resource bundle to load; may be repeated, later bundles override earlier ones")
+	ctrlSpec := fs.String("ctrl", "", "controller spec, e.g. adb:127.0.0.1:5555 or images:./frames")
+	entry := fs.String("entry", "", "entry node of the task")
+	override := fs.String("override", "", "pipeline override as JSON, or @FILE to read it from FILE")
+	summaryPath := fs.String("json", "", "write a JSON run summary to this file, - for stdout")
+	coverProfile := fs.String("coverprofile", "", "write the pipeline coverage of the run to this file")
+	fs.Parse(args)
+
+	if *entry == "" {
+		return 0, usageError("-entry is required")
+	}
+	if len(bundles) == 0 {
+		return 0, usageError("at least one -bundle is required")
+	}
+	if *ctrlSpec == "" {
+		return 0, usageError("-ctrl is required")
+	}
+	overrideJSON, err := readOverride(*override)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := common.init(); err != nil {
+		return 0, err
+	}
+	defer maa.Release()
+
+	res, err := loadResource(bundles)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Destroy()
+	ctrl, err := connectController(*ctrlSpec)
+	if err != nil {
+		return 0, err
+	}
+	defer ctrl.Destroy()
+
+	tasker, err := maa.NewTasker()
+	if err != nil {
+		return 0, err
+	}
+	defer tasker.Destroy()
+	if err := tasker.BindResource(res); err != nil {
+		return 0, err
+	}
+	if err := tasker.BindController(ctrl); err != nil {
+		return 0, err
+	}
+	if !tasker.Initialized() {
+		return 0, errors.New("tasker failed to initialize")
+	}
+	var coverage *maa.CoverageCollector
+	if *coverProfile != "" {
+		coverage = maa.NewCoverageCollector()
+		coverage.Attach(tasker)
+	}
+
+	start := time.Now()
+	var job *maa.TaskJob
+	if overrideJSON != "" {
+		job = tasker.PostTask(*entry, overrideJSON)
+	} else {
+		job = tasker.PostTask(*entry)
+	}
+	job.Wait()
+	if err := job.Error(); err != nil {
+		return 0, err
+	}
+
+	detail, detailErr := job.GetDetail()
+	summary := newRunSummary(*entry, job.Status(), start, time.Now(), detail, detailErr)
+	summary.print(os.Stderr)
+	if *summaryPath != "" {
+		if err := summary.write(*summaryPath); err != nil {
+			return 0, err
+		}
+	}
+	if coverage != nil {
+		if err := writeCoverProfile(*coverProfile, coverage.Profile()); err != nil {
+			return 0, err
+		}
+	}
+
+	if summary.Status != maa.StatusSuccess.String() {
+		return exitFailure, nil
+	}
+	return exitSuccess, nil
+}
+
+func readOverride(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	data := []byte(value)
+	if path, ok := strings.CutPrefix(value, "@"); ok {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return "", err
+		}
+	}
+	if !json.Valid(data) {
+		return "", usageError("-override is not valid JSON")
+	}
+	return string(data), nil
+}
+
+func nodes(args []string) error {
+	fs := flag.NewFlagSet("nodes", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	var bundles stringList
+	fs.Var(&bundles, "bundle", "resource bundle to load; may be repeated")
+	fs.Parse(args)
+	if len(bundles) == 0 {
+		return usageError("at least one -bundle is required")
+	}
+
+	if err := common.init(); err != nil {
+		return err
+	}
+	defer maa.Release()
+
+	res, err := loadResource(bundles)
+	if err != nil {
+		return err
+	}
+	defer res.Destroy()
+
+	if fs.NArg() == 0 {
+		list, err := res.GetNodeList()
+		if err != nil {
+			return err
+		}
+		slices.Sort(list)
+		for _, name := range list {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	out := make(map[string]json.RawMessage, fs.NArg())
+	for _, name := range fs.Args() {
+		data, err := res.GetNodeJSON(name)
+		if err != nil || data == "" {
+			return fmt.Errorf("node %s not found", name)
+		}
+		out[name] = json.RawMessage(data)
+	}
+	return printJSON(out)
+}
+
+func devices(args []string) error {
+	fs := flag.NewFlagSet("devices", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	windows := fs.Bool("windows", false, "list desktop windows instead of ADB devices")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	adbPath := fs.String("adb", "", "only search with this adb executable")
+	fs.Parse(args)
+
+	if err := common.init(); err != nil {
+		return err
+	}
+	defer maa.Release()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	if *windows {
+		list, err := maa.FindDesktopWindows()
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			out := make([]map[string]string, len(list))
+			for i, win := range list {
+				out[i] = map[string]string{
+					"handle":      fmt.Sprintf("%p", win.Handle),
+					"class_name":  win.ClassName,
+					"window_name": win.WindowName,
+				}
+			}
+			return printJSON(out)
+		}
+		fmt.Fprintln(w, "HANDLE\tCLASS\tTITLE")
+		for _, win := range list {
+			fmt.Fprintf(w, "%p\t%s\t%s\n", win.Handle, win.ClassName, win.WindowName)
+		}
+		return nil
+	}
+
+	var specified []string
+	if *adbPath != "" {
+		specified = append(specified, *adbPath)
+	}
+	list, err := maa.FindAdbDevices(specified...)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		out := make([]map[string]string, len(list))
+		for i, dev := range list {
+			out[i] = map[string]string{
+				"name":             dev.Name,
+				"adb_path":         dev.AdbPath,
+				"address":          dev.Address,
+				"screencap_method": dev.ScreencapMethod.String(),
+				"input_method":     dev.InputMethod.String(),
+				"ctrl":             "adb:" + dev.Address,
+			}
+		}
+		return printJSON(out)
+	}
+	fmt.Fprintln(w, "NAME\tADDRESS\tADB\tCTRL")
+	for _, dev := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\tadb:%s\n", dev.Name, dev.Address, dev.AdbPath, dev.Address)
+	}
+	return nil
+}
+
+func screenshot(args []string) error {
+	fs := flag.NewFlagSet("screenshot", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	ctrlSpec := fs.String("ctrl", "", "controller spec, e.g. adb:127.0.0.1:5555")
+	out := fs.String("out", "screenshot.png", "PNG file to write")
+	rawSize := fs.Bool("raw", false, "keep the device resolution instead of the framework default")
+	fs.Parse(args)
+	if *ctrlSpec == "" {
+		return usageError("-ctrl is required")
+	}
+
+	if err := common.init(); err != nil {
+		return err
+	}
+	defer maa.Release()
+
+	ctrl, err := connectController(*ctrlSpec)
+	if err != nil {
+		return err
+	}
+	defer ctrl.Destroy()
+	if *rawSize {
+		if err := ctrl.SetScreenshot(maa.WithScreenshotUseRawSize(true)); err != nil {
+			return err
+		}
+	}
+
+	if !ctrl.PostScreencap().Wait().Success() {
+		return errors.New("screencap failed")
+	}
+	img, err := ctrl.CacheImage()
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %dx%d\n", *out, img.Bounds().Dx(), img.Bounds().Dy())
+	return nil
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
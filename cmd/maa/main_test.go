@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseControllerSpec(t *testing.T) {
+	s, err := parseControllerSpec("adb:127.0.0.1:5555")
+	require.NoError(t, err)
+	require.Equal(t, controllerSpec{kind: "adb", arg: "127.0.0.1:5555"}, s)
+
+	s, err = parseControllerSpec("blank")
+	require.NoError(t, err)
+	require.Equal(t, "blank", s.kind)
+
+	var usageErr usageError
+	for _, bad := range []string{"", "adb", "images:", "blank:x", "serial:COM1"} {
+		_, err := parseControllerSpec(bad)
+		require.ErrorAs(t, err, &usageErr, bad)
+	}
+}
+
+func TestReadOverride(t *testing.T) {
+	got, err := readOverride(`{"A":{"enabled":false}}`)
+	require.NoError(t, err)
+	require.Equal(t, `{"A":{"enabled":false}}`, got)
+
+	path := filepath.Join(t.TempDir(), "override.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"B":{}}`), 0o644))
+	got, err = readOverride("@" + path)
+	require.NoError(t, err)
+	require.Equal(t, `{"B":{}}`, got)
+
+	_, err = readOverride("{")
+	var usageErr usageError
+	require.ErrorAs(t, err, &usageErr)
+}
+
+func TestNewController_Images(t *testing.T) {
+	require.NoError(t, maa.Init(maa.WithNativeBackend(maafake.New())))
+	defer maa.Release()
+
+	dir := t.TempDir()
+	for _, name := range []string{"b.png", "a.png"} {
+		f, err := os.Create(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.NoError(t, png.Encode(f, image.NewGray(image.Rect(0, 0, 2, 2))))
+		require.NoError(t, f.Close())
+	}
+	ctrl, err := newController("images:" + dir)
+	require.NoError(t, err)
+	ctrl.Destroy()
+
+	_, err = newController("images:" + t.TempDir())
+	require.Error(t, err)
+}
+
+func TestRunSummary(t *testing.T) {
+	start := time.Unix(100, 0)
+	s := newRunSummary("Start", maa.StatusFailure, start, start.Add(1500*time.Millisecond), nil, errors.New("boom"))
+	require.Equal(t, "failure", s.Status)
+	require.Equal(t, int64(1500), s.DurationMs)
+	require.Equal(t, "boom", s.Error)
+	require.Empty(t, s.Nodes)
+
+	n := newNodeSummary(&maa.NodeDetail{
+		ID:          7,
+		Name:        "Start",
+		Recognition: &maa.RecognitionDetail{ID: 3, Algorithm: "OCR", Hit: true, Box: maa.Rect{1, 2, 3, 4}},
+		Action:      &maa.ActionDetail{ID: 5, Action: "Click", Success: true},
+	})
+	require.Equal(t, "OCR", n.Recognition.Algorithm)
+	require.True(t, n.Recognition.Hit)
+	require.Equal(t, maa.Rect{1, 2, 3, 4}, n.Recognition.Box)
+	require.True(t, n.Action.Success)
+
+	path := filepath.Join(t.TempDir(), "summary.json")
+	require.NoError(t, s.write(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"status": "failure"`)
+}
+
+func TestCoverProfiles(t *testing.T) {
+	dir := t.TempDir()
+	a := maa.NewCoverageProfile()
+	a.Tasks = 1
+	a.Nodes["Start"] = &maa.NodeCoverage{Entered: 1, RecognitionHit: 1}
+	b := maa.NewCoverageProfile()
+	b.Tasks = 2
+	b.Nodes["Start"] = &maa.NodeCoverage{RecognitionMiss: 2}
+	require.NoError(t, writeCoverProfile(filepath.Join(dir, "a.json"), a))
+	require.NoError(t, writeCoverProfile(filepath.Join(dir, "b.json"), b))
+
+	merged, err := readCoverProfiles([]string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), merged.Tasks)
+	require.Equal(t, &maa.NodeCoverage{Entered: 1, RecognitionHit: 1, RecognitionMiss: 2}, merged.Nodes["Start"])
+
+	null := filepath.Join(dir, "null.json")
+	require.NoError(t, os.WriteFile(null, []byte(`{"tasks":1,"nodes":{"Start":null}}`), 0o644))
+	merged, err = readCoverProfiles([]string{null, filepath.Join(dir, "a.json")})
+	require.NoError(t, err)
+	require.Equal(t, &maa.NodeCoverage{Entered: 1, RecognitionHit: 1}, merged.Nodes["Start"])
+
+	_, err = readCoverProfiles([]string{filepath.Join(dir, "missing.json")})
+	require.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+)
+
+// runSummary is the JSON document written by run -json.
+type runSummary struct {
+	Entry      string        `json:"entry"`
+	TaskID     int64         `json:"task_id"`
+	Status     string        `json:"status"`
+	StartedAt  time.Time     `json:"started_at"`
+	DurationMs int64         `json:"duration_ms"`
+	Nodes      []nodeSummary `json:"nodes"`
+	Error      string        `json:"error,omitempty"`
+}
+
+type nodeSummary struct {
+	ID           int64               `json:"id"`
+	Name         string              `json:"name"`
+	Recognition  *recognitionSummary `json:"recognition,omitempty"`
+	Action       *actionSummary      `json:"action,omitempty"`
+	RunCompleted bool                `json:"completed"`
+}
+
+type recognitionSummary struct {
+	ID        int64    `json:"id"`
+	Algorithm string   `json:"algorithm"`
+	Hit       bool     `json:"hit"`
+	Box       maa.Rect `json:"box"`
+}
+
+type actionSummary struct {
+	ID      int64    `json:"id"`
+	Action  string   `json:"action"`
+	Box     maa.Rect `json:"box"`
+	Success bool     `json:"success"`
+}
+
+func newRunSummary(entry string, status maa.Status, start, end time.Time, detail *maa.TaskDetail, detailErr error) *runSummary {
+	s := &runSummary{
+		Entry:      entry,
+		Status:     status.String(),
+		StartedAt:  start,
+		DurationMs: end.Sub(start).Milliseconds(),
+		Nodes:      []nodeSummary{},
+	}
+	if detailErr != nil {
+		s.Error = detailErr.Error()
+		return s
+	}
+	if detail == nil {
+		return s
+	}
+
+	s.TaskID = detail.ID
+	for _, ref := range detail.Nodes {
+		node, err := ref.GetDetail()
+		if err != nil {
+			s.Error = fmt.Sprintf("failed to get node detail: %v", err)
+			continue
+		}
+		s.Nodes = append(s.Nodes, newNodeSummary(node))
+	}
+	return s
+}
+
+func newNodeSummary(node *maa.NodeDetail) nodeSummary {
+	n := nodeSummary{
+		ID:           node.ID,
+		Name:         node.Name,
+		RunCompleted: node.RunCompleted,
+	}
+	if reco := node.Recognition; reco != nil {
+		n.Recognition = &recognitionSummary{
+			ID:        reco.ID,
+			Algorithm: reco.Algorithm,
+			Hit:       reco.Hit,
+			Box:       reco.Box,
+		}
+	}
+	if act := node.Action; act != nil {
+		n.Action = &actionSummary{
+			ID:      act.ID,
+			Action:  act.Action,
+			Box:     act.Box,
+			Success: act.Success,
+		}
+	}
+	return n
+}
+
+// print writes a one-line-per-node human summary to w.
+func (s *runSummary) print(w io.Writer) {
+	for _, n := range s.Nodes {
+		line := n.Name
+		if r := n.Recognition; r != nil {
+			line += fmt.Sprintf(" reco=%s hit=%t", r.Algorithm, r.Hit)
+		}
+		if a := n.Action; a != nil {
+			line += fmt.Sprintf(" action=%s success=%t", a.Action, a.Success)
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "%s: %s (%d nodes, %dms)\n", s.Entry, s.Status, len(s.Nodes), s.DurationMs)
+	if s.Error != "" {
+		fmt.Fprintln(w, "error:", s.Error)
+	}
+}
+
+// write writes the summary as JSON to path, or to stdout if path is "-".
+func (s *runSummary) write(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -3,6 +3,7 @@ package maa
 import (
 	"errors"
 	"image"
+	"log/slog"
 	"reflect"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 type Context struct {
 	handle uintptr
 	tasker *Tasker
+	logger *slog.Logger
 }
 
 func isNilOverride(v any) bool {
@@ -469,7 +471,21 @@ func (ctx *Context) WaitFreezes(
 // Clone clones current Context.
 func (ctx *Context) Clone() *Context {
 	handle := native.MaaContextClone(ctx.handle)
-	return &Context{handle: handle}
+	return &Context{handle: handle, logger: ctx.logger}
+}
+
+// Logger returns the logger for code running in this context.
+// Runners wrapped by SlogSink get a logger that already carries the task ID
+// and node name. Otherwise the logger set with Tasker.SetLogger is returned,
+// falling back to slog.Default.
+func (ctx *Context) Logger() *slog.Logger {
+	if ctx.logger != nil {
+		return ctx.logger
+	}
+	if logger := ctx.GetTasker().Logger(); logger != nil {
+		return logger
+	}
+	return slog.Default()
 }
 
 // SetAnchor sets an anchor by name.
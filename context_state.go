@@ -0,0 +1,352 @@
+package maa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ContextStateVersion is the version of the ContextState JSON document written by this package.
+const ContextStateVersion = 1
+
+var ErrContextStateVersion = errors.New("unsupported context state version")
+
+// ContextState is a snapshot of the runtime state of a Context: anchors and node
+// hit counts. It survives the process as a versioned JSON document.
+type ContextState struct {
+	Version int       `json:"version"`
+	SavedAt time.Time `json:"saved_at,omitzero"`
+	// Anchors maps anchor name to the node it points to.
+	Anchors map[string]string `json:"anchors,omitempty"`
+	// HitCounts maps node name to its hit count. Nodes that were never hit are omitted.
+	HitCounts map[string]uint64 `json:"hit_counts,omitempty"`
+}
+
+// contextStateTarget is the part of Context that snapshots read and restores write.
+type contextStateTarget interface {
+	GetAnchor(anchorName string) (string, error)
+	SetAnchor(anchorName, nodeName string) error
+	GetHitCount(nodeName string) (uint64, error)
+	GetNode(name string) (*Node, error)
+	OverridePipeline(override any) error
+	// NodeList returns every node of the bound resource.
+	NodeList() ([]string, error)
+}
+
+type contextStateContext struct {
+	*Context
+}
+
+func (c contextStateContext) NodeList() ([]string, error) {
+	return c.GetTasker().GetResource().GetNodeList()
+}
+
+type contextStateConfig struct {
+	nodes   []string
+	anchors []string
+	base    *ContextState
+	now     func() time.Time
+}
+
+// ContextStateOption configures SnapshotContextState.
+type ContextStateOption func(*contextStateConfig)
+
+// WithContextStateNodes limits the hit counts captured to nodes.
+// By default every node of the bound resource is captured.
+func WithContextStateNodes(nodes ...string) ContextStateOption {
+	return func(cfg *contextStateConfig) {
+		cfg.nodes = append(cfg.nodes, nodes...)
+	}
+}
+
+// WithContextStateAnchors sets the anchors captured.
+// By default every anchor declared by the captured nodes is captured.
+func WithContextStateAnchors(anchors ...string) ContextStateOption {
+	return func(cfg *contextStateConfig) {
+		cfg.anchors = append(cfg.anchors, anchors...)
+	}
+}
+
+// WithContextStateBase adds the hit counts of base, typically the state restored
+// at task start, to the counts read from the context, and keeps the anchors of
+// base that are not set in the context.
+func WithContextStateBase(base *ContextState) ContextStateOption {
+	return func(cfg *contextStateConfig) {
+		cfg.base = base
+	}
+}
+
+// SnapshotContextState captures the anchors and hit counts of ctx.
+func SnapshotContextState(ctx *Context, opts ...ContextStateOption) (*ContextState, error) {
+	return snapshotContextState(contextStateContext{ctx}, opts...)
+}
+
+func snapshotContextState(target contextStateTarget, opts ...ContextStateOption) (*ContextState, error) {
+	cfg := contextStateConfig{now: time.Now}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	nodes := cfg.nodes
+	if nodes == nil {
+		var err error
+		if nodes, err = target.NodeList(); err != nil {
+			return nil, err
+		}
+	}
+
+	state := &ContextState{
+		Version:   ContextStateVersion,
+		SavedAt:   cfg.now(),
+		Anchors:   make(map[string]string),
+		HitCounts: make(map[string]uint64),
+	}
+
+	anchors := cfg.anchors
+	for _, name := range nodes {
+		count, err := target.GetHitCount(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hit count of %s: %w", name, err)
+		}
+		if cfg.base != nil {
+			count += cfg.base.HitCounts[name]
+		}
+		if count > 0 {
+			state.HitCounts[name] = count
+		}
+
+		if cfg.anchors != nil {
+			continue
+		}
+		node, err := target.GetNode(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", name, err)
+		}
+		for anchor := range node.Anchor {
+			if !slices.Contains(anchors, anchor) {
+				anchors = append(anchors, anchor)
+			}
+		}
+	}
+
+	for _, anchor := range anchors {
+		// An anchor that was never set cannot be read.
+		if node, err := target.GetAnchor(anchor); err == nil && node != "" {
+			state.Anchors[anchor] = node
+		} else if cfg.base != nil && cfg.base.Anchors[anchor] != "" {
+			state.Anchors[anchor] = cfg.base.Anchors[anchor]
+		}
+	}
+	return state, nil
+}
+
+// ParseContextState parses a ContextState JSON document.
+func ParseContextState(data []byte) (*ContextState, error) {
+	var state ContextState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid context state: %w", err)
+	}
+	if state.Version != ContextStateVersion {
+		return nil, fmt.Errorf("%w: %d", ErrContextStateVersion, state.Version)
+	}
+	return &state, nil
+}
+
+// LoadContextState reads a ContextState saved with Save.
+func LoadContextState(path string) (*ContextState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseContextState(data)
+}
+
+// Save writes the state to path. The file is replaced atomically, so a crash
+// while saving leaves the previous state intact.
+func (s *ContextState) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Apply restores the state into ctx.
+//
+// Anchors are set directly. The framework cannot set a hit count, so for every
+// node with a max_hit the limit of the current task is lowered by the saved
+// count instead. Apply is therefore meant to run at task start, before the
+// nodes are hit; ContextStateRestorer does that.
+func (s *ContextState) Apply(ctx *Context) error {
+	return s.apply(contextStateContext{ctx})
+}
+
+func (s *ContextState) apply(target contextStateTarget) error {
+	var errs []error
+	for _, anchor := range slices.Sorted(maps.Keys(s.Anchors)) {
+		if err := target.SetAnchor(anchor, s.Anchors[anchor]); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore anchor %s: %w", anchor, err))
+		}
+	}
+
+	override := make(map[string]any)
+	for _, name := range slices.Sorted(maps.Keys(s.HitCounts)) {
+		node, err := target.GetNode(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore hit count of %s: %w", name, err))
+			continue
+		}
+		if node.MaxHit == nil {
+			continue
+		}
+		remaining := uint64(0)
+		if *node.MaxHit > s.HitCounts[name] {
+			remaining = *node.MaxHit - s.HitCounts[name]
+		}
+		override[name] = map[string]any{"max_hit": remaining}
+	}
+	if len(override) > 0 {
+		if err := target.OverridePipeline(override); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore hit counts: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HitCount returns the saved hit count of node, for custom code that keeps its
+// own limits on top of GetHitCount.
+func (s *ContextState) HitCount(node string) uint64 {
+	return s.HitCounts[node]
+}
+
+// ContextStateRestorer applies a ContextState once, at the start of the next task.
+// It can be attached to a tasker as a context sink, which applies it on the first
+// pipeline node, or wrap a custom action or recognition, which applies it on the
+// first call. Whichever comes first wins.
+type ContextStateRestorer struct {
+	state *ContextState
+
+	mu      sync.Mutex
+	applied bool
+	err     error
+}
+
+// NewContextStateRestorer creates a restorer for state.
+func NewContextStateRestorer(state *ContextState) *ContextStateRestorer {
+	return &ContextStateRestorer{state: state}
+}
+
+// State returns the state being restored.
+func (r *ContextStateRestorer) State() *ContextState {
+	return r.state
+}
+
+// Attach registers the restorer on tasker as a context sink and returns the sink ID.
+func (r *ContextStateRestorer) Attach(tasker *Tasker) int64 {
+	return tasker.AddContextSink(r)
+}
+
+// Apply applies the state to ctx unless it was applied already, and returns the
+// error of the one application.
+func (r *ContextStateRestorer) Apply(ctx *Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.applied {
+		r.applied = true
+		r.err = r.state.apply(contextStateContext{ctx})
+	}
+	return r.err
+}
+
+// Applied reports whether the state was applied, successfully or not.
+func (r *ContextStateRestorer) Applied() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.applied
+}
+
+// Err returns the error of the application, or nil if it succeeded or did not happen yet.
+func (r *ContextStateRestorer) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Snapshot captures the state of ctx on top of the restored state, so the saved
+// hit counts keep growing across runs.
+func (r *ContextStateRestorer) Snapshot(ctx *Context, opts ...ContextStateOption) (*ContextState, error) {
+	return snapshotContextState(contextStateContext{ctx}, append(opts, WithContextStateBase(r.state))...)
+}
+
+// WrapCustomAction returns a runner that applies the state before the first call of action.
+func (r *ContextStateRestorer) WrapCustomAction(action CustomActionRunner) CustomActionRunner {
+	return &contextStateCustomAction{r: r, action: action}
+}
+
+// WrapCustomRecognition returns a runner that applies the state before the first call of recognition.
+func (r *ContextStateRestorer) WrapCustomRecognition(recognition CustomRecognitionRunner) CustomRecognitionRunner {
+	return &contextStateCustomRecognition{r: r, recognition: recognition}
+}
+
+// OnNodePipelineNode implements ContextEventSink.
+func (r *ContextStateRestorer) OnNodePipelineNode(ctx *Context, status EventStatus, _ NodePipelineNodeDetail) {
+	if status == EventStatusStarting {
+		r.Apply(ctx)
+	}
+}
+
+// OnNodeRecognitionNode implements ContextEventSink.
+func (r *ContextStateRestorer) OnNodeRecognitionNode(*Context, EventStatus, NodeRecognitionNodeDetail) {
+}
+
+// OnNodeActionNode implements ContextEventSink.
+func (r *ContextStateRestorer) OnNodeActionNode(*Context, EventStatus, NodeActionNodeDetail) {}
+
+// OnNodeNextList implements ContextEventSink.
+func (r *ContextStateRestorer) OnNodeNextList(*Context, EventStatus, NodeNextListDetail) {}
+
+// OnNodeRecognition implements ContextEventSink.
+func (r *ContextStateRestorer) OnNodeRecognition(*Context, EventStatus, NodeRecognitionDetail) {}
+
+// OnNodeAction implements ContextEventSink.
+func (r *ContextStateRestorer) OnNodeAction(*Context, EventStatus, NodeActionDetail) {}
+
+type contextStateCustomAction struct {
+	r      *ContextStateRestorer
+	action CustomActionRunner
+}
+
+func (a *contextStateCustomAction) Run(ctx *Context, arg *CustomActionArg) bool {
+	a.r.Apply(ctx)
+	return a.action.Run(ctx, arg)
+}
+
+type contextStateCustomRecognition struct {
+	r           *ContextStateRestorer
+	recognition CustomRecognitionRunner
+}
+
+func (c *contextStateCustomRecognition) Run(ctx *Context, arg *CustomRecognitionArg) (*CustomRecognitionResult, bool) {
+	c.r.Apply(ctx)
+	return c.recognition.Run(ctx, arg)
+}
@@ -0,0 +1,189 @@
+package maa
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeContextStateTarget struct {
+	nodes     map[string]*Node
+	anchors   map[string]string
+	hits      map[string]uint64
+	overrides []any
+}
+
+func (f *fakeContextStateTarget) GetAnchor(anchorName string) (string, error) {
+	if node, ok := f.anchors[anchorName]; ok {
+		return node, nil
+	}
+	return "", errors.New("failed to get anchor")
+}
+
+func (f *fakeContextStateTarget) SetAnchor(anchorName, nodeName string) error {
+	f.anchors[anchorName] = nodeName
+	return nil
+}
+
+func (f *fakeContextStateTarget) GetHitCount(nodeName string) (uint64, error) {
+	return f.hits[nodeName], nil
+}
+
+func (f *fakeContextStateTarget) GetNode(name string) (*Node, error) {
+	if node, ok := f.nodes[name]; ok {
+		return node, nil
+	}
+	return nil, errors.New("failed to get node")
+}
+
+func (f *fakeContextStateTarget) OverridePipeline(override any) error {
+	f.overrides = append(f.overrides, override)
+	return nil
+}
+
+func (f *fakeContextStateTarget) NodeList() ([]string, error) {
+	var names []string
+	for name := range f.nodes {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func newFakeContextStateTarget() *fakeContextStateTarget {
+	return &fakeContextStateTarget{
+		nodes: map[string]*Node{
+			"Start":  NewNode("Start").SetAnchorTarget("Back", "Menu"),
+			"Daily":  NewNode("Daily").SetMaxHit(3),
+			"Reward": NewNode("Reward").SetMaxHit(1),
+			"Menu":   NewNode("Menu"),
+		},
+		anchors: map[string]string{},
+		hits:    map[string]uint64{},
+	}
+}
+
+func TestContextState_SnapshotSaveLoadApply(t *testing.T) {
+	running := newFakeContextStateTarget()
+	running.anchors["Back"] = "Menu"
+	running.hits["Daily"] = 2
+	running.hits["Reward"] = 4
+	running.hits["Menu"] = 1
+
+	saved := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	state, err := snapshotContextState(running, func(cfg *contextStateConfig) {
+		cfg.now = func() time.Time { return saved }
+	})
+	require.NoError(t, err)
+	require.Equal(t, &ContextState{
+		Version:   ContextStateVersion,
+		SavedAt:   saved,
+		Anchors:   map[string]string{"Back": "Menu"},
+		HitCounts: map[string]uint64{"Daily": 2, "Reward": 4, "Menu": 1},
+	}, state)
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, state.Save(path))
+	loaded, err := LoadContextState(path)
+	require.NoError(t, err)
+	require.Equal(t, state, loaded)
+
+	restarted := newFakeContextStateTarget()
+	require.NoError(t, loaded.apply(restarted))
+	require.Equal(t, map[string]string{"Back": "Menu"}, restarted.anchors)
+	require.Equal(t, []any{map[string]any{
+		"Daily":  map[string]any{"max_hit": uint64(1)},
+		"Reward": map[string]any{"max_hit": uint64(0)},
+	}}, restarted.overrides)
+}
+
+func TestContextState_SnapshotScopeAndBase(t *testing.T) {
+	target := newFakeContextStateTarget()
+	target.hits["Daily"] = 1
+	target.hits["Menu"] = 5
+
+	base := &ContextState{
+		Version:   ContextStateVersion,
+		Anchors:   map[string]string{"Back": "Start", "Other": "Menu"},
+		HitCounts: map[string]uint64{"Daily": 2},
+	}
+	state, err := snapshotContextState(target,
+		WithContextStateNodes("Daily"),
+		WithContextStateAnchors("Back"),
+		WithContextStateBase(base),
+	)
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint64{"Daily": 3}, state.HitCounts)
+	require.Equal(t, map[string]string{"Back": "Start"}, state.Anchors)
+}
+
+func TestParseContextState_Version(t *testing.T) {
+	_, err := ParseContextState([]byte(`{"version":2}`))
+	require.ErrorIs(t, err, ErrContextStateVersion)
+
+	state, err := ParseContextState([]byte(`{"version":1,"hit_counts":{"A":3}}`))
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), state.HitCount("A"))
+	require.Zero(t, state.HitCount("B"))
+}
+
+type contextStateTestAction struct {
+	r        *ContextStateRestorer
+	calls    int
+	anchor   string
+	maxHit   uint64
+	snapshot *ContextState
+}
+
+func (a *contextStateTestAction) Run(ctx *Context, _ *CustomActionArg) bool {
+	a.calls++
+	a.anchor, _ = ctx.GetAnchor("Back")
+	if node, err := ctx.GetNode("Daily"); err == nil && node.MaxHit != nil {
+		a.maxHit = *node.MaxHit
+	}
+	a.snapshot, _ = a.r.Snapshot(ctx, WithContextStateNodes("Daily"))
+	return true
+}
+
+func TestContextStateRestorer_AppliesOnce(t *testing.T) {
+	state := &ContextState{
+		Version:   ContextStateVersion,
+		Anchors:   map[string]string{"Back": "Menu"},
+		HitCounts: map[string]uint64{"Daily": 1},
+	}
+	r := NewContextStateRestorer(state)
+	r.OnNodePipelineNode(nil, EventStatusSucceeded, NodePipelineNodeDetail{})
+	require.False(t, r.Applied())
+
+	fake := useFakeBackend(t)
+	fake.RunTask = func(task *maafake.Task) bool {
+		return task.RunNode(task.DefaultNode("Daily")) && task.RunNode(task.DefaultNode("Start"))
+	}
+	tasker := newFakeTasker(t, fake, map[string]any{
+		"Daily": map[string]any{"max_hit": 3},
+		"Start": map[string]any{"action": map[string]any{"type": "Custom", "param": map[string]any{"custom_action": "Check"}}},
+		"Menu":  map[string]any{},
+	})
+	action := &contextStateTestAction{r: r}
+	require.NoError(t, tasker.GetResource().RegisterCustomAction("Check", r.WrapCustomAction(action)))
+	r.Attach(tasker)
+
+	// The sink applies the state when Daily starts; the wrapped action finds
+	// it applied.
+	require.True(t, tasker.PostTask("Daily").Wait().Success())
+	require.True(t, r.Applied())
+	require.NoError(t, r.Err())
+	require.Equal(t, 1, action.calls)
+	require.Equal(t, "Menu", action.anchor)
+	require.EqualValues(t, 2, action.maxHit)
+	require.Equal(t, map[string]uint64{"Daily": 2}, action.snapshot.HitCounts)
+
+	// The next task starts from the pipeline again.
+	require.True(t, tasker.PostTask("Daily").Wait().Success())
+	require.Equal(t, 2, action.calls)
+	require.Empty(t, action.anchor)
+	require.EqualValues(t, 3, action.maxHit)
+}
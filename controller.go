@@ -54,6 +54,21 @@ func NewAdbController(
 	}, nil
 }
 
+// NewAdbControllerWithConfig creates a new ADB controller from a typed config.
+// The config is validated before the controller is created; a nil config is sent as "{}".
+func NewAdbControllerWithConfig(
+	adbPath, address string,
+	screencapMethod adb.ScreencapMethod,
+	inputMethod adb.InputMethod,
+	config *adb.Config,
+	agentPath string,
+) (*Controller, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return NewAdbController(adbPath, address, screencapMethod, inputMethod, config.String(), agentPath)
+}
+
 // NewPlayCoverController creates a new PlayCover controller.
 func NewPlayCoverController(
 	address, uuid string,
@@ -279,11 +294,13 @@ func (c *Controller) Destroy() {
 	store.CtrlStore.Lock()
 	value := store.CtrlStore.Get(c.handle)
 	unregisterCustomControllerCallbacks(value.CustomControllerCallbacksID)
+	store.CtrlStore.Del(c.handle)
+	store.CtrlStore.Unlock()
+
+	// Unregistering waits for the async sink handlers still running.
 	for _, cbID := range value.SinkIDToEventCallbackID {
 		unregisterEventCallback(cbID)
 	}
-	store.CtrlStore.Del(c.handle)
-	store.CtrlStore.Unlock()
 
 	native.MaaControllerDestroy(c.handle)
 }
@@ -635,8 +652,9 @@ func (c *Controller) GetInfo() (string, error) {
 
 // AddSink adds a event callback sink and returns the sink ID.
 // The sink ID can be used to remove the sink later.
-func (c *Controller) AddSink(sink ControllerEventSink) int64 {
-	id := registerEventCallback(sink)
+// By default the sink is called on the framework thread; see WithSinkAsync.
+func (c *Controller) AddSink(sink ControllerEventSink, opts ...SinkOption) int64 {
+	id := registerEventCallback(sink, opts...)
 	sinkId := native.MaaControllerAddSink(
 		c.handle,
 		_MaaEventCallbackAgent,
@@ -652,22 +670,35 @@ func (c *Controller) AddSink(sink ControllerEventSink) int64 {
 
 // RemoveSink removes a event callback sink by sink ID.
 func (c *Controller) RemoveSink(sinkId int64) {
+	var id uint64
 	store.CtrlStore.Update(c.handle, func(v *store.CtrlStoreValue) {
-		unregisterEventCallback(v.SinkIDToEventCallbackID[sinkId])
+		id = v.SinkIDToEventCallbackID[sinkId]
 		delete(v.SinkIDToEventCallbackID, sinkId)
 	})
+	unregisterEventCallback(id)
 
 	native.MaaControllerRemoveSink(c.handle, sinkId)
 }
 
+// SinkDropped returns the number of events the async sink sinkId dropped
+// because its queue was full.
+func (c *Controller) SinkDropped(sinkId int64) uint64 {
+	store.CtrlStore.Lock()
+	id := store.CtrlStore.Get(c.handle).SinkIDToEventCallbackID[sinkId]
+	store.CtrlStore.Unlock()
+	return sinkDropped(id)
+}
+
 // ClearSinks clears all event callback sinks.
 func (c *Controller) ClearSinks() {
+	var ids map[int64]uint64
 	store.CtrlStore.Update(c.handle, func(v *store.CtrlStoreValue) {
-		for _, id := range v.SinkIDToEventCallbackID {
-			unregisterEventCallback(id)
-		}
+		ids = v.SinkIDToEventCallbackID
 		v.SinkIDToEventCallbackID = make(map[int64]uint64)
 	})
+	for _, id := range ids {
+		unregisterEventCallback(id)
+	}
 
 	native.MaaControllerClearSinks(c.handle)
 }
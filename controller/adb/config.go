@@ -0,0 +1,263 @@
+package adb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	configKeyExtras    = "extras"
+	configKeyMinitouch = "minitouch"
+	configKeyMaatouch  = "maatouch"
+	configKeyScreencap = "screencap"
+
+	extrasKeyMuMu     = "mumu"
+	extrasKeyLDPlayer = "ld"
+)
+
+// Config is the typed form of the config JSON accepted by NewAdbController.
+//
+// Keys that are not modelled here are kept in Unknown and written back by
+// MarshalJSON, so a config parsed from AdbDevice.Config round-trips losslessly.
+type Config struct {
+	Extras    *Extras
+	Minitouch *MinitouchConfig
+	Maatouch  *MaatouchConfig
+	Screencap *ScreencapConfig
+
+	// Unknown holds top-level keys that have no typed field.
+	Unknown map[string]json.RawMessage
+}
+
+// Extras configures emulator-specific extensions used by ScreencapEmulatorExtras
+// and InputEmulatorExtras.
+type Extras struct {
+	MuMu     *MuMuExtras
+	LDPlayer *LDPlayerExtras
+
+	// Unknown holds emulator sections that have no typed field.
+	Unknown map[string]json.RawMessage
+}
+
+// MuMuExtras configures the MuMuPlayer 12 extras.
+type MuMuExtras struct {
+	Enable bool   `json:"enable"`
+	Path   string `json:"path"`
+	Index  int    `json:"index"`
+}
+
+// LDPlayerExtras configures the LDPlayer extras.
+type LDPlayerExtras struct {
+	Enable bool   `json:"enable"`
+	Path   string `json:"path"`
+	Index  int    `json:"index"`
+	PID    int    `json:"pid,omitempty"`
+}
+
+// MinitouchConfig tunes the minitouch input method.
+type MinitouchConfig struct {
+	// Pressure is the touch pressure sent with every contact. Zero keeps the default.
+	Pressure int `json:"pressure,omitempty"`
+}
+
+// MaatouchConfig tunes the maatouch input method.
+type MaatouchConfig struct {
+	// Package is the package name of the maatouch app. Empty keeps the default.
+	Package string `json:"package,omitempty"`
+	// Pressure is the touch pressure sent with every contact. Zero keeps the default.
+	Pressure int `json:"pressure,omitempty"`
+}
+
+// ScreencapConfig tunes the screencap methods.
+type ScreencapConfig struct {
+	// TimeoutMs bounds a single screencap in milliseconds. Zero keeps the default.
+	TimeoutMs int `json:"timeout,omitempty"`
+	// NetcatPort is the local port used by ScreencapRawByNetcat. Zero picks one automatically.
+	NetcatPort int `json:"netcat_port,omitempty"`
+}
+
+// ParseConfig parses a config JSON string such as AdbDevice.Config.
+// An empty string yields an empty config.
+func ParseConfig(data string) (*Config, error) {
+	cfg := &Config{}
+	if strings.TrimSpace(data) == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(data), cfg); err != nil {
+		return nil, fmt.Errorf("invalid adb config: %w", err)
+	}
+	return cfg, nil
+}
+
+// MuMuConfig returns a config that enables the MuMuPlayer 12 extras.
+// path is the MuMuPlayer install directory and index is the emulator instance index.
+func MuMuConfig(path string, index int) *Config {
+	return &Config{
+		Extras: &Extras{
+			MuMu: &MuMuExtras{Enable: true, Path: path, Index: index},
+		},
+	}
+}
+
+// LDPlayerConfig returns a config that enables the LDPlayer extras.
+// path is the LDPlayer install directory, index is the emulator instance index
+// and pid is the emulator process id, or zero if unknown.
+func LDPlayerConfig(path string, index, pid int) *Config {
+	return &Config{
+		Extras: &Extras{
+			LDPlayer: &LDPlayerExtras{Enable: true, Path: path, Index: index, PID: pid},
+		},
+	}
+}
+
+// Validate reports every invalid field in the config.
+func (c *Config) Validate() error {
+	if c == nil {
+		return nil
+	}
+	var errs []error
+	if c.Extras != nil {
+		if mumu := c.Extras.MuMu; mumu != nil && mumu.Enable {
+			if mumu.Path == "" {
+				errs = append(errs, errors.New("extras.mumu.path is required when enabled"))
+			}
+			if mumu.Index < 0 {
+				errs = append(errs, fmt.Errorf("extras.mumu.index must be non-negative, got %d", mumu.Index))
+			}
+		}
+		if ld := c.Extras.LDPlayer; ld != nil && ld.Enable {
+			if ld.Path == "" {
+				errs = append(errs, errors.New("extras.ld.path is required when enabled"))
+			}
+			if ld.Index < 0 {
+				errs = append(errs, fmt.Errorf("extras.ld.index must be non-negative, got %d", ld.Index))
+			}
+			if ld.PID < 0 {
+				errs = append(errs, fmt.Errorf("extras.ld.pid must be non-negative, got %d", ld.PID))
+			}
+		}
+	}
+	if c.Minitouch != nil && c.Minitouch.Pressure < 0 {
+		errs = append(errs, fmt.Errorf("minitouch.pressure must be non-negative, got %d", c.Minitouch.Pressure))
+	}
+	if c.Maatouch != nil && c.Maatouch.Pressure < 0 {
+		errs = append(errs, fmt.Errorf("maatouch.pressure must be non-negative, got %d", c.Maatouch.Pressure))
+	}
+	if c.Screencap != nil {
+		if c.Screencap.TimeoutMs < 0 {
+			errs = append(errs, fmt.Errorf("screencap.timeout must be non-negative, got %d", c.Screencap.TimeoutMs))
+		}
+		if c.Screencap.NetcatPort < 0 || c.Screencap.NetcatPort > 65535 {
+			errs = append(errs, fmt.Errorf("screencap.netcat_port out of range: %d", c.Screencap.NetcatPort))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// String returns the config as a JSON string suitable for NewAdbController.
+// It returns "{}" if the config cannot be marshaled.
+func (c *Config) String() string {
+	if c == nil {
+		return "{}"
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c Config) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(c.Unknown)+4)
+	for k, v := range c.Unknown {
+		out[k] = v
+	}
+	if c.Extras != nil {
+		out[configKeyExtras] = c.Extras
+	}
+	if c.Minitouch != nil {
+		out[configKeyMinitouch] = c.Minitouch
+	}
+	if c.Maatouch != nil {
+		out[configKeyMaatouch] = c.Maatouch
+	}
+	if c.Screencap != nil {
+		out[configKeyScreencap] = c.Screencap
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = Config{}
+	if err := takeKey(raw, configKeyExtras, &c.Extras); err != nil {
+		return err
+	}
+	if err := takeKey(raw, configKeyMinitouch, &c.Minitouch); err != nil {
+		return err
+	}
+	if err := takeKey(raw, configKeyMaatouch, &c.Maatouch); err != nil {
+		return err
+	}
+	if err := takeKey(raw, configKeyScreencap, &c.Screencap); err != nil {
+		return err
+	}
+	if len(raw) > 0 {
+		c.Unknown = raw
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e Extras) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(e.Unknown)+2)
+	for k, v := range e.Unknown {
+		out[k] = v
+	}
+	if e.MuMu != nil {
+		out[extrasKeyMuMu] = e.MuMu
+	}
+	if e.LDPlayer != nil {
+		out[extrasKeyLDPlayer] = e.LDPlayer
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *Extras) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*e = Extras{}
+	if err := takeKey(raw, extrasKeyMuMu, &e.MuMu); err != nil {
+		return err
+	}
+	if err := takeKey(raw, extrasKeyLDPlayer, &e.LDPlayer); err != nil {
+		return err
+	}
+	if len(raw) > 0 {
+		e.Unknown = raw
+	}
+	return nil
+}
+
+// takeKey decodes raw[key] into dst and removes the key from raw.
+func takeKey(raw map[string]json.RawMessage, key string, dst any) error {
+	value, ok := raw[key]
+	if !ok {
+		return nil
+	}
+	delete(raw, key)
+	if err := json.Unmarshal(value, dst); err != nil {
+		return fmt.Errorf("invalid adb config %q: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package adb
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig_RoundTrip(t *testing.T) {
+	input := `{"extras":{"mumu":{"enable":true,"path":"C:/MuMu","index":2},"bluestacks":{"enable":true}},"command":{"Devices":["a"]}}`
+
+	cfg, err := ParseConfig(input)
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if cfg.Extras == nil || cfg.Extras.MuMu == nil {
+		t.Fatalf("ParseConfig() did not decode mumu extras: %+v", cfg)
+	}
+	if got := *cfg.Extras.MuMu; got != (MuMuExtras{Enable: true, Path: "C:/MuMu", Index: 2}) {
+		t.Errorf("mumu extras = %+v", got)
+	}
+	if _, ok := cfg.Extras.Unknown["bluestacks"]; !ok {
+		t.Errorf("unknown extras section was not preserved")
+	}
+	if _, ok := cfg.Unknown["command"]; !ok {
+		t.Errorf("unknown top-level key was not preserved")
+	}
+
+	var want, got any
+	if err := json.Unmarshal([]byte(input), &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(cfg.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("round trip = %v, want %v", got, want)
+	}
+}
+
+func TestParseConfig_Empty(t *testing.T) {
+	for _, input := range []string{"", "  ", "{}"} {
+		cfg, err := ParseConfig(input)
+		if err != nil {
+			t.Fatalf("ParseConfig(%q) error = %v", input, err)
+		}
+		if got := cfg.String(); got != "{}" {
+			t.Errorf("ParseConfig(%q).String() = %s, want {}", input, got)
+		}
+	}
+}
+
+func TestParseConfig_Invalid(t *testing.T) {
+	for _, input := range []string{"[]", `{"extras":1}`, `{"minitouch":"x"}`} {
+		if _, err := ParseConfig(input); err == nil {
+			t.Errorf("ParseConfig(%q) expected error", input)
+		}
+	}
+}
+
+func TestPresets(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *Config
+		expected string
+	}{
+		{"MuMu", MuMuConfig("C:/MuMu", 1), `{"extras":{"mumu":{"enable":true,"path":"C:/MuMu","index":1}}}`},
+		{"LDPlayer", LDPlayerConfig("C:/LD", 0, 42), `{"extras":{"ld":{"enable":true,"path":"C:/LD","index":0,"pid":42}}}`},
+		{"LDPlayerNoPID", LDPlayerConfig("C:/LD", 3, 0), `{"extras":{"ld":{"enable":true,"path":"C:/LD","index":3}}}`},
+		{"Nil", nil, `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got := tt.cfg.String(); got != tt.expected {
+				t.Errorf("String() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{"MuMuMissingPath", MuMuConfig("", 0), "extras.mumu.path"},
+		{"MuMuNegativeIndex", MuMuConfig("C:/MuMu", -1), "extras.mumu.index"},
+		{"LDNegativePID", LDPlayerConfig("C:/LD", 0, -5), "extras.ld.pid"},
+		{"DisabledIsNotChecked", &Config{Extras: &Extras{MuMu: &MuMuExtras{Index: -1}}}, ""},
+		{"MinitouchPressure", &Config{Minitouch: &MinitouchConfig{Pressure: -1}}, "minitouch.pressure"},
+		{"MaatouchPressure", &Config{Maatouch: &MaatouchConfig{Pressure: -1}}, "maatouch.pressure"},
+		{"ScreencapTimeout", &Config{Screencap: &ScreencapConfig{TimeoutMs: -1}}, "screencap.timeout"},
+		{"NetcatPort", &Config{Screencap: &ScreencapConfig{NetcatPort: 70000}}, "screencap.netcat_port"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
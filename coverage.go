@@ -0,0 +1,500 @@
+package maa
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+type coverageConfig struct {
+	ignore *regexp.Regexp
+}
+
+// CoverageOption configures a CoverageCollector.
+type CoverageOption func(*coverageConfig)
+
+// WithCoverageIgnore skips the nodes whose name matches pattern.
+func WithCoverageIgnore(pattern *regexp.Regexp) CoverageOption {
+	return func(cfg *coverageConfig) {
+		cfg.ignore = pattern
+	}
+}
+
+// NodeCoverage counts what happened to one pipeline node.
+type NodeCoverage struct {
+	// Entered counts the runs of the node, after its recognition hit.
+	Entered uint64 `json:"entered"`
+	// RecognitionHit and RecognitionMiss count the recognitions of the node
+	// while it was a candidate in a next or on_error list.
+	RecognitionHit  uint64 `json:"recognition_hit"`
+	RecognitionMiss uint64 `json:"recognition_miss"`
+	// ActionSucceeded and ActionFailed count the results of the node action.
+	ActionSucceeded uint64 `json:"action_succeeded"`
+	ActionFailed    uint64 `json:"action_failed"`
+	// OnError counts the times the on_error list of the node was evaluated.
+	OnError uint64 `json:"on_error"`
+}
+
+func (n *NodeCoverage) add(other NodeCoverage) {
+	n.Entered += other.Entered
+	n.RecognitionHit += other.RecognitionHit
+	n.RecognitionMiss += other.RecognitionMiss
+	n.ActionSucceeded += other.ActionSucceeded
+	n.ActionFailed += other.ActionFailed
+	n.OnError += other.OnError
+}
+
+// CoverageProfile is the pipeline coverage of one or more runs, by node name.
+// Profiles of separate runs, such as separate test binaries, combine with Merge.
+type CoverageProfile struct {
+	// Tasks counts the finished tasks.
+	Tasks uint64                   `json:"tasks"`
+	Nodes map[string]*NodeCoverage `json:"nodes"`
+}
+
+// NewCoverageProfile creates an empty profile.
+func NewCoverageProfile() *CoverageProfile {
+	return &CoverageProfile{Nodes: make(map[string]*NodeCoverage)}
+}
+
+// ReadCoverageProfile reads a profile written by CoverageProfile.WriteTo.
+func ReadCoverageProfile(r io.Reader) (*CoverageProfile, error) {
+	p := NewCoverageProfile()
+	if err := json.NewDecoder(r).Decode(p); err != nil {
+		return nil, fmt.Errorf("failed to read coverage profile: %w", err)
+	}
+	if p.Nodes == nil {
+		p.Nodes = make(map[string]*NodeCoverage)
+	}
+	// A null node counts nothing.
+	maps.DeleteFunc(p.Nodes, func(_ string, node *NodeCoverage) bool { return node == nil })
+	return p, nil
+}
+
+// WriteTo writes p as JSON.
+func (p *CoverageProfile) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// Merge adds the counts of other to p.
+func (p *CoverageProfile) Merge(other *CoverageProfile) {
+	if other == nil {
+		return
+	}
+	p.Tasks += other.Tasks
+	for name, node := range other.Nodes {
+		if node != nil {
+			p.node(name).add(*node)
+		}
+	}
+}
+
+// Clone returns a deep copy of p.
+func (p *CoverageProfile) Clone() *CoverageProfile {
+	clone := NewCoverageProfile()
+	clone.Merge(p)
+	return clone
+}
+
+func (p *CoverageProfile) node(name string) *NodeCoverage {
+	if p.Nodes == nil {
+		p.Nodes = make(map[string]*NodeCoverage)
+	}
+	node := p.Nodes[name]
+	if node == nil {
+		node = &NodeCoverage{}
+		p.Nodes[name] = node
+	}
+	return node
+}
+
+// CoverageCollector records pipeline coverage from the events of the taskers
+// it is attached to. It implements TaskerEventSink and ContextEventSink.
+//
+// A node takes its on_error branch when the list evaluated after it is its
+// on_error list, as seen by the task, pipeline overrides included. Nodes whose
+// next and on_error lists are equal are never counted as taking on_error, and
+// neither are the nodes of a collector added with WithSinkAsync, which gets no
+// Context to read them from.
+type CoverageCollector struct {
+	cfg coverageConfig
+
+	mu      sync.Mutex
+	profile *CoverageProfile
+	// branches caches the next and on_error lists of the nodes seen by each
+	// running task, since a pipeline override can change them.
+	branches map[coverageBranchKey]nodeBranches
+}
+
+type coverageBranchKey struct {
+	taskID uint64
+	name   string
+}
+
+type nodeBranches struct {
+	next, onError []string
+}
+
+// NewCoverageCollector creates a collector with an empty profile.
+func NewCoverageCollector(opts ...CoverageOption) *CoverageCollector {
+	cfg := coverageConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	return &CoverageCollector{
+		cfg:      cfg,
+		profile:  NewCoverageProfile(),
+		branches: make(map[coverageBranchKey]nodeBranches),
+	}
+}
+
+// Attach registers the collector on tasker and returns the tasker and context
+// sink IDs.
+func (c *CoverageCollector) Attach(tasker *Tasker) (sinkID, contextSinkID int64) {
+	return tasker.AddSink(c), tasker.AddContextSink(c)
+}
+
+// Profile returns a copy of the coverage recorded so far.
+func (c *CoverageCollector) Profile() *CoverageProfile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.profile.Clone()
+}
+
+// Reset clears the recorded coverage.
+func (c *CoverageCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.profile = NewCoverageProfile()
+	clear(c.branches)
+}
+
+func (c *CoverageCollector) record(name string, fn func(node *NodeCoverage)) {
+	if c.ignored(name) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(c.profile.node(name))
+}
+
+func (c *CoverageCollector) ignored(name string) bool {
+	return c.cfg.ignore != nil && c.cfg.ignore.MatchString(name)
+}
+
+// OnTaskerTask implements TaskerEventSink.
+func (c *CoverageCollector) OnTaskerTask(tasker *Tasker, status EventStatus, detail TaskerTaskDetail) {
+	if status == EventStatusStarting {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.profile.Tasks++
+	for key := range c.branches {
+		if key.taskID == detail.TaskID {
+			delete(c.branches, key)
+		}
+	}
+}
+
+// OnNodePipelineNode implements ContextEventSink.
+func (c *CoverageCollector) OnNodePipelineNode(ctx *Context, status EventStatus, detail NodePipelineNodeDetail) {
+	if status != EventStatusStarting {
+		return
+	}
+	c.record(detail.Name, func(node *NodeCoverage) { node.Entered++ })
+}
+
+// OnNodeRecognitionNode implements ContextEventSink.
+func (c *CoverageCollector) OnNodeRecognitionNode(ctx *Context, status EventStatus, detail NodeRecognitionNodeDetail) {
+}
+
+// OnNodeActionNode implements ContextEventSink.
+func (c *CoverageCollector) OnNodeActionNode(ctx *Context, status EventStatus, detail NodeActionNodeDetail) {
+}
+
+// OnNodeNextList implements ContextEventSink.
+func (c *CoverageCollector) OnNodeNextList(ctx *Context, status EventStatus, detail NodeNextListDetail) {
+	if status != EventStatusStarting || len(detail.List) == 0 || c.ignored(detail.Name) {
+		return
+	}
+	branches, ok := c.branchesOf(ctx, detail.TaskID, detail.Name)
+	if !ok {
+		return
+	}
+	list := nextItemNames(detail.List)
+	if slices.Equal(list, branches.onError) && !slices.Equal(list, branches.next) {
+		c.record(detail.Name, func(node *NodeCoverage) { node.OnError++ })
+	}
+}
+
+// OnNodeRecognition implements ContextEventSink.
+func (c *CoverageCollector) OnNodeRecognition(ctx *Context, status EventStatus, detail NodeRecognitionDetail) {
+	switch status {
+	case EventStatusSucceeded:
+		c.record(detail.Name, func(node *NodeCoverage) { node.RecognitionHit++ })
+	case EventStatusFailed:
+		c.record(detail.Name, func(node *NodeCoverage) { node.RecognitionMiss++ })
+	}
+}
+
+// OnNodeAction implements ContextEventSink.
+func (c *CoverageCollector) OnNodeAction(ctx *Context, status EventStatus, detail NodeActionDetail) {
+	switch status {
+	case EventStatusSucceeded:
+		c.record(detail.Name, func(node *NodeCoverage) { node.ActionSucceeded++ })
+	case EventStatusFailed:
+		c.record(detail.Name, func(node *NodeCoverage) { node.ActionFailed++ })
+	}
+}
+
+func (c *CoverageCollector) branchesOf(ctx *Context, taskID uint64, name string) (nodeBranches, bool) {
+	key := coverageBranchKey{taskID, name}
+	c.mu.Lock()
+	branches, ok := c.branches[key]
+	c.mu.Unlock()
+	if ok {
+		return branches, true
+	}
+
+	if ctx == nil {
+		return nodeBranches{}, false
+	}
+	node, err := ctx.GetNode(name)
+	if err != nil || node == nil {
+		return nodeBranches{}, false
+	}
+	branches = nodeBranches{next: nextItemNames(node.Next), onError: nextItemNames(node.OnError)}
+	c.mu.Lock()
+	c.branches[key] = branches
+	c.mu.Unlock()
+	return branches, true
+}
+
+func nextItemNames(items []NextItem) []string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
+	}
+	return names
+}
+
+// CoverageBranch is an outcome of a node that a report expects to be covered.
+type CoverageBranch string
+
+const (
+	CoverageBranchEntered         CoverageBranch = "entered"
+	CoverageBranchRecognitionHit  CoverageBranch = "hit"
+	CoverageBranchRecognitionMiss CoverageBranch = "miss"
+	CoverageBranchActionSucceeded CoverageBranch = "action_succeeded"
+	CoverageBranchActionFailed    CoverageBranch = "action_failed"
+	CoverageBranchOnError         CoverageBranch = "on_error"
+)
+
+func (n NodeCoverage) count(branch CoverageBranch) uint64 {
+	switch branch {
+	case CoverageBranchEntered:
+		return n.Entered
+	case CoverageBranchRecognitionHit:
+		return n.RecognitionHit
+	case CoverageBranchRecognitionMiss:
+		return n.RecognitionMiss
+	case CoverageBranchActionSucceeded:
+		return n.ActionSucceeded
+	case CoverageBranchActionFailed:
+		return n.ActionFailed
+	case CoverageBranchOnError:
+		return n.OnError
+	default:
+		return 0
+	}
+}
+
+// NodeCoverageReport is the coverage of one node of a CoverageReport.
+type NodeCoverageReport struct {
+	Name     string
+	Coverage NodeCoverage
+	// Branches are the outcomes expected of the node. An action that does
+	// nothing cannot fail, and on_error is only expected if the node has one.
+	Branches []CoverageBranch
+	// Uncovered are the branches that never happened.
+	Uncovered []CoverageBranch
+}
+
+// CoverageReport compares a profile with the nodes of a resource.
+type CoverageReport struct {
+	// Nodes are the nodes of the resource in name order.
+	Nodes []NodeCoverageReport
+	// Unknown lists the nodes of the profile that the resource does not have.
+	Unknown []string
+}
+
+// NewCoverageReport reports the coverage of the nodes of res in profile.
+// Nodes ignored with WithCoverageIgnore are left out of the report.
+func NewCoverageReport(profile *CoverageProfile, res *Resource, opts ...CoverageOption) (*CoverageReport, error) {
+	cfg := coverageConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	names, err := res.GetNodeList()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*Node, 0, len(names))
+	for _, name := range names {
+		if cfg.ignore != nil && cfg.ignore.MatchString(name) {
+			continue
+		}
+		node, err := res.GetNode(name)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return newCoverageReport(profile, nodes, cfg), nil
+}
+
+func newCoverageReport(profile *CoverageProfile, nodes []*Node, cfg coverageConfig) *CoverageReport {
+	if profile == nil {
+		profile = NewCoverageProfile()
+	}
+	report := &CoverageReport{}
+	known := make(map[string]struct{}, len(nodes))
+	for _, node := range nodes {
+		known[node.Name] = struct{}{}
+
+		n := NodeCoverageReport{
+			Name:     node.Name,
+			Branches: coverageBranches(node),
+		}
+		if cov, ok := profile.Nodes[node.Name]; ok {
+			n.Coverage = *cov
+		}
+		for _, branch := range n.Branches {
+			if n.Coverage.count(branch) == 0 {
+				n.Uncovered = append(n.Uncovered, branch)
+			}
+		}
+		report.Nodes = append(report.Nodes, n)
+	}
+	slices.SortFunc(report.Nodes, func(a, b NodeCoverageReport) int { return strings.Compare(a.Name, b.Name) })
+
+	for _, name := range slices.Sorted(maps.Keys(profile.Nodes)) {
+		if _, ok := known[name]; !ok && (cfg.ignore == nil || !cfg.ignore.MatchString(name)) {
+			report.Unknown = append(report.Unknown, name)
+		}
+	}
+	return report
+}
+
+func coverageBranches(node *Node) []CoverageBranch {
+	branches := []CoverageBranch{
+		CoverageBranchEntered,
+		CoverageBranchRecognitionHit,
+		CoverageBranchRecognitionMiss,
+		CoverageBranchActionSucceeded,
+	}
+	if node.Action != nil && node.Action.Type != "" && node.Action.Type != ActionTypeDoNothing {
+		branches = append(branches, CoverageBranchActionFailed)
+	}
+	if len(node.OnError) > 0 {
+		branches = append(branches, CoverageBranchOnError)
+	}
+	return branches
+}
+
+// NodesCovered returns the number of entered nodes and the number of nodes.
+func (r *CoverageReport) NodesCovered() (covered, total int) {
+	for _, n := range r.Nodes {
+		if n.Coverage.Entered > 0 {
+			covered++
+		}
+	}
+	return covered, len(r.Nodes)
+}
+
+// BranchesCovered returns the number of covered branches and the number of
+// branches, over all nodes.
+func (r *CoverageReport) BranchesCovered() (covered, total int) {
+	for _, n := range r.Nodes {
+		total += len(n.Branches)
+		covered += len(n.Branches) - len(n.Uncovered)
+	}
+	return covered, total
+}
+
+// UncoveredNodes returns the nodes that were never entered.
+func (r *CoverageReport) UncoveredNodes() []string {
+	var names []string
+	for _, n := range r.Nodes {
+		if n.Coverage.Entered == 0 {
+			names = append(names, n.Name)
+		}
+	}
+	return names
+}
+
+// WriteText writes the report as a table with one row per node, followed by
+// the totals, the uncovered nodes and the nodes the resource does not have.
+// Counts of branches the node does not expect are shown as "-".
+func (r *CoverageReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tENTERED\tHIT\tMISS\tACTION OK\tACTION FAILED\tON_ERROR\tUNCOVERED")
+	for _, n := range r.Nodes {
+		fmt.Fprint(tw, n.Name)
+		for _, branch := range []CoverageBranch{
+			CoverageBranchEntered,
+			CoverageBranchRecognitionHit,
+			CoverageBranchRecognitionMiss,
+			CoverageBranchActionSucceeded,
+			CoverageBranchActionFailed,
+			CoverageBranchOnError,
+		} {
+			if slices.Contains(n.Branches, branch) {
+				fmt.Fprintf(tw, "\t%d", n.Coverage.count(branch))
+			} else {
+				fmt.Fprint(tw, "\t-")
+			}
+		}
+		uncovered := make([]string, len(n.Uncovered))
+		for i, branch := range n.Uncovered {
+			uncovered[i] = string(branch)
+		}
+		fmt.Fprintf(tw, "\t%s\n", strings.Join(uncovered, ","))
+	}
+
+	nodes, nodeTotal := r.NodesCovered()
+	branches, branchTotal := r.BranchesCovered()
+	fmt.Fprintf(tw, "\nnodes: %d/%d (%s)\nbranches: %d/%d (%s)\n",
+		nodes, nodeTotal, percent(nodes, nodeTotal), branches, branchTotal, percent(branches, branchTotal))
+
+	if uncovered := r.UncoveredNodes(); len(uncovered) > 0 {
+		fmt.Fprintf(tw, "\nuncovered nodes:\n  %s\n", strings.Join(uncovered, "\n  "))
+	}
+	if len(r.Unknown) > 0 {
+		fmt.Fprintf(tw, "\nnodes not in the resource:\n  %s\n", strings.Join(r.Unknown, "\n  "))
+	}
+	return tw.Flush()
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
@@ -0,0 +1,162 @@
+package maa
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+// coverageNext returns a next list in the normalized form, which the fake
+// backend returns as registered.
+func coverageNext(names ...string) []map[string]any {
+	list := make([]map[string]any, len(names))
+	for i, name := range names {
+		list[i] = map[string]any{"name": name}
+	}
+	return list
+}
+
+func TestCoverageCollector_Record(t *testing.T) {
+	fake := useFakeBackend(t)
+	fake.RunTask = func(task *maafake.Task) bool {
+		task.RunNode(maafake.Node{Name: "Start", ActionFailed: true})
+		task.NextList("Start", []string{"A"})
+		task.NextList("Start", []string{"Recover"})
+		task.NextList("Same", []string{"A"})
+		task.NextList("Unknown", []string{"A"})
+		task.RunNode(maafake.Node{Name: "Recover", Miss: true})
+		task.RunNode(maafake.Node{Name: "DebugProbe"})
+		return false
+	}
+	tasker := newFakeTasker(t, fake, map[string]any{
+		"Start":   map[string]any{"next": coverageNext("A"), "on_error": coverageNext("Recover")},
+		"Same":    map[string]any{"next": coverageNext("A"), "on_error": coverageNext("A")},
+		"A":       map[string]any{},
+		"Recover": map[string]any{},
+	})
+	c := NewCoverageCollector(WithCoverageIgnore(regexp.MustCompile(`^Debug`)))
+	c.Attach(tasker)
+
+	require.True(t, tasker.PostTask("Start").Wait().Failure())
+	p := c.Profile()
+	require.Equal(t, uint64(1), p.Tasks)
+	require.Equal(t, map[string]*NodeCoverage{
+		"Start":   {Entered: 1, RecognitionHit: 1, ActionFailed: 1, OnError: 1},
+		"Recover": {RecognitionMiss: 1},
+	}, p.Nodes)
+
+	p.Nodes["Start"].Entered = 100
+	require.Equal(t, uint64(1), c.Profile().Nodes["Start"].Entered, "Profile returns a copy")
+
+	c.Reset()
+	require.Empty(t, c.Profile().Nodes)
+}
+
+func TestCoverageCollector_OverridePerTask(t *testing.T) {
+	fake := useFakeBackend(t)
+	fake.RunTask = func(task *maafake.Task) bool {
+		task.NextList("Start", []string{"A"})
+		task.NextList("Start", []string{"Fallback"})
+		task.NextList("Start", []string{"Recover"})
+		return true
+	}
+	tasker := newFakeTasker(t, fake, map[string]any{
+		"Start": map[string]any{"next": coverageNext("A"), "on_error": coverageNext("Recover")},
+	})
+	c := NewCoverageCollector()
+	c.Attach(tasker)
+
+	// Each task reads the on_error list of Start with its own override.
+	require.True(t, tasker.PostTask("Start").Wait().Success())
+	require.Equal(t, uint64(1), c.Profile().Nodes["Start"].OnError)
+	require.True(t, tasker.PostTask("Start", map[string]any{
+		"Start": map[string]any{"on_error": coverageNext("Fallback")},
+	}).Wait().Success())
+	require.Equal(t, uint64(2), c.Profile().Nodes["Start"].OnError)
+	require.Empty(t, c.branches, "finished tasks drop their cached lists")
+
+	// Without a Context, as for an async sink, on_error is not attributed.
+	async := NewCoverageCollector()
+	async.OnNodeNextList(nil, EventStatusStarting, NodeNextListDetail{TaskID: 1, Name: "Start", List: []NextItem{{Name: "Recover"}}})
+	require.Empty(t, async.Profile().Nodes)
+}
+
+func TestCoverageProfile_MergeAndRoundTrip(t *testing.T) {
+	a := NewCoverageProfile()
+	a.Tasks = 2
+	a.Nodes["Start"] = &NodeCoverage{Entered: 2, RecognitionHit: 2, ActionSucceeded: 2}
+	b := NewCoverageProfile()
+	b.Tasks = 1
+	b.Nodes["Start"] = &NodeCoverage{Entered: 1, RecognitionHit: 1, ActionFailed: 1, OnError: 1}
+	b.Nodes["End"] = &NodeCoverage{RecognitionMiss: 3}
+
+	var buf bytes.Buffer
+	_, err := b.WriteTo(&buf)
+	require.NoError(t, err)
+	read, err := ReadCoverageProfile(&buf)
+	require.NoError(t, err)
+	require.Equal(t, b, read)
+
+	merged := a.Clone()
+	merged.Merge(read)
+	merged.Merge(nil)
+	require.Equal(t, uint64(3), merged.Tasks)
+	require.Equal(t, &NodeCoverage{Entered: 3, RecognitionHit: 3, ActionSucceeded: 2, ActionFailed: 1, OnError: 1}, merged.Nodes["Start"])
+	require.Equal(t, &NodeCoverage{RecognitionMiss: 3}, merged.Nodes["End"])
+	require.Equal(t, uint64(2), a.Nodes["Start"].Entered, "Clone does not share nodes")
+
+	_, err = ReadCoverageProfile(strings.NewReader("not json"))
+	require.Error(t, err)
+
+	// Null nodes and zero-value profiles count nothing.
+	read, err = ReadCoverageProfile(strings.NewReader(`{"tasks":1,"nodes":{"X":null,"Y":{"entered":1}}}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]*NodeCoverage{"Y": {Entered: 1}}, read.Nodes)
+	zero := &CoverageProfile{}
+	zero.Merge(&CoverageProfile{Tasks: 1, Nodes: map[string]*NodeCoverage{"X": nil, "Y": {Entered: 2}}})
+	require.Equal(t, map[string]*NodeCoverage{"Y": {Entered: 2}}, zero.Nodes)
+	clone := (&CoverageProfile{}).Clone()
+	clone.Merge(zero)
+	require.Equal(t, zero, clone)
+}
+
+func TestCoverageReport(t *testing.T) {
+	profile := NewCoverageProfile()
+	profile.Nodes["Start"] = &NodeCoverage{Entered: 2, RecognitionHit: 2, RecognitionMiss: 1, ActionSucceeded: 2}
+	profile.Nodes["Click"] = &NodeCoverage{RecognitionMiss: 4}
+	profile.Nodes["Removed"] = &NodeCoverage{Entered: 1}
+	profile.Nodes["DebugProbe"] = &NodeCoverage{Entered: 1}
+
+	report := newCoverageReport(profile, []*Node{
+		{Name: "Start", OnError: []NextItem{{Name: "Recover"}}},
+		{Name: "Click", Action: &Action{Type: ActionTypeClick}},
+		{Name: "Recover", Action: &Action{Type: ActionTypeDoNothing}},
+	}, coverageConfig{ignore: regexp.MustCompile(`^Debug`)})
+
+	require.Equal(t, []string{"Click", "Recover", "Start"}, []string{report.Nodes[0].Name, report.Nodes[1].Name, report.Nodes[2].Name})
+	require.Equal(t, []string{"Removed"}, report.Unknown)
+	require.Equal(t, []CoverageBranch{CoverageBranchOnError}, report.Nodes[2].Uncovered)
+	require.Equal(t, []CoverageBranch{
+		CoverageBranchEntered, CoverageBranchRecognitionHit, CoverageBranchActionSucceeded, CoverageBranchActionFailed,
+	}, report.Nodes[0].Uncovered)
+	require.Equal(t, []string{"Click", "Recover"}, report.UncoveredNodes())
+
+	covered, total := report.NodesCovered()
+	require.Equal(t, []int{1, 3}, []int{covered, total})
+	covered, total = report.BranchesCovered()
+	require.Equal(t, []int{5, 14}, []int{covered, total})
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteText(&buf))
+	out := buf.String()
+	require.Contains(t, out, "NODE     ENTERED  HIT  MISS  ACTION OK  ACTION FAILED  ON_ERROR  UNCOVERED\n")
+	require.Contains(t, out, "Recover  0        0    0     0          -              -         entered,hit,miss,action_succeeded\n")
+	require.Contains(t, out, "Start    2        2    1     2          -              0         on_error\n")
+	require.Contains(t, out, "nodes: 1/3 (33.3%)\nbranches: 5/14 (35.7%)\n")
+	require.Contains(t, out, "uncovered nodes:\n  Click\n  Recover\n")
+	require.Contains(t, out, "nodes not in the resource:\n  Removed\n")
+}
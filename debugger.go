@@ -0,0 +1,514 @@
+package maa
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrDebuggerNotPaused = errors.New("debugger is not paused")
+	ErrDebuggerClosed    = errors.New("debugger is closed")
+)
+
+// DebuggerCommand tells a paused Debugger how to resume.
+type DebuggerCommand int
+
+const (
+	// DebuggerContinue runs until the next breakpoint.
+	DebuggerContinue DebuggerCommand = iota
+	// DebuggerStep runs until the next pipeline node, breakpoint or not.
+	DebuggerStep
+	// DebuggerAbort stops the task with Tasker.PostStop.
+	DebuggerAbort
+)
+
+// String returns the human-readable representation of the DebuggerCommand.
+func (c DebuggerCommand) String() string {
+	switch c {
+	case DebuggerContinue:
+		return "continue"
+	case DebuggerStep:
+		return "step"
+	case DebuggerAbort:
+		return "abort"
+	default:
+		return "unknown"
+	}
+}
+
+// Breakpoint pauses a Debugger when the pipeline is about to run Node.
+type Breakpoint struct {
+	Node string
+	// MinHitCount only breaks once the hit count of the node, as reported by
+	// Context.GetHitCount when the node starts, reached it.
+	MinHitCount uint64
+	// Condition, if set, only breaks when it returns true. It receives the
+	// recognition that selected the node, which is nil if it is unknown.
+	Condition func(reco *RecognitionDetail) bool
+}
+
+// DebuggerStop describes where a Debugger is paused.
+type DebuggerStop struct {
+	TaskID   uint64
+	NodeID   uint64
+	Node     string
+	HitCount uint64
+	// Recognition is the recognition that selected the node, or nil if it is unknown.
+	Recognition *RecognitionDetail
+	// Context is the context of the paused node. It can be used to inspect the
+	// runtime state until the debugger is resumed.
+	Context *Context
+
+	resume chan DebuggerCommand
+	done   chan struct{}
+}
+
+// Resumed returns a channel that is closed once the debugger resumed from this stop.
+func (s *DebuggerStop) Resumed() <-chan struct{} {
+	return s.done
+}
+
+// Screenshot returns the latest screenshot cached by the controller.
+func (s *DebuggerStop) Screenshot() (image.Image, error) {
+	return s.Context.GetTasker().GetController().CacheImage()
+}
+
+type debuggerRecoKey struct {
+	taskID uint64
+	node   string
+}
+
+// Debugger pauses the pipeline before the nodes that have a breakpoint.
+// It is a ContextEventSink: while paused, the Node.PipelineNode Starting
+// callback is blocked, so the task does not progress until Continue, Step or
+// Abort is called.
+//
+// A Debugger pauses one node at a time; callbacks of other taskers attached to
+// the same Debugger wait for the current stop to resume.
+type Debugger struct {
+	mu          sync.Mutex
+	breakpoints map[string]Breakpoint
+	stepping    bool
+	closed      bool
+	current     *DebuggerStop
+	paused      chan struct{}
+	recos       map[debuggerRecoKey]uint64
+
+	// pause serializes the stops.
+	pause sync.Mutex
+}
+
+// NewDebugger creates a debugger with no breakpoints.
+func NewDebugger() *Debugger {
+	return &Debugger{
+		breakpoints: make(map[string]Breakpoint),
+		paused:      make(chan struct{}),
+		recos:       make(map[debuggerRecoKey]uint64),
+	}
+}
+
+// Attach registers the debugger on tasker as a context sink and returns the sink ID.
+func (d *Debugger) Attach(tasker *Tasker) int64 {
+	return tasker.AddContextSink(d)
+}
+
+// SetBreakpoint adds bp, replacing any breakpoint on the same node.
+func (d *Debugger) SetBreakpoint(bp Breakpoint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[bp.Node] = bp
+}
+
+// ClearBreakpoint removes the breakpoint on node and reports whether there was one.
+func (d *Debugger) ClearBreakpoint(node string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.breakpoints[node]
+	delete(d.breakpoints, node)
+	return ok
+}
+
+// Breakpoints returns the breakpoints sorted by node name.
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bps := make([]Breakpoint, 0, len(d.breakpoints))
+	for _, bp := range d.breakpoints {
+		bps = append(bps, bp)
+	}
+	slices.SortFunc(bps, func(a, b Breakpoint) int { return strings.Compare(a.Node, b.Node) })
+	return bps
+}
+
+// Pause makes the debugger pause before the next pipeline node, breakpoint or not.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stepping = true
+}
+
+// Paused returns the current stop, or nil if the debugger is running.
+func (d *Debugger) Paused() *DebuggerStop {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.current
+}
+
+// Wait blocks until the debugger is paused and returns the stop.
+func (d *Debugger) Wait(ctx context.Context) (*DebuggerStop, error) {
+	for {
+		d.mu.Lock()
+		current, paused, closed := d.current, d.paused, d.closed
+		d.mu.Unlock()
+		switch {
+		case current != nil:
+			return current, nil
+		case closed:
+			return nil, ErrDebuggerClosed
+		}
+		select {
+		case <-paused:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Continue resumes until the next breakpoint.
+func (d *Debugger) Continue() error {
+	return d.Resume(DebuggerContinue)
+}
+
+// Step resumes until the next pipeline node.
+func (d *Debugger) Step() error {
+	return d.Resume(DebuggerStep)
+}
+
+// Abort resumes and stops the task with Tasker.PostStop.
+func (d *Debugger) Abort() error {
+	return d.Resume(DebuggerAbort)
+}
+
+// Resume resumes the paused node with cmd.
+// It returns ErrDebuggerNotPaused if the debugger is not paused.
+func (d *Debugger) Resume(cmd DebuggerCommand) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.resumeLocked(cmd)
+}
+
+func (d *Debugger) resumeLocked(cmd DebuggerCommand) error {
+	stop := d.current
+	if stop == nil {
+		return ErrDebuggerNotPaused
+	}
+	d.current = nil
+	d.paused = make(chan struct{})
+	d.stepping = cmd == DebuggerStep
+	stop.resume <- cmd
+	close(stop.done)
+	return nil
+}
+
+// Close resumes the paused node, if any, and disables the debugger so that it
+// never pauses again.
+func (d *Debugger) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	d.resumeLocked(DebuggerContinue)
+	close(d.paused)
+}
+
+// OnNodePipelineNode implements ContextEventSink.
+func (d *Debugger) OnNodePipelineNode(ctx *Context, status EventStatus, detail NodePipelineNodeDetail) {
+	if status != EventStatusStarting {
+		return
+	}
+
+	d.mu.Lock()
+	bp, hasBreakpoint := d.breakpoints[detail.Name]
+	stepping, closed := d.stepping, d.closed
+	recoID := d.recos[debuggerRecoKey{detail.TaskID, detail.Name}]
+	// The node is entered: the recognitions of its next list are consumed.
+	for key := range d.recos {
+		if key.taskID == detail.TaskID {
+			delete(d.recos, key)
+		}
+	}
+	d.mu.Unlock()
+	if closed || (!stepping && !hasBreakpoint) {
+		return
+	}
+
+	hitCount, _ := ctx.GetHitCount(detail.Name)
+	var reco *RecognitionDetail
+	if recoID != 0 {
+		reco, _ = ctx.GetTasker().GetRecognitionDetail(int64(recoID))
+	}
+	if !stepping {
+		if hitCount < bp.MinHitCount {
+			return
+		}
+		if bp.Condition != nil && !bp.Condition(reco) {
+			return
+		}
+	}
+
+	d.pause.Lock()
+	defer d.pause.Unlock()
+
+	stop := &DebuggerStop{
+		TaskID:      detail.TaskID,
+		NodeID:      detail.NodeID,
+		Node:        detail.Name,
+		HitCount:    hitCount,
+		Recognition: reco,
+		Context:     ctx,
+		resume:      make(chan DebuggerCommand, 1),
+		done:        make(chan struct{}),
+	}
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.current = stop
+	close(d.paused)
+	d.mu.Unlock()
+
+	if <-stop.resume == DebuggerAbort {
+		ctx.GetTasker().PostStop()
+	}
+}
+
+// OnNodeRecognition implements ContextEventSink.
+func (d *Debugger) OnNodeRecognition(_ *Context, status EventStatus, detail NodeRecognitionDetail) {
+	// Only a hit leads to its node being entered.
+	if status != EventStatusSucceeded || detail.RecognitionID == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.recos[debuggerRecoKey{detail.TaskID, detail.Name}] = detail.RecognitionID
+}
+
+// OnNodeRecognitionNode implements ContextEventSink.
+func (d *Debugger) OnNodeRecognitionNode(*Context, EventStatus, NodeRecognitionNodeDetail) {}
+
+// OnNodeActionNode implements ContextEventSink.
+func (d *Debugger) OnNodeActionNode(*Context, EventStatus, NodeActionNodeDetail) {}
+
+// OnNodeNextList implements ContextEventSink.
+func (d *Debugger) OnNodeNextList(*Context, EventStatus, NodeNextListDetail) {}
+
+// OnNodeAction implements ContextEventSink.
+func (d *Debugger) OnNodeAction(*Context, EventStatus, NodeActionDetail) {}
+
+const debuggerConsoleHelp = `commands:
+  break NODE [hits N] [match TEXT]  pause before NODE, optionally once hit N times
+                                    or when its recognition detail contains TEXT
+  delete NODE                       remove the breakpoint on NODE
+  list                              list breakpoints
+  pause                             pause before the next node
+  continue | c                      resume until the next breakpoint
+  step | s                          resume until the next node
+  abort | a                         stop the task
+  info | i                          show where the debugger is paused
+  screenshot FILE                   save the latest screenshot as PNG
+  help                              show this help
+`
+
+// Console runs a line-oriented console reading commands from in and writing to
+// out, typically os.Stdin and os.Stdout. It announces every stop and returns
+// when in is exhausted or ctx is done.
+func (d *Debugger) Console(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var outMu sync.Mutex
+	printf := func(format string, args ...any) {
+		outMu.Lock()
+		defer outMu.Unlock()
+		fmt.Fprintf(out, format, args...)
+	}
+
+	go func() {
+		for {
+			stop, err := d.Wait(ctx)
+			if err != nil {
+				return
+			}
+			printf("paused at %s\n", formatDebuggerStop(stop))
+			select {
+			case <-stop.Resumed():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-scanErr:
+			return err
+		case line := <-lines:
+			if msg := d.consoleCommand(line); msg != "" {
+				printf("%s", msg)
+			}
+		}
+	}
+}
+
+func (d *Debugger) consoleCommand(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	args := fields[1:]
+
+	switch fields[0] {
+	case "break", "b":
+		bp, err := parseConsoleBreakpoint(args)
+		if err != nil {
+			return err.Error() + "\n"
+		}
+		d.SetBreakpoint(bp)
+		return ""
+	case "delete", "d":
+		if len(args) != 1 {
+			return "usage: delete NODE\n"
+		}
+		if !d.ClearBreakpoint(args[0]) {
+			return "no breakpoint on " + args[0] + "\n"
+		}
+		return ""
+	case "list", "l":
+		var b strings.Builder
+		for _, bp := range d.Breakpoints() {
+			b.WriteString(bp.Node)
+			if bp.MinHitCount > 0 {
+				fmt.Fprintf(&b, " hits %d", bp.MinHitCount)
+			}
+			if bp.Condition != nil {
+				b.WriteString(" (conditional)")
+			}
+			b.WriteByte('\n')
+		}
+		return b.String()
+	case "pause", "p":
+		d.Pause()
+		return ""
+	case "continue", "c":
+		return consoleError(d.Continue())
+	case "step", "s":
+		return consoleError(d.Step())
+	case "abort", "a":
+		return consoleError(d.Abort())
+	case "info", "i":
+		stop := d.Paused()
+		if stop == nil {
+			return "running\n"
+		}
+		return formatDebuggerStop(stop) + "\n"
+	case "screenshot":
+		if len(args) != 1 {
+			return "usage: screenshot FILE\n"
+		}
+		stop := d.Paused()
+		if stop == nil {
+			return ErrDebuggerNotPaused.Error() + "\n"
+		}
+		return consoleError(saveDebuggerScreenshot(stop, args[0]))
+	case "help", "h", "?":
+		return debuggerConsoleHelp
+	default:
+		return "unknown command " + strconv.Quote(fields[0]) + ", try help\n"
+	}
+}
+
+func consoleError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error() + "\n"
+}
+
+func parseConsoleBreakpoint(args []string) (Breakpoint, error) {
+	const usage = "usage: break NODE [hits N] [match TEXT]"
+	if len(args) == 0 || len(args)%2 != 1 {
+		return Breakpoint{}, errors.New(usage)
+	}
+	bp := Breakpoint{Node: args[0]}
+	for i := 1; i < len(args); i += 2 {
+		switch args[i] {
+		case "hits":
+			n, err := strconv.ParseUint(args[i+1], 10, 64)
+			if err != nil {
+				return Breakpoint{}, fmt.Errorf("invalid hit count %q", args[i+1])
+			}
+			bp.MinHitCount = n
+		case "match":
+			text := args[i+1]
+			bp.Condition = func(reco *RecognitionDetail) bool {
+				return reco != nil && strings.Contains(reco.DetailJson, text)
+			}
+		default:
+			return Breakpoint{}, errors.New(usage)
+		}
+	}
+	return bp, nil
+}
+
+func formatDebuggerStop(stop *DebuggerStop) string {
+	s := fmt.Sprintf("%s (task %d, node %d, hits %d)", stop.Node, stop.TaskID, stop.NodeID, stop.HitCount)
+	if reco := stop.Recognition; reco != nil {
+		s += fmt.Sprintf(" reco %d %s hit=%t box=%v", reco.ID, reco.Algorithm, reco.Hit, reco.Box)
+	}
+	return s
+}
+
+func saveDebuggerScreenshot(stop *DebuggerStop, path string) error {
+	img, err := stop.Screenshot()
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
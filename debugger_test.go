@@ -0,0 +1,218 @@
+package maa
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+// newDebuggerTasker returns a tasker on the fake backend with d attached, whose
+// tasks run nodes in order until one fails or the tasker is stopped. The
+// controller screen is a 4x4 image.
+func newDebuggerTasker(t *testing.T, d *Debugger, nodes ...maafake.Node) *Tasker {
+	t.Helper()
+	fake := useFakeBackend(t)
+	fake.Screencap = func(string) image.Image { return image.NewRGBA(image.Rect(0, 0, 4, 4)) }
+	fake.RunTask = func(task *maafake.Task) bool {
+		for _, node := range nodes {
+			if task.Stopping() || !task.RunNode(node) {
+				return false
+			}
+		}
+		return true
+	}
+	tasker := newFakeTasker(t, fake, map[string]any{"Start": map[string]any{}})
+	require.True(t, tasker.GetController().PostScreencap().Wait().Success())
+	d.Attach(tasker)
+	t.Cleanup(d.Close)
+	return tasker
+}
+
+func debuggerNodes(names ...string) []maafake.Node {
+	nodes := make([]maafake.Node, len(names))
+	for i, name := range names {
+		nodes[i] = maafake.Node{Name: name}
+	}
+	return nodes
+}
+
+func waitStop(t *testing.T, d *Debugger) *DebuggerStop {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stop, err := d.Wait(ctx)
+	require.NoError(t, err)
+	return stop
+}
+
+func TestDebugger_BreakpointStepContinue(t *testing.T) {
+	d := NewDebugger()
+	tasker := newDebuggerTasker(t, d, debuggerNodes("A", "B", "C", "D")...)
+	d.SetBreakpoint(Breakpoint{Node: "B"})
+	require.ErrorIs(t, d.Continue(), ErrDebuggerNotPaused)
+
+	job := tasker.PostTask("Start")
+
+	stop := waitStop(t, d)
+	require.Equal(t, "B", stop.Node)
+	require.Equal(t, uint64(1), stop.HitCount)
+	require.NotNil(t, stop.Recognition)
+	require.Equal(t, "B", stop.Recognition.Name)
+	node, err := tasker.GetNodeDetail(int64(stop.NodeID))
+	require.NoError(t, err)
+	require.Equal(t, stop.Recognition.ID, node.Recognition.ID)
+	img, err := stop.Screenshot()
+	require.NoError(t, err)
+	require.Equal(t, 4, img.Bounds().Dx())
+
+	require.NoError(t, d.Step())
+	<-stop.Resumed()
+	stop = waitStop(t, d)
+	require.Equal(t, "C", stop.Node)
+
+	require.NoError(t, d.Continue())
+	require.True(t, job.Wait().Success())
+	require.Nil(t, d.Paused())
+}
+
+func TestDebugger_Conditions(t *testing.T) {
+	claim := `{"text":"Claim"}`
+	d := NewDebugger()
+	tasker := newDebuggerTasker(t, d,
+		maafake.Node{Name: "A"},
+		maafake.Node{Name: "B"},
+		maafake.Node{Name: "B"},
+		maafake.Node{Name: "C", Detail: claim},
+		maafake.Node{Name: "D", Detail: claim},
+		maafake.Node{Name: "E"},
+	)
+	d.SetBreakpoint(Breakpoint{Node: "A", MinHitCount: 2})
+	d.SetBreakpoint(Breakpoint{Node: "B", MinHitCount: 2})
+	d.SetBreakpoint(Breakpoint{Node: "C", Condition: func(reco *RecognitionDetail) bool {
+		return reco != nil && strings.Contains(reco.DetailJson, "Skip")
+	}})
+	d.SetBreakpoint(Breakpoint{Node: "D", Condition: func(reco *RecognitionDetail) bool {
+		return reco != nil && strings.Contains(reco.DetailJson, "Claim")
+	}})
+
+	job := tasker.PostTask("Start")
+	stop := waitStop(t, d)
+	require.Equal(t, "B", stop.Node)
+	require.Equal(t, uint64(2), stop.HitCount)
+	require.NoError(t, d.Continue())
+	require.Equal(t, "D", waitStop(t, d).Node)
+	require.NoError(t, d.Abort())
+
+	// Abort stops the tasker before E runs.
+	require.True(t, job.Wait().Failure())
+	detail, err := job.GetDetail()
+	require.NoError(t, err)
+	var names []string
+	for _, ref := range detail.Nodes {
+		node, err := ref.GetDetail()
+		require.NoError(t, err)
+		names = append(names, node.Name)
+	}
+	require.Equal(t, []string{"A", "B", "B", "C", "D"}, names)
+}
+
+func TestDebugger_Close(t *testing.T) {
+	d := NewDebugger()
+	tasker := newDebuggerTasker(t, d, debuggerNodes("A", "B")...)
+	d.Pause()
+	job := tasker.PostTask("Start")
+	waitStop(t, d)
+	d.Close()
+	require.True(t, job.Wait().Success())
+
+	_, err := d.Wait(context.Background())
+	require.ErrorIs(t, err, ErrDebuggerClosed)
+	d.Close()
+}
+
+func TestDebugger_Console(t *testing.T) {
+	claim := maafake.Node{Name: "B", Recognition: "OCR", Detail: `{"text":"Claim"}`}
+	d := NewDebugger()
+	tasker := newDebuggerTasker(t, d, claim, claim, claim, maafake.Node{Name: "C"})
+	in, feed := io.Pipe()
+	out := &syncBuffer{}
+
+	consoleDone := make(chan error, 1)
+	go func() { consoleDone <- d.Console(context.Background(), in, out) }()
+
+	send := func(line string) {
+		_, err := io.WriteString(feed, line+"\n")
+		require.NoError(t, err)
+	}
+	send("break B hits 3 match Claim")
+	send("break C")
+	send("delete C")
+	send("delete C")
+	send("bogus")
+	send("list")
+	require.Eventually(t, func() bool { return strings.Contains(out.String(), "B hits 3 (conditional)\n") }, 5*time.Second, time.Millisecond)
+	require.Equal(t, []string{"B"}, breakpointNodes(d.Breakpoints()))
+
+	job := tasker.PostTask("Start")
+	require.Eventually(t, func() bool { return strings.Contains(out.String(), "paused at B") }, 5*time.Second, time.Millisecond)
+	stop := d.Paused()
+	require.NotNil(t, stop)
+	require.Contains(t, out.String(), fmt.Sprintf("paused at B (task %d, node %d, hits 3) reco %d OCR hit=true box=[0 0 0 0]",
+		stop.TaskID, stop.NodeID, stop.Recognition.ID))
+	send("s")
+	require.Eventually(t, func() bool { return strings.Contains(out.String(), "paused at C") }, 5*time.Second, time.Millisecond)
+	send("c")
+	require.True(t, job.Wait().Success())
+	send("c")
+
+	require.NoError(t, feed.Close())
+	require.NoError(t, <-consoleDone)
+	require.Contains(t, out.String(), "no breakpoint on C\n")
+	require.Contains(t, out.String(), `unknown command "bogus", try help`)
+	require.True(t, strings.HasSuffix(out.String(), ErrDebuggerNotPaused.Error()+"\n"))
+}
+
+func breakpointNodes(bps []Breakpoint) []string {
+	var nodes []string
+	for _, bp := range bps {
+		nodes = append(nodes, bp.Node)
+	}
+	return nodes
+}
+
+type syncBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestDebugger_ConsumesRecognitions(t *testing.T) {
+	d := NewDebugger()
+	d.OnNodeRecognition(nil, EventStatusFailed, NodeRecognitionDetail{TaskID: 1, RecognitionID: 1, Name: "Miss"})
+	d.OnNodeRecognition(nil, EventStatusSucceeded, NodeRecognitionDetail{TaskID: 1, RecognitionID: 2, Name: "A"})
+	d.OnNodeRecognition(nil, EventStatusSucceeded, NodeRecognitionDetail{TaskID: 1, RecognitionID: 3, Name: "B"})
+	d.OnNodeRecognition(nil, EventStatusSucceeded, NodeRecognitionDetail{TaskID: 2, RecognitionID: 4, Name: "A"})
+	require.Len(t, d.recos, 3)
+
+	d.OnNodePipelineNode(nil, EventStatusStarting, NodePipelineNodeDetail{TaskID: 1, NodeID: 1, Name: "A"})
+	require.Equal(t, map[debuggerRecoKey]uint64{{2, "A"}: 4}, d.recos)
+}
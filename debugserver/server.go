@@ -0,0 +1,423 @@
+// Package debugserver serves a live debugging UI for a Tasker over HTTP.
+//
+// The UI and its JSON endpoints are self-contained and use only net/http:
+//
+//	GET  /                   the web UI
+//	GET  /api/events         live framework events as Server-Sent Events
+//	GET  /api/frame          the latest Controller.CacheImage frame as PNG
+//	GET  /api/nodes          the node names of the resource
+//	GET  /api/nodes/{name}   the JSON definition of a node
+//	POST /api/tasks          post a task: {"entry": "...", "override": {...}}
+//	POST /api/stop           stop the running task
+//
+// The POST endpoints only accept same-origin requests with a Content-Type of
+// application/json, so other web pages open in the browser cannot drive the tasker.
+package debugserver
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// Tasker is the part of *maa.Tasker the server drives.
+type Tasker interface {
+	PostTask(entry string, override ...any) *maa.TaskJob
+	PostStop() *maa.TaskJob
+}
+
+// Resource is the part of *maa.Resource the server browses.
+type Resource interface {
+	GetNodeList() ([]string, error)
+	GetNodeJSON(name string) (string, error)
+}
+
+// Controller is the part of *maa.Controller the server reads frames from.
+type Controller interface {
+	CacheImage() (image.Image, error)
+}
+
+// Event is a framework event as sent on the event stream.
+type Event struct {
+	Event  string    `json:"event"`
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+	Detail any       `json:"detail"`
+}
+
+type config struct {
+	tasker     Tasker
+	resource   Resource
+	controller Controller
+	buffer     int
+}
+
+// Option configures a Server.
+type Option func(*config)
+
+// WithTasker sets the tasker tasks are posted to and stopped on.
+func WithTasker(tasker Tasker) Option {
+	return func(cfg *config) {
+		cfg.tasker = tasker
+	}
+}
+
+// WithResource sets the resource whose nodes are browsed.
+func WithResource(res Resource) Option {
+	return func(cfg *config) {
+		cfg.resource = res
+	}
+}
+
+// WithController sets the controller frames are read from.
+func WithController(ctrl Controller) Option {
+	return func(cfg *config) {
+		cfg.controller = ctrl
+	}
+}
+
+// WithEventBuffer sets how many events are queued for each event stream client.
+// Events are dropped for a client whose queue is full. Defaults to 256.
+func WithEventBuffer(n int) Option {
+	return func(cfg *config) {
+		cfg.buffer = n
+	}
+}
+
+// Server is an http.Handler serving the debug UI. It is also a sink for tasker,
+// context, controller and resource events, which it forwards to the event stream.
+// Endpoints whose target was not configured answer 404.
+type Server struct {
+	cfg config
+	mux *http.ServeMux
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	closed  chan struct{}
+	once    sync.Once
+	detach  []func()
+
+	now func() time.Time
+}
+
+// New creates a server.
+func New(opts ...Option) *Server {
+	cfg := config{buffer: 256}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if cfg.buffer <= 0 {
+		cfg.buffer = 256
+	}
+
+	s := &Server{
+		cfg:     cfg,
+		mux:     http.NewServeMux(),
+		clients: make(map[chan []byte]struct{}),
+		closed:  make(chan struct{}),
+		now:     time.Now,
+	}
+	s.mux.HandleFunc("GET /{$}", s.handleIndex)
+	s.mux.HandleFunc("GET /api/events", s.handleEvents)
+	s.mux.HandleFunc("GET /api/frame", s.handleFrame)
+	s.mux.HandleFunc("GET /api/nodes", s.handleNodes)
+	s.mux.HandleFunc("GET /api/nodes/{name}", s.handleNode)
+	s.mux.HandleFunc("POST /api/tasks", s.handlePostTask)
+	s.mux.HandleFunc("POST /api/stop", s.handleStop)
+	return s
+}
+
+// NewForTasker creates a server for tasker and its bound resource and controller,
+// and attaches it to all three. The sinks are removed by Close.
+func NewForTasker(tasker *maa.Tasker, opts ...Option) *Server {
+	res := tasker.GetResource()
+	ctrl := tasker.GetController()
+	s := New(append([]Option{WithTasker(tasker), WithResource(res), WithController(ctrl)}, opts...)...)
+	sinkID := tasker.AddSink(s)
+	contextSinkID := tasker.AddContextSink(s)
+	resSinkID := res.AddSink(s)
+	ctrlSinkID := ctrl.AddSink(s)
+	s.detach = append(s.detach, func() {
+		tasker.RemoveSink(sinkID)
+		tasker.RemoveContextSink(contextSinkID)
+		res.RemoveSink(resSinkID)
+		ctrl.RemoveSink(ctrlSinkID)
+	})
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close removes the sinks added by NewForTasker and ends every event stream.
+// Later event stream requests end immediately.
+func (s *Server) Close() {
+	s.once.Do(func() {
+		for _, fn := range s.detach {
+			fn()
+		}
+		close(s.closed)
+	})
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexHTML)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan []byte, s.cfg.buffer)
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case data := <-ch:
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+func (s *Server) handleFrame(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.controller == nil {
+		http.NotFound(w, r)
+		return
+	}
+	img, err := s.cfg.controller.CacheImage()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if img == nil || img.Bounds().Empty() {
+		http.Error(w, "no frame captured yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	png.Encode(w, img)
+}
+
+func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.resource == nil {
+		http.NotFound(w, r)
+		return
+	}
+	nodes, err := s.cfg.resource.GetNodeList()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if nodes == nil {
+		nodes = []string{}
+	}
+	slices.Sort(nodes)
+	writeJSON(w, http.StatusOK, nodes)
+}
+
+func (s *Server) handleNode(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.resource == nil {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := s.cfg.resource.GetNodeJSON(r.PathValue("name"))
+	if err != nil || data == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(data))
+}
+
+type postTaskRequest struct {
+	Entry    string          `json:"entry"`
+	Override json.RawMessage `json:"override,omitempty"`
+}
+
+func (s *Server) handlePostTask(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.tasker == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !checkPost(w, r) {
+		return
+	}
+	var req postTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Entry == "" {
+		http.Error(w, "entry is required", http.StatusBadRequest)
+		return
+	}
+
+	var override []any
+	if len(req.Override) > 0 {
+		override = append(override, []byte(req.Override))
+	}
+	if err := s.cfg.tasker.PostTask(req.Entry, override...).Error(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"entry": req.Entry})
+}
+
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.tasker == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !checkPost(w, r) {
+		return
+	}
+	if err := s.cfg.tasker.PostStop().Error(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// checkPost rejects cross-origin requests and requests that are not JSON, which
+// a page of another origin could send as a simple request without a preflight.
+func checkPost(w http.ResponseWriter, r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+		http.Error(w, "cross-origin request", http.StatusForbidden)
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			http.Error(w, "cross-origin request", http.StatusForbidden)
+			return false
+		}
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Publish sends an event to every event stream client.
+func (s *Server) Publish(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = s.now()
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.clients {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+	return nil
+}
+
+// Clients returns the number of connected event stream clients.
+func (s *Server) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+func (s *Server) publish(event maa.Event, status maa.EventStatus, detail any) {
+	s.Publish(Event{Event: event.String(), Status: status.String(), Detail: detail})
+}
+
+// OnTaskerTask implements maa.TaskerEventSink.
+func (s *Server) OnTaskerTask(_ *maa.Tasker, status maa.EventStatus, detail maa.TaskerTaskDetail) {
+	s.publish(maa.EventTaskerTask, status, detail)
+}
+
+// OnNodePipelineNode implements maa.ContextEventSink.
+func (s *Server) OnNodePipelineNode(_ *maa.Context, status maa.EventStatus, detail maa.NodePipelineNodeDetail) {
+	s.publish(maa.EventNodePipelineNode, status, detail)
+}
+
+// OnNodeRecognitionNode implements maa.ContextEventSink.
+func (s *Server) OnNodeRecognitionNode(_ *maa.Context, status maa.EventStatus, detail maa.NodeRecognitionNodeDetail) {
+	s.publish(maa.EventNodeRecognitionNode, status, detail)
+}
+
+// OnNodeActionNode implements maa.ContextEventSink.
+func (s *Server) OnNodeActionNode(_ *maa.Context, status maa.EventStatus, detail maa.NodeActionNodeDetail) {
+	s.publish(maa.EventNodeActionNode, status, detail)
+}
+
+// OnNodeNextList implements maa.ContextEventSink.
+func (s *Server) OnNodeNextList(_ *maa.Context, status maa.EventStatus, detail maa.NodeNextListDetail) {
+	s.publish(maa.EventNodeNextList, status, detail)
+}
+
+// OnNodeRecognition implements maa.ContextEventSink.
+func (s *Server) OnNodeRecognition(_ *maa.Context, status maa.EventStatus, detail maa.NodeRecognitionDetail) {
+	s.publish(maa.EventNodeRecognition, status, detail)
+}
+
+// OnNodeAction implements maa.ContextEventSink.
+func (s *Server) OnNodeAction(_ *maa.Context, status maa.EventStatus, detail maa.NodeActionDetail) {
+	s.publish(maa.EventNodeAction, status, detail)
+}
+
+// OnControllerAction implements maa.ControllerEventSink.
+func (s *Server) OnControllerAction(_ *maa.Controller, status maa.EventStatus, detail maa.ControllerActionDetail) {
+	s.publish(maa.EventControllerAction, status, detail)
+}
+
+// OnResourceLoading implements maa.ResourceEventSink.
+func (s *Server) OnResourceLoading(_ *maa.Resource, status maa.EventStatus, detail maa.ResourceLoadingDetail) {
+	s.publish(maa.EventResourceLoading, status, detail)
+}
@@ -0,0 +1,270 @@
+package debugserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTasker struct {
+	entries   []string
+	overrides []string
+	stops     int
+}
+
+func (f *fakeTasker) PostTask(entry string, override ...any) *maa.TaskJob {
+	f.entries = append(f.entries, entry)
+	for _, o := range override {
+		f.overrides = append(f.overrides, string(o.([]byte)))
+	}
+	return &maa.TaskJob{}
+}
+
+func (f *fakeTasker) PostStop() *maa.TaskJob {
+	f.stops++
+	return &maa.TaskJob{}
+}
+
+type fakeResource map[string]string
+
+func (f fakeResource) GetNodeList() ([]string, error) {
+	var names []string
+	for name := range f {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (f fakeResource) GetNodeJSON(name string) (string, error) {
+	if data, ok := f[name]; ok {
+		return data, nil
+	}
+	return "", errors.New("failed to get node")
+}
+
+type fakeController struct {
+	img image.Image
+}
+
+func (f *fakeController) CacheImage() (image.Image, error) {
+	if f.img == nil {
+		return nil, errors.New("failed to get cached image")
+	}
+	return f.img, nil
+}
+
+func TestServer_Endpoints(t *testing.T) {
+	tasker := &fakeTasker{}
+	ctrl := &fakeController{}
+	s := New(
+		WithTasker(tasker),
+		WithResource(fakeResource{"Start": `{"next":["Menu"]}`, "Menu": `{}`}),
+		WithController(ctrl),
+	)
+	defer s.Close()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	get := func(path string) (*http.Response, string) {
+		res, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, string(body)
+	}
+	post := func(path, body string) *http.Response {
+		res, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		res.Body.Close()
+		return res
+	}
+
+	res, body := get("/")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Contains(t, body, `new EventSource("api/events")`)
+	require.NotContains(t, body, "http://")
+	require.NotContains(t, body, "https://")
+
+	_, body = get("/api/nodes")
+	require.JSONEq(t, `["Menu","Start"]`, body)
+	res, body = get("/api/nodes/Start")
+	require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	require.JSONEq(t, `{"next":["Menu"]}`, body)
+	res, _ = get("/api/nodes/Missing")
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, _ = get("/api/frame")
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	frame := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	frame.Set(1, 0, color.RGBA{R: 255, A: 255})
+	ctrl.img = frame
+	res, body = get("/api/frame")
+	require.Equal(t, "image/png", res.Header.Get("Content-Type"))
+	decoded, err := png.Decode(strings.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, color.RGBA{R: 255, A: 255}, color.RGBAModel.Convert(decoded.At(1, 0)))
+
+	require.Equal(t, http.StatusAccepted, post("/api/tasks", `{"entry":"Start","override":{"Start":{"enabled":false}}}`).StatusCode)
+	require.Equal(t, http.StatusAccepted, post("/api/tasks", `{"entry":"Menu"}`).StatusCode)
+	require.Equal(t, http.StatusBadRequest, post("/api/tasks", `{}`).StatusCode)
+	require.Equal(t, http.StatusBadRequest, post("/api/tasks", `not json`).StatusCode)
+	require.Equal(t, []string{"Start", "Menu"}, tasker.entries)
+	require.Equal(t, []string{`{"Start":{"enabled":false}}`}, tasker.overrides)
+
+	require.Equal(t, http.StatusAccepted, post("/api/stop", ``).StatusCode)
+	require.Equal(t, 1, tasker.stops)
+	res, _ = get("/api/stop")
+	require.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+}
+
+func TestServer_RejectsCrossSitePosts(t *testing.T) {
+	tasker := &fakeTasker{}
+	s := New(WithTasker(tasker))
+	defer s.Close()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	post := func(path, contentType string, header map[string]string) int {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(`{"entry":"Start"}`))
+		require.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	for _, path := range []string{"/api/tasks", "/api/stop"} {
+		// Simple requests a form or a no-cors fetch of another page can send.
+		require.Equal(t, http.StatusUnsupportedMediaType, post(path, "", nil), path)
+		require.Equal(t, http.StatusUnsupportedMediaType, post(path, "text/plain", nil), path)
+		require.Equal(t, http.StatusUnsupportedMediaType, post(path, "application/x-www-form-urlencoded", nil), path)
+		require.Equal(t, http.StatusForbidden, post(path, "application/json", map[string]string{"Origin": "https://evil.example"}), path)
+		require.Equal(t, http.StatusForbidden, post(path, "application/json", map[string]string{"Sec-Fetch-Site": "cross-site"}), path)
+	}
+	require.Empty(t, tasker.entries)
+	require.Zero(t, tasker.stops)
+
+	// The UI sends same-origin JSON requests.
+	sameOrigin := map[string]string{"Origin": srv.URL, "Sec-Fetch-Site": "same-origin"}
+	require.Equal(t, http.StatusAccepted, post("/api/tasks", "application/json; charset=utf-8", sameOrigin))
+	require.Equal(t, http.StatusAccepted, post("/api/stop", "application/json", sameOrigin))
+	require.Equal(t, []string{"Start"}, tasker.entries)
+	require.Equal(t, 1, tasker.stops)
+}
+
+func TestServer_MissingTargets(t *testing.T) {
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	for _, path := range []string{"/api/nodes", "/api/nodes/A", "/api/frame"} {
+		res, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusNotFound, res.StatusCode, path)
+	}
+	res, err := http.Post(srv.URL+"/api/tasks", "application/json", strings.NewReader(`{"entry":"A"}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestServer_EventStream(t *testing.T) {
+	s := New()
+	s.now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/api/events")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool { return s.Clients() == 1 }, 5*time.Second, time.Millisecond)
+
+	s.OnTaskerTask(nil, maa.EventStatusStarting, maa.TaskerTaskDetail{TaskID: 1, Entry: "Start"})
+	s.OnNodePipelineNode(nil, maa.EventStatusSucceeded, maa.NodePipelineNodeDetail{TaskID: 1, NodeID: 2, Name: "Start"})
+
+	reader := bufio.NewReader(res.Body)
+	var events []Event
+	for len(events) < 2 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+		if !ok {
+			continue
+		}
+		var event Event
+		require.NoError(t, json.Unmarshal([]byte(data), &event))
+		events = append(events, event)
+	}
+	require.Equal(t, "Tasker.Task", events[0].Event)
+	require.Equal(t, "starting", events[0].Status)
+	require.Equal(t, "Start", events[0].Detail.(map[string]any)["entry"])
+	require.Equal(t, "Node.PipelineNode", events[1].Event)
+	require.Equal(t, "succeeded", events[1].Status)
+	require.Equal(t, 2.0, events[1].Detail.(map[string]any)["node_id"])
+	require.Equal(t, s.now(), events[1].Time)
+
+	s.Close()
+	_, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return s.Clients() == 0 }, 5*time.Second, time.Millisecond)
+}
+
+func TestNewForTasker_CloseRemovesSinks(t *testing.T) {
+	fake := maafake.New()
+	require.NoError(t, maa.Init(maa.WithNativeBackend(fake)))
+	defer maa.Release()
+	require.NoError(t, fake.AddBundle("bundle", map[string]any{"Start": map[string]any{}}))
+
+	res, err := maa.NewResource()
+	require.NoError(t, err)
+	defer res.Destroy()
+	ctrl, err := maa.NewBlankController()
+	require.NoError(t, err)
+	defer ctrl.Destroy()
+	tasker, err := maa.NewTasker()
+	require.NoError(t, err)
+	defer tasker.Destroy()
+	require.NoError(t, tasker.BindResource(res))
+	require.NoError(t, tasker.BindController(ctrl))
+
+	s := NewForTasker(tasker)
+	var events atomic.Int32
+	s.now = func() time.Time {
+		events.Add(1)
+		return time.Time{}
+	}
+	run := func() {
+		require.True(t, res.PostBundle("bundle").Wait().Success())
+		require.True(t, ctrl.PostConnect().Wait().Success())
+		require.True(t, tasker.PostTask("Start").Wait().Success())
+	}
+
+	run()
+	require.NotZero(t, events.Load())
+	s.Close()
+	events.Store(0)
+	run()
+	require.Zero(t, events.Load())
+}
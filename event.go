@@ -1,6 +1,7 @@
 package maa
 
 import (
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -10,22 +11,29 @@ import (
 type eventCallback struct {
 	id   uint64
 	sink any
+	// queue is set for sinks added with WithSinkAsync.
+	queue *sinkQueue
 }
 
 var (
 	lastestEventCallbackID uint64
-	eventCallbacks         = make(map[uint64]eventCallback)
+	eventCallbacks         = make(map[uint64]*eventCallback)
 	eventCallbacksMutex    sync.RWMutex
 )
 
-func registerEventCallback(sink any) uint64 {
+func registerEventCallback(sink any, opts ...SinkOption) uint64 {
 	id := atomic.AddUint64(&lastestEventCallbackID, 1)
 
-	eventCallbacksMutex.Lock()
-	eventCallbacks[id] = eventCallback{
+	cb := &eventCallback{
 		id:   id,
 		sink: sink,
 	}
+	if cfg := newSinkConfig(opts); cfg.async && sink != nil {
+		cb.queue = newSinkQueue(cb, cfg)
+	}
+
+	eventCallbacksMutex.Lock()
+	eventCallbacks[id] = cb
 	eventCallbacksMutex.Unlock()
 
 	return id
@@ -33,8 +41,13 @@ func registerEventCallback(sink any) uint64 {
 
 func unregisterEventCallback(id uint64) {
 	eventCallbacksMutex.Lock()
+	cb := eventCallbacks[id]
 	delete(eventCallbacks, id)
 	eventCallbacksMutex.Unlock()
+
+	if cb != nil && cb.queue != nil {
+		cb.queue.close()
+	}
 }
 
 type Event string
@@ -65,6 +78,7 @@ const (
 	EventNodeNextList        = Event("Node.NextList")
 	EventNodeRecognition     = Event("Node.Recognition")
 	EventNodeAction          = Event("Node.Action")
+	EventNodeWaitFreezes     = Event("Node.WaitFreezes")
 )
 
 // EventStatus represents the current state of an event
@@ -78,6 +92,20 @@ const (
 	EventStatusFailed
 )
 
+// String returns the human-readable representation of the EventStatus.
+func (s EventStatus) String() string {
+	switch s {
+	case EventStatusStarting:
+		return "starting"
+	case EventStatusSucceeded:
+		return "succeeded"
+	case EventStatusFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
 // ResourceLoadingDetail contains information about resource loading events
 type ResourceLoadingDetail struct {
 	ResID uint64 `json:"res_id"`
@@ -150,6 +178,18 @@ type NodeActionDetail struct {
 	Focus    any    `json:"focus"`
 }
 
+// NodeWaitFreezesDetail contains information about node wait-freezes events.
+// Pass WaitFreezesID to Tasker.GetWaitFreezesDetail for the elapsed time,
+// recognitions and ROI.
+type NodeWaitFreezesDetail struct {
+	TaskID        uint64 `json:"task_id"`
+	WaitFreezesID uint64 `json:"wf_id"`
+	Name          string `json:"name"`
+	// Phase is "pre", "post" or "repeat".
+	Phase string `json:"phase"`
+	Focus any    `json:"focus"`
+}
+
 func parseEvent(msg string) (name string, status EventStatus) {
 	lastDot := strings.LastIndexByte(msg, '.')
 
@@ -211,6 +251,15 @@ func handleTaskerTask(sink any, handle uintptr, status EventStatus, detailsJSON
 	s.OnTaskerTask(&Tasker{handle: handle}, status, detail)
 }
 
+// eventContext returns the Context of a Node event, or nil for the events of
+// async sinks, which are handled after the context is gone.
+func eventContext(handle uintptr) *Context {
+	if handle == 0 {
+		return nil
+	}
+	return &Context{handle: handle}
+}
+
 func handleNodePipelineNode(sink any, handle uintptr, status EventStatus, detailsJSON []byte) {
 	s, ok := sink.(ContextEventSink)
 	if !ok {
@@ -222,7 +271,7 @@ func handleNodePipelineNode(sink any, handle uintptr, status EventStatus, detail
 		return
 	}
 
-	s.OnNodePipelineNode(&Context{handle: handle}, status, detail)
+	s.OnNodePipelineNode(eventContext(handle), status, detail)
 }
 
 func handleNodeRecognitionNode(sink any, handle uintptr, status EventStatus, detailsJSON []byte) {
@@ -236,7 +285,7 @@ func handleNodeRecognitionNode(sink any, handle uintptr, status EventStatus, det
 		return
 	}
 
-	s.OnNodeRecognitionNode(&Context{handle: handle}, status, detail)
+	s.OnNodeRecognitionNode(eventContext(handle), status, detail)
 }
 
 func handleNodeActionNode(sink any, handle uintptr, status EventStatus, detailsJSON []byte) {
@@ -250,7 +299,7 @@ func handleNodeActionNode(sink any, handle uintptr, status EventStatus, detailsJ
 		return
 	}
 
-	s.OnNodeActionNode(&Context{handle: handle}, status, detail)
+	s.OnNodeActionNode(eventContext(handle), status, detail)
 }
 
 func handleNodeNextList(sink any, handle uintptr, status EventStatus, detailsJSON []byte) {
@@ -264,7 +313,7 @@ func handleNodeNextList(sink any, handle uintptr, status EventStatus, detailsJSO
 		return
 	}
 
-	s.OnNodeNextList(&Context{handle: handle}, status, detail)
+	s.OnNodeNextList(eventContext(handle), status, detail)
 }
 
 func handleNodeRecognition(sink any, handle uintptr, status EventStatus, detailsJSON []byte) {
@@ -278,7 +327,7 @@ func handleNodeRecognition(sink any, handle uintptr, status EventStatus, details
 		return
 	}
 
-	s.OnNodeRecognition(&Context{handle: handle}, status, detail)
+	s.OnNodeRecognition(eventContext(handle), status, detail)
 }
 
 func handleNodeAction(sink any, handle uintptr, status EventStatus, detailsJSON []byte) {
@@ -292,7 +341,21 @@ func handleNodeAction(sink any, handle uintptr, status EventStatus, detailsJSON
 		return
 	}
 
-	s.OnNodeAction(&Context{handle: handle}, status, detail)
+	s.OnNodeAction(eventContext(handle), status, detail)
+}
+
+func handleNodeWaitFreezes(sink any, handle uintptr, status EventStatus, detailsJSON []byte) {
+	s, ok := sink.(NodeWaitFreezesEventSink)
+	if !ok {
+		return
+	}
+
+	var detail NodeWaitFreezesDetail
+	if err := unmarshalJSON(detailsJSON, &detail); err != nil {
+		return
+	}
+
+	s.OnNodeWaitFreezes(eventContext(handle), status, detail)
 }
 
 func (c *eventCallback) handleRaw(handle uintptr, msg string, detailsJSON []byte) {
@@ -325,6 +388,9 @@ func (c *eventCallback) handleRaw(handle uintptr, msg string, detailsJSON []byte
 	case EventNodeAction:
 		handleNodeAction(c.sink, handle, eventStatus, detailsJSON)
 
+	case EventNodeWaitFreezes:
+		handleNodeWaitFreezes(c.sink, handle, eventStatus, detailsJSON)
+
 	default:
 		// do nothing
 	}
@@ -348,6 +414,21 @@ func _MaaEventCallbackAgent(handle uintptr, message, detailsJson *byte, transArg
 		return 0
 	}
 
+	if cb.queue != nil {
+		// The strings are owned by the framework and only valid during this call.
+		ev := sinkEvent{
+			handle:  handle,
+			msg:     cStringToString(message),
+			details: slices.Clone(cStringToBytes(detailsJson)),
+		}
+		// So is the context of Node events: async context sinks get a nil Context.
+		if strings.HasPrefix(ev.msg, "Node.") {
+			ev.handle = 0
+		}
+		cb.queue.push(ev)
+		return 0
+	}
+
 	cb.handleRaw(
 		handle,
 		// Event message is consumed immediately in this stack frame.
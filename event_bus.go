@@ -0,0 +1,393 @@
+package maa
+
+import (
+	"regexp"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what happens to an event when a bounded buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest discards the event that does not fit.
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered event to make room.
+	OverflowDropOldest
+	// OverflowBlock waits until there is room. It holds up the framework
+	// callback, and with it the task, until the consumer catches up.
+	OverflowBlock
+)
+
+// String returns the human-readable representation of the OverflowPolicy.
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropNewest:
+		return "drop_newest"
+	case OverflowDropOldest:
+		return "drop_oldest"
+	case OverflowBlock:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// BusEvent is an event delivered by an EventBus.
+//
+// Detail holds the typed detail of the event, e.g. NodePipelineNodeDetail for
+// EventNodePipelineNode. The Context of context events is not carried, since
+// it is only valid during the framework callback.
+type BusEvent struct {
+	Event  Event
+	Status EventStatus
+	Time   time.Time
+	// TaskID is the task of tasker and node events, or 0.
+	TaskID uint64
+	// Name is the node name of node events and the entry of tasker events.
+	Name   string
+	Detail any
+}
+
+type subscriptionConfig struct {
+	events   []Event
+	statuses []EventStatus
+	taskID   uint64
+	name     *regexp.Regexp
+	buffer   int
+	overflow OverflowPolicy
+}
+
+// SubscriptionOption configures an EventBus subscription.
+type SubscriptionOption func(*subscriptionConfig)
+
+// WithSubscriptionEvents only delivers the given event types.
+func WithSubscriptionEvents(events ...Event) SubscriptionOption {
+	return func(cfg *subscriptionConfig) {
+		cfg.events = append(cfg.events, events...)
+	}
+}
+
+// WithSubscriptionStatus only delivers events with one of the given statuses.
+func WithSubscriptionStatus(statuses ...EventStatus) SubscriptionOption {
+	return func(cfg *subscriptionConfig) {
+		cfg.statuses = append(cfg.statuses, statuses...)
+	}
+}
+
+// WithSubscriptionTaskID only delivers the tasker and node events of task id.
+func WithSubscriptionTaskID(id uint64) SubscriptionOption {
+	return func(cfg *subscriptionConfig) {
+		cfg.taskID = id
+	}
+}
+
+// WithSubscriptionNodeName only delivers node events whose node name matches pattern.
+func WithSubscriptionNodeName(pattern *regexp.Regexp) SubscriptionOption {
+	return func(cfg *subscriptionConfig) {
+		cfg.name = pattern
+	}
+}
+
+// WithSubscriptionBuffer sets the channel capacity of the subscription. It defaults to 64.
+func WithSubscriptionBuffer(size int) SubscriptionOption {
+	return func(cfg *subscriptionConfig) {
+		cfg.buffer = max(size, 0)
+	}
+}
+
+// WithSubscriptionOverflow sets what happens when the subscription buffer is full.
+// It defaults to OverflowDropNewest.
+func WithSubscriptionOverflow(policy OverflowPolicy) SubscriptionOption {
+	return func(cfg *subscriptionConfig) {
+		cfg.overflow = policy
+	}
+}
+
+const defaultSubscriptionBuffer = 64
+
+func (cfg *subscriptionConfig) match(ev BusEvent) bool {
+	if len(cfg.events) > 0 && !slices.Contains(cfg.events, ev.Event) {
+		return false
+	}
+	if len(cfg.statuses) > 0 && !slices.Contains(cfg.statuses, ev.Status) {
+		return false
+	}
+	if cfg.taskID != 0 && ev.TaskID != cfg.taskID {
+		return false
+	}
+	if cfg.name != nil && (!isNodeEvent(ev.Event) || !cfg.name.MatchString(ev.Name)) {
+		return false
+	}
+	return true
+}
+
+func isNodeEvent(event Event) bool {
+	switch event {
+	case EventNodePipelineNode, EventNodeRecognitionNode, EventNodeActionNode,
+		EventNodeNextList, EventNodeRecognition, EventNodeAction, EventNodeWaitFreezes:
+		return true
+	}
+	return false
+}
+
+// Subscription is a filtered stream of events from an EventBus.
+type Subscription struct {
+	bus *EventBus
+	cfg subscriptionConfig
+	ch  chan BusEvent
+
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+
+	// mu serializes sends with closing ch.
+	mu     sync.Mutex
+	closed bool
+}
+
+// Events returns the channel events are delivered on. It is closed by
+// Unsubscribe and by closing the bus.
+func (s *Subscription) Events() <-chan BusEvent {
+	return s.ch
+}
+
+// Dropped returns the number of events discarded because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe stops delivery and closes the events channel. A publisher
+// blocked on the subscription is released. It is safe to call more than once.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		if s.bus != nil {
+			s.bus.remove(s)
+		}
+		s.mu.Lock()
+		s.closed = true
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
+
+func (s *Subscription) deliver(ev BusEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- ev:
+		return
+	default:
+	}
+
+	switch s.cfg.overflow {
+	case OverflowBlock:
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+		}
+	case OverflowDropOldest:
+		// Only deliver sends on ch and it holds mu, so after taking one event
+		// out there is room for ev.
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+		default:
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			s.dropped.Add(1)
+		}
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// EventBus registers one sink on each attached tasker, controller and resource
+// and fans their events out to subscribers over channels.
+//
+// It implements TaskerEventSink, ContextEventSink, NodeWaitFreezesEventSink,
+// ControllerEventSink and ResourceEventSink.
+type EventBus struct {
+	mu     sync.RWMutex
+	subs   []*Subscription
+	detach []func()
+	closed bool
+	now    func() time.Time
+}
+
+// NewEventBus creates an event bus with no attached sources.
+func NewEventBus() *EventBus {
+	return &EventBus{now: time.Now}
+}
+
+// AttachTasker registers the bus as a tasker and context sink of tasker.
+// The sinks are removed by Close.
+func (b *EventBus) AttachTasker(tasker *Tasker) {
+	sinkID := tasker.AddSink(b)
+	contextSinkID := tasker.AddContextSink(b)
+	b.addDetach(func() {
+		tasker.RemoveSink(sinkID)
+		tasker.RemoveContextSink(contextSinkID)
+	})
+}
+
+// AttachController registers the bus as a sink of ctrl. The sink is removed by Close.
+func (b *EventBus) AttachController(ctrl *Controller) {
+	sinkID := ctrl.AddSink(b)
+	b.addDetach(func() { ctrl.RemoveSink(sinkID) })
+}
+
+// AttachResource registers the bus as a sink of res. The sink is removed by Close.
+func (b *EventBus) AttachResource(res *Resource) {
+	sinkID := res.AddSink(b)
+	b.addDetach(func() { res.RemoveSink(sinkID) })
+}
+
+func (b *EventBus) addDetach(fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.detach = append(b.detach, fn)
+}
+
+// Subscribe returns a subscription to the events matching opts.
+// Without options every event is delivered.
+// Subscribing to a closed bus returns a subscription whose channel is closed.
+func (b *EventBus) Subscribe(opts ...SubscriptionOption) *Subscription {
+	cfg := subscriptionConfig{buffer: defaultSubscriptionBuffer}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	sub := &Subscription{
+		bus:  b,
+		cfg:  cfg,
+		ch:   make(chan BusEvent, cfg.buffer),
+		done: make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		sub.Unsubscribe()
+		return sub
+	}
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *EventBus) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = slices.DeleteFunc(b.subs, func(s *Subscription) bool { return s == sub })
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *EventBus) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Close removes the sinks of the bus and ends every subscription.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	detach, subs := b.detach, b.subs
+	b.detach, b.subs = nil, nil
+	b.mu.Unlock()
+
+	for _, fn := range detach {
+		fn()
+	}
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+}
+
+func (b *EventBus) publish(event Event, status EventStatus, taskID uint64, name string, detail any) {
+	b.mu.RLock()
+	subs := slices.Clone(b.subs)
+	b.mu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	ev := BusEvent{
+		Event:  event,
+		Status: status,
+		Time:   b.now(),
+		TaskID: taskID,
+		Name:   name,
+		Detail: detail,
+	}
+	for _, sub := range subs {
+		if sub.cfg.match(ev) {
+			sub.deliver(ev)
+		}
+	}
+}
+
+// OnTaskerTask implements TaskerEventSink.
+func (b *EventBus) OnTaskerTask(_ *Tasker, status EventStatus, detail TaskerTaskDetail) {
+	b.publish(EventTaskerTask, status, detail.TaskID, detail.Entry, detail)
+}
+
+// OnNodePipelineNode implements ContextEventSink.
+func (b *EventBus) OnNodePipelineNode(_ *Context, status EventStatus, detail NodePipelineNodeDetail) {
+	b.publish(EventNodePipelineNode, status, detail.TaskID, detail.Name, detail)
+}
+
+// OnNodeRecognitionNode implements ContextEventSink.
+func (b *EventBus) OnNodeRecognitionNode(_ *Context, status EventStatus, detail NodeRecognitionNodeDetail) {
+	b.publish(EventNodeRecognitionNode, status, detail.TaskID, detail.Name, detail)
+}
+
+// OnNodeActionNode implements ContextEventSink.
+func (b *EventBus) OnNodeActionNode(_ *Context, status EventStatus, detail NodeActionNodeDetail) {
+	b.publish(EventNodeActionNode, status, detail.TaskID, detail.Name, detail)
+}
+
+// OnNodeNextList implements ContextEventSink.
+func (b *EventBus) OnNodeNextList(_ *Context, status EventStatus, detail NodeNextListDetail) {
+	b.publish(EventNodeNextList, status, detail.TaskID, detail.Name, detail)
+}
+
+// OnNodeRecognition implements ContextEventSink.
+func (b *EventBus) OnNodeRecognition(_ *Context, status EventStatus, detail NodeRecognitionDetail) {
+	b.publish(EventNodeRecognition, status, detail.TaskID, detail.Name, detail)
+}
+
+// OnNodeAction implements ContextEventSink.
+func (b *EventBus) OnNodeAction(_ *Context, status EventStatus, detail NodeActionDetail) {
+	b.publish(EventNodeAction, status, detail.TaskID, detail.Name, detail)
+}
+
+// OnNodeWaitFreezes implements NodeWaitFreezesEventSink.
+func (b *EventBus) OnNodeWaitFreezes(_ *Context, status EventStatus, detail NodeWaitFreezesDetail) {
+	b.publish(EventNodeWaitFreezes, status, detail.TaskID, detail.Name, detail)
+}
+
+// OnControllerAction implements ControllerEventSink.
+func (b *EventBus) OnControllerAction(_ *Controller, status EventStatus, detail ControllerActionDetail) {
+	b.publish(EventControllerAction, status, 0, "", detail)
+}
+
+// OnResourceLoading implements ResourceEventSink.
+func (b *EventBus) OnResourceLoading(_ *Resource, status EventStatus, detail ResourceLoadingDetail) {
+	b.publish(EventResourceLoading, status, 0, "", detail)
+}
@@ -0,0 +1,141 @@
+package maa
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func drainBusEvents(sub *Subscription) []BusEvent {
+	var events []BusEvent
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func TestEventBus_Filters(t *testing.T) {
+	bus := NewEventBus()
+	all := bus.Subscribe()
+	nodes := bus.Subscribe(
+		WithSubscriptionEvents(EventNodePipelineNode),
+		WithSubscriptionStatus(EventStatusSucceeded),
+		WithSubscriptionNodeName(regexp.MustCompile(`^Click`)),
+	)
+	task := bus.Subscribe(WithSubscriptionTaskID(2))
+
+	bus.OnTaskerTask(nil, EventStatusStarting, TaskerTaskDetail{TaskID: 1, Entry: "Start"})
+	bus.OnNodePipelineNode(nil, EventStatusStarting, NodePipelineNodeDetail{TaskID: 1, Name: "ClickA"})
+	bus.OnNodePipelineNode(nil, EventStatusSucceeded, NodePipelineNodeDetail{TaskID: 1, Name: "ClickA"})
+	bus.OnNodePipelineNode(nil, EventStatusSucceeded, NodePipelineNodeDetail{TaskID: 2, Name: "Other"})
+	bus.OnNodeRecognition(nil, EventStatusSucceeded, NodeRecognitionDetail{TaskID: 2, Name: "ClickB"})
+	bus.OnNodeWaitFreezes(nil, EventStatusSucceeded, NodeWaitFreezesDetail{TaskID: 2, Name: "Other", Phase: "post"})
+	bus.OnControllerAction(nil, EventStatusSucceeded, ControllerActionDetail{Action: "click"})
+	bus.OnResourceLoading(nil, EventStatusSucceeded, ResourceLoadingDetail{Path: "res"})
+
+	require.Len(t, drainBusEvents(all), 8)
+
+	got := drainBusEvents(nodes)
+	require.Len(t, got, 1)
+	require.Equal(t, "ClickA", got[0].Name)
+	detail, ok := got[0].Detail.(NodePipelineNodeDetail)
+	require.True(t, ok)
+	require.Equal(t, uint64(1), detail.TaskID)
+
+	got = drainBusEvents(task)
+	require.Len(t, got, 3)
+	require.Equal(t, EventNodePipelineNode, got[0].Event)
+	require.Equal(t, EventNodeRecognition, got[1].Event)
+	require.Equal(t, EventNodeWaitFreezes, got[2].Event)
+	require.Equal(t, "Other", got[2].Name)
+}
+
+func TestEventBus_NodeNameMatchesWaitFreezes(t *testing.T) {
+	bus := NewEventBus()
+	sub := bus.Subscribe(WithSubscriptionNodeName(regexp.MustCompile(`^Swipe`)))
+
+	bus.OnNodeWaitFreezes(nil, EventStatusStarting, NodeWaitFreezesDetail{TaskID: 1, WaitFreezesID: 3, Name: "Swipe", Phase: "pre"})
+	bus.OnNodeWaitFreezes(nil, EventStatusStarting, NodeWaitFreezesDetail{TaskID: 1, WaitFreezesID: 4, Name: "Other", Phase: "pre"})
+
+	got := drainBusEvents(sub)
+	require.Len(t, got, 1)
+	require.EqualValues(t, 3, got[0].Detail.(NodeWaitFreezesDetail).WaitFreezesID)
+}
+
+func TestEventBus_Overflow(t *testing.T) {
+	bus := NewEventBus()
+	newest := bus.Subscribe(WithSubscriptionBuffer(2))
+	oldest := bus.Subscribe(WithSubscriptionBuffer(2), WithSubscriptionOverflow(OverflowDropOldest))
+
+	for i := range 4 {
+		bus.OnTaskerTask(nil, EventStatusStarting, TaskerTaskDetail{TaskID: uint64(i + 1)})
+	}
+
+	got := drainBusEvents(newest)
+	require.Equal(t, []uint64{1, 2}, []uint64{got[0].TaskID, got[1].TaskID})
+	require.Equal(t, uint64(2), newest.Dropped())
+
+	got = drainBusEvents(oldest)
+	require.Equal(t, []uint64{3, 4}, []uint64{got[0].TaskID, got[1].TaskID})
+	require.Equal(t, uint64(2), oldest.Dropped())
+}
+
+func TestEventBus_BlockUntilUnsubscribed(t *testing.T) {
+	bus := NewEventBus()
+	sub := bus.Subscribe(WithSubscriptionBuffer(1), WithSubscriptionOverflow(OverflowBlock))
+
+	bus.OnTaskerTask(nil, EventStatusStarting, TaskerTaskDetail{TaskID: 1})
+	published := make(chan struct{})
+	go func() {
+		bus.OnTaskerTask(nil, EventStatusSucceeded, TaskerTaskDetail{TaskID: 1})
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("publisher did not block on a full subscription")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ev := <-sub.Events()
+	require.Equal(t, EventStatusStarting, ev.Status)
+	<-published
+	ev = <-sub.Events()
+	require.Equal(t, EventStatusSucceeded, ev.Status)
+
+	bus.OnTaskerTask(nil, EventStatusStarting, TaskerTaskDetail{TaskID: 2})
+	go func() {
+		bus.OnTaskerTask(nil, EventStatusSucceeded, TaskerTaskDetail{TaskID: 2})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	require.Equal(t, 0, bus.Subscribers())
+}
+
+func TestEventBus_Close(t *testing.T) {
+	bus := NewEventBus()
+	detached := 0
+	bus.addDetach(func() { detached++ })
+	sub := bus.Subscribe()
+
+	bus.Close()
+	bus.Close()
+	require.Equal(t, 1, detached)
+	_, ok := <-sub.Events()
+	require.False(t, ok)
+
+	late := bus.Subscribe()
+	_, ok = <-late.Events()
+	require.False(t, ok)
+	bus.OnTaskerTask(nil, EventStatusStarting, TaskerTaskDetail{})
+}
@@ -51,6 +51,43 @@ func Initialize(libDir string) error {
 	return nil
 }
 
+// Backend provides the functions of this package in place of the MaaFramework
+// libraries, for example an in-process fake for tests.
+type Backend interface {
+	// Install assigns the function variables the backend implements.
+	// Variables it leaves nil panic when called, as they do before Initialize.
+	Install() error
+	// Uninstall is called by Shutdown before every function variable is cleared.
+	Uninstall() error
+}
+
+// InitializeBackend installs backend instead of loading the libraries.
+func InitializeBackend(backend Backend) error {
+	if err := backend.Install(); err != nil {
+		unregisterAll()
+		return err
+	}
+
+	loadedLibs = append(loadedLibs, Library{
+		name: "backend",
+		init: func(string) error { return backend.Install() },
+		release: func() error {
+			err := backend.Uninstall()
+			unregisterAll()
+			return err
+		},
+	})
+
+	return nil
+}
+
+func unregisterAll() {
+	unregisterFramework()
+	unregisterToolkit()
+	unregisterAgentServer()
+	unregisterAgentClient()
+}
+
 func Shutdown() error {
 
 	var (
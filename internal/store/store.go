@@ -1,6 +1,9 @@
 package store
 
-import "sync"
+import (
+	"log/slog"
+	"sync"
+)
 
 type Store[T any] struct {
 	data map[uintptr]T
@@ -44,6 +47,7 @@ func (s *Store[T]) Update(handle uintptr, fn func(*T)) {
 type TaskerStoreValue struct {
 	SinkIDToEventCallbackID        map[int64]uint64
 	ContextSinkIDToEventCallbackID map[int64]uint64
+	Logger                         *slog.Logger
 }
 
 type CtrlStoreValue struct {
@@ -55,6 +59,12 @@ type ResStoreValue struct {
 	SinkIDToEventCallbackID     map[int64]uint64
 	CustomRecognizersCallbackID map[string]uint64
 	CustomActionsCallbackID     map[string]uint64
+	MaterializedDirs            []string
+	// InferenceSet reports whether the inference execution provider and
+	// device below were set on the resource.
+	InferenceSet               bool
+	InferenceExecutionProvider int32
+	InferenceDevice            int32
 }
 
 var (
@@ -64,6 +64,10 @@ type initConfig struct {
 	// JSONDecoder sets a custom JSON decoder for the framework.
 	// Nil means Init will not change the current decoder.
 	JSONDecoder JSONDecoder
+
+	// Backend replaces the MaaFramework libraries. LibDir is ignored when it is set.
+	// Nil means Init loads the libraries.
+	Backend NativeBackend
 }
 
 // InitOption defines a function type for configuring initialization through functional options.
@@ -138,6 +142,18 @@ func WithJSONDecoder(decoder JSONDecoder) InitOption {
 	}
 }
 
+// NativeBackend provides the native MaaFramework functions in process instead
+// of loading the dynamic libraries. The maafake package implements one for tests.
+type NativeBackend = native.Backend
+
+// WithNativeBackend returns an InitOption that uses backend instead of the
+// MaaFramework libraries.
+func WithNativeBackend(backend NativeBackend) InitOption {
+	return func(ic *initConfig) {
+		ic.Backend = backend
+	}
+}
+
 // Init loads the dynamic library related to the MAA framework and registers its related functions.
 // It must be called before invoking any other MAA-related functions.
 // It must not be called concurrently with Release or other MAA-related functions.
@@ -154,7 +170,11 @@ func Init(opts ...InitOption) error {
 		opt(&cfg)
 	}
 
-	if err := native.Initialize(cfg.LibDir); err != nil {
+	if cfg.Backend != nil {
+		if err := native.InitializeBackend(cfg.Backend); err != nil {
+			return err
+		}
+	} else if err := native.Initialize(cfg.LibDir); err != nil {
 		return err
 	}
 
@@ -0,0 +1,408 @@
+package maafake
+
+import (
+	"image"
+	"slices"
+	"unsafe"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/native"
+)
+
+// cvType8UC3 is the cv::Mat type of the BGR images the framework exchanges.
+const cvType8UC3 int32 = 16
+
+type stringBuffer struct {
+	value string
+}
+
+type stringListBuffer struct {
+	items []uintptr
+}
+
+type imageBuffer struct {
+	data          []byte
+	width, height int32
+	typ           int32
+}
+
+type imageListBuffer struct {
+	items []uintptr
+}
+
+type rectBuffer struct {
+	x, y, w, h int32
+}
+
+func (b *Backend) installBuffers() {
+	native.MaaStringBufferCreate = func() uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.newHandle(&stringBuffer{})
+	}
+	native.MaaStringBufferDestroy = b.destroy
+	native.MaaStringBufferIsEmpty = func(handle uintptr) bool {
+		return b.getString(handle) == ""
+	}
+	native.MaaStringBufferClear = func(handle uintptr) bool {
+		return b.setString(handle, "")
+	}
+	native.MaaStringBufferGet = b.getString
+	native.MaaStringBufferSize = func(handle uintptr) uint64 {
+		return uint64(len(b.getString(handle)))
+	}
+	native.MaaStringBufferSet = b.setString
+	native.MaaStringBufferSetEx = func(handle uintptr, str string, size uint64) bool {
+		return b.setString(handle, str[:min(uint64(len(str)), size)])
+	}
+
+	native.MaaStringListBufferCreate = func() uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.newHandle(&stringListBuffer{})
+	}
+	native.MaaStringListBufferDestroy = func(handle uintptr) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if list, ok := lookup[*stringListBuffer](b, handle); ok {
+			for _, item := range list.items {
+				delete(b.objects, item)
+			}
+		}
+		delete(b.objects, handle)
+	}
+	native.MaaStringListBufferIsEmpty = func(handle uintptr) bool {
+		return native.MaaStringListBufferSize(handle) == 0
+	}
+	native.MaaStringListBufferSize = func(handle uintptr) uint64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*stringListBuffer](b, handle)
+		if list == nil {
+			return 0
+		}
+		return uint64(len(list.items))
+	}
+	native.MaaStringListBufferAt = func(handle uintptr, index uint64) uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*stringListBuffer](b, handle)
+		if list == nil || index >= uint64(len(list.items)) {
+			return 0
+		}
+		return list.items[index]
+	}
+	native.MaaStringListBufferAppend = func(handle uintptr, value uintptr) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, ok := lookup[*stringListBuffer](b, handle)
+		str, ok2 := lookup[*stringBuffer](b, value)
+		if !ok || !ok2 {
+			return false
+		}
+		list.items = append(list.items, b.newHandle(&stringBuffer{value: str.value}))
+		return true
+	}
+	native.MaaStringListBufferRemove = func(handle uintptr, index uint64) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*stringListBuffer](b, handle)
+		if list == nil || index >= uint64(len(list.items)) {
+			return false
+		}
+		delete(b.objects, list.items[index])
+		list.items = slices.Delete(list.items, int(index), int(index)+1)
+		return true
+	}
+	native.MaaStringListBufferClear = func(handle uintptr) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*stringListBuffer](b, handle)
+		if list == nil {
+			return false
+		}
+		for _, item := range list.items {
+			delete(b.objects, item)
+		}
+		list.items = nil
+		return true
+	}
+
+	native.MaaImageBufferCreate = func() uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.newHandle(&imageBuffer{})
+	}
+	native.MaaImageBufferDestroy = b.destroy
+	native.MaaImageBufferIsEmpty = func(handle uintptr) bool {
+		img := b.image(handle)
+		return img == nil || len(img.data) == 0
+	}
+	native.MaaImageBufferClear = func(handle uintptr) bool {
+		img := b.image(handle)
+		if img == nil {
+			return false
+		}
+		*img = imageBuffer{}
+		return true
+	}
+	native.MaaImageBufferGetRawData = func(handle uintptr) unsafe.Pointer {
+		img := b.image(handle)
+		if img == nil || len(img.data) == 0 {
+			return nil
+		}
+		return unsafe.Pointer(&img.data[0])
+	}
+	native.MaaImageBufferWidth = func(handle uintptr) int32 {
+		if img := b.image(handle); img != nil {
+			return img.width
+		}
+		return 0
+	}
+	native.MaaImageBufferHeight = func(handle uintptr) int32 {
+		if img := b.image(handle); img != nil {
+			return img.height
+		}
+		return 0
+	}
+	native.MaaImageBufferChannels = func(handle uintptr) int32 {
+		if img := b.image(handle); img != nil && len(img.data) > 0 {
+			return 3
+		}
+		return 0
+	}
+	native.MaaImageBufferType = func(handle uintptr) int32 {
+		if img := b.image(handle); img != nil {
+			return img.typ
+		}
+		return 0
+	}
+	native.MaaImageBufferSetRawData = func(handle uintptr, data unsafe.Pointer, width, height, imageType int32) bool {
+		img := b.image(handle)
+		if img == nil || imageType != cvType8UC3 || width <= 0 || height <= 0 {
+			return false
+		}
+		*img = imageBuffer{
+			data:   copyBytes(data, uint64(width)*uint64(height)*3),
+			width:  width,
+			height: height,
+			typ:    imageType,
+		}
+		return true
+	}
+	native.MaaImageBufferResize = func(handle uintptr, width, height int32) bool {
+		img := b.image(handle)
+		if img == nil || len(img.data) == 0 || width <= 0 || height <= 0 {
+			return false
+		}
+		*img = img.resized(width, height)
+		return true
+	}
+
+	native.MaaImageListBufferCreate = func() uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.newHandle(&imageListBuffer{})
+	}
+	native.MaaImageListBufferDestroy = func(handle uintptr) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if list, ok := lookup[*imageListBuffer](b, handle); ok {
+			for _, item := range list.items {
+				delete(b.objects, item)
+			}
+		}
+		delete(b.objects, handle)
+	}
+	native.MaaImageListBufferIsEmpty = func(handle uintptr) bool {
+		return native.MaaImageListBufferSize(handle) == 0
+	}
+	native.MaaImageListBufferSize = func(handle uintptr) uint64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*imageListBuffer](b, handle)
+		if list == nil {
+			return 0
+		}
+		return uint64(len(list.items))
+	}
+	native.MaaImageListBufferAt = func(handle uintptr, index uint64) uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*imageListBuffer](b, handle)
+		if list == nil || index >= uint64(len(list.items)) {
+			return 0
+		}
+		return list.items[index]
+	}
+	native.MaaImageListBufferAppend = func(handle uintptr, value uintptr) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, ok := lookup[*imageListBuffer](b, handle)
+		img, ok2 := lookup[*imageBuffer](b, value)
+		if !ok || !ok2 {
+			return false
+		}
+		clone := *img
+		clone.data = slices.Clone(img.data)
+		list.items = append(list.items, b.newHandle(&clone))
+		return true
+	}
+	native.MaaImageListBufferRemove = func(handle uintptr, index uint64) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*imageListBuffer](b, handle)
+		if list == nil || index >= uint64(len(list.items)) {
+			return false
+		}
+		delete(b.objects, list.items[index])
+		list.items = slices.Delete(list.items, int(index), int(index)+1)
+		return true
+	}
+	native.MaaImageListBufferClear = func(handle uintptr) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*imageListBuffer](b, handle)
+		if list == nil {
+			return false
+		}
+		for _, item := range list.items {
+			delete(b.objects, item)
+		}
+		list.items = nil
+		return true
+	}
+
+	native.MaaRectCreate = func() uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.newHandle(&rectBuffer{})
+	}
+	native.MaaRectDestroy = b.destroy
+	native.MaaRectGetX = func(handle uintptr) int32 { return b.rect(handle).x }
+	native.MaaRectGetY = func(handle uintptr) int32 { return b.rect(handle).y }
+	native.MaaRectGetW = func(handle uintptr) int32 { return b.rect(handle).w }
+	native.MaaRectGetH = func(handle uintptr) int32 { return b.rect(handle).h }
+	native.MaaRectSet = func(handle uintptr, x, y, w, h int32) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		r, ok := lookup[*rectBuffer](b, handle)
+		if !ok {
+			return false
+		}
+		*r = rectBuffer{x: x, y: y, w: w, h: h}
+		return true
+	}
+}
+
+func (b *Backend) getString(handle uintptr) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if str, ok := lookup[*stringBuffer](b, handle); ok {
+		return str.value
+	}
+	return ""
+}
+
+func (b *Backend) setString(handle uintptr, value string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.setStringLocked(handle, value)
+}
+
+// setStringLocked writes a string output parameter. Handle 0 means the caller
+// does not want the value. Callers hold b.mu.
+func (b *Backend) setStringLocked(handle uintptr, value string) bool {
+	if handle == 0 {
+		return true
+	}
+	str, ok := lookup[*stringBuffer](b, handle)
+	if !ok {
+		return false
+	}
+	// Copy, since value may point into memory the caller reuses.
+	str.value = string([]byte(value))
+	return true
+}
+
+func (b *Backend) setStringListLocked(handle uintptr, values []string) bool {
+	list, ok := lookup[*stringListBuffer](b, handle)
+	if !ok {
+		return false
+	}
+	for _, item := range list.items {
+		delete(b.objects, item)
+	}
+	list.items = list.items[:0]
+	for _, v := range values {
+		list.items = append(list.items, b.newHandle(&stringBuffer{value: v}))
+	}
+	return true
+}
+
+func (b *Backend) image(handle uintptr) *imageBuffer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	img, _ := lookup[*imageBuffer](b, handle)
+	return img
+}
+
+// setImageLocked writes img into an image buffer as BGR. Callers hold b.mu.
+func (b *Backend) setImageLocked(handle uintptr, img image.Image) bool {
+	buf, ok := lookup[*imageBuffer](b, handle)
+	if !ok {
+		return false
+	}
+	*buf = encodeBGR(img)
+	return true
+}
+
+func (b *Backend) rect(handle uintptr) rectBuffer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r, ok := lookup[*rectBuffer](b, handle); ok {
+		return *r
+	}
+	return rectBuffer{}
+}
+
+func (b *Backend) setRectLocked(handle uintptr, box Rect) bool {
+	if handle == 0 {
+		return true
+	}
+	r, ok := lookup[*rectBuffer](b, handle)
+	if !ok {
+		return false
+	}
+	*r = rectBuffer{x: int32(box[0]), y: int32(box[1]), w: int32(box[2]), h: int32(box[3])}
+	return true
+}
+
+func encodeBGR(img image.Image) imageBuffer {
+	if img == nil {
+		return imageBuffer{}
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	data := make([]byte, 0, width*height*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			data = append(data, byte(bl>>8), byte(g>>8), byte(r>>8))
+		}
+	}
+	return imageBuffer{data: data, width: int32(width), height: int32(height), typ: cvType8UC3}
+}
+
+// resized scales the image with nearest-neighbour sampling.
+func (img *imageBuffer) resized(width, height int32) imageBuffer {
+	data := make([]byte, 0, int(width)*int(height)*3)
+	for y := range height {
+		sy := int(y) * int(img.height) / int(height)
+		for x := range width {
+			sx := int(x) * int(img.width) / int(width)
+			i := (sy*int(img.width) + sx) * 3
+			data = append(data, img.data[i:i+3]...)
+		}
+	}
+	return imageBuffer{data: data, width: width, height: height, typ: img.typ}
+}
@@ -0,0 +1,149 @@
+package maafake
+
+import (
+	"encoding/json"
+	"maps"
+	"strings"
+	"unsafe"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/native"
+)
+
+// taskContext is the context of a running task.
+type taskContext struct {
+	handle   uintptr
+	tasker   *tasker
+	task     *taskRecord
+	override map[string]json.RawMessage
+}
+
+// nodeLocked returns the definition of a node as seen by the context: the
+// resource node with the task and context overrides applied. Callers hold b.mu.
+func (b *Backend) nodeLocked(ctx *taskContext, name string) (json.RawMessage, bool) {
+	var node json.RawMessage
+	if res, ok := lookup[*resource](b, ctx.tasker.res); ok {
+		node = res.nodes[name]
+	}
+	for _, override := range []map[string]json.RawMessage{ctx.task.override, ctx.override} {
+		if patch, ok := override[name]; ok {
+			node = mergeNode(node, patch)
+		}
+	}
+	return node, node != nil
+}
+
+func (b *Backend) installContext() {
+	native.MaaContextGetTaskId = func(handle uintptr) int64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if ctx, ok := lookup[*taskContext](b, handle); ok {
+			return ctx.task.id
+		}
+		return 0
+	}
+	native.MaaContextGetTasker = func(handle uintptr) uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if ctx, ok := lookup[*taskContext](b, handle); ok {
+			return ctx.tasker.handle
+		}
+		return 0
+	}
+	native.MaaContextClone = func(handle uintptr) uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		ctx, ok := lookup[*taskContext](b, handle)
+		if !ok {
+			return 0
+		}
+		clone := &taskContext{tasker: ctx.tasker, task: ctx.task, override: maps.Clone(ctx.override)}
+		clone.handle = b.newHandle(clone)
+		return clone.handle
+	}
+
+	// Pipeline recognition is not simulated.
+	native.MaaContextRunTask = func(handle uintptr, entry, override string) int64 { return 0 }
+	native.MaaContextRunRecognition = func(handle uintptr, entry, override string, img uintptr) int64 { return 0 }
+	native.MaaContextRunAction = func(handle uintptr, entry, override string, box uintptr, recoDetail string) int64 {
+		return 0
+	}
+	native.MaaContextRunRecognitionDirect = func(handle uintptr, recoType, recoParam string, img uintptr) int64 { return 0 }
+	native.MaaContextRunActionDirect = func(handle uintptr, actionType, actionParam string, box uintptr, recoDetail string) int64 {
+		return 0
+	}
+	native.MaaContextWaitFreezes = func(handle uintptr, time uint64, box uintptr, param string) bool {
+		return b.withContext(handle, func(*taskContext) bool { return true })
+	}
+
+	native.MaaContextOverridePipeline = func(handle uintptr, override string) bool {
+		return b.withContext(handle, func(ctx *taskContext) bool { return applyOverride(ctx.override, override) })
+	}
+	native.MaaContextOverrideNext = func(handle uintptr, name string, nextList uintptr) bool {
+		name = strings.Clone(name)
+		next := b.stringList(nextList)
+		return b.withContext(handle, func(ctx *taskContext) bool {
+			if _, ok := b.nodeLocked(ctx, name); !ok {
+				return false
+			}
+			patch, _ := json.Marshal(map[string]any{"next": next})
+			ctx.override[name] = mergeNode(ctx.override[name], patch)
+			return true
+		})
+	}
+	native.MaaContextOverrideImage = func(handle uintptr, name string, img uintptr) bool {
+		return b.withContext(handle, func(*taskContext) bool { return true })
+	}
+	native.MaaContextGetNodeData = func(handle uintptr, name string, buffer uintptr) bool {
+		return b.withContext(handle, func(ctx *taskContext) bool {
+			node, ok := b.nodeLocked(ctx, name)
+			return ok && b.setStringLocked(buffer, string(node))
+		})
+	}
+
+	native.MaaContextSetAnchor = func(handle uintptr, anchor, name string) bool {
+		anchor, name = strings.Clone(anchor), strings.Clone(name)
+		return b.withContext(handle, func(ctx *taskContext) bool {
+			ctx.task.anchors[anchor] = name
+			return true
+		})
+	}
+	native.MaaContextGetAnchor = func(handle uintptr, anchor string, buffer uintptr) bool {
+		return b.withContext(handle, func(ctx *taskContext) bool {
+			name, ok := ctx.task.anchors[anchor]
+			return ok && b.setStringLocked(buffer, name)
+		})
+	}
+	native.MaaContextGetHitCount = func(handle uintptr, name string, count *uint64) bool {
+		return b.withContext(handle, func(ctx *taskContext) bool {
+			*count = ctx.task.hitCounts[name]
+			return true
+		})
+	}
+	native.MaaContextClearHitCount = func(handle uintptr, name string) bool {
+		return b.withContext(handle, func(ctx *taskContext) bool {
+			delete(ctx.task.hitCounts, name)
+			return true
+		})
+	}
+}
+
+// withContext calls fn with the context of handle while holding b.mu, and
+// returns false if there is no such context.
+func (b *Backend) withContext(handle uintptr, fn func(ctx *taskContext) bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ctx, ok := lookup[*taskContext](b, handle)
+	return ok && fn(ctx)
+}
+
+// contextSinks returns the context sinks of the tasker running ctx.
+func (b *Backend) contextSinks(ctx *taskContext) []sink {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return ctx.tasker.contextSinks.snapshot()
+}
+
+// cStringPtr returns a NUL-terminated copy of s for passing to callbacks.
+func cStringPtr(s string) *byte {
+	return unsafe.SliceData(cString(s))
+}
@@ -0,0 +1,294 @@
+package maafake
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/native"
+)
+
+// ControllerAction is an action posted to a controller.
+type ControllerAction struct {
+	// UUID is the UUID of the controller.
+	UUID string
+	// Kind is the action, e.g. "click", "swipe" or "input_text".
+	Kind string
+	// Params holds the arguments of the action by name.
+	Params map[string]any
+}
+
+type controller struct {
+	kind      string
+	uuid      string
+	connected bool
+	image     imageBuffer
+	shell     string
+
+	sinks sinkSet
+	jobs  map[int64]int32
+}
+
+// Actions returns the actions posted to all controllers, in order.
+func (b *Backend) Actions() []ControllerAction {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.actions)
+}
+
+func (b *Backend) installController() {
+	native.MaaAdbControllerCreate = func(adbPath, address string, screencapMethods, inputMethods uint64, config, agentPath string) uintptr {
+		return b.newController("adb")
+	}
+	native.MaaPlayCoverControllerCreate = func(address, uuid string) uintptr {
+		return b.newController("playcover")
+	}
+	native.MaaWin32ControllerCreate = func(hWnd unsafe.Pointer, screencapMethods, mouseMethod, keyboardMethod uint64) uintptr {
+		return b.newController("win32")
+	}
+	native.MaaWlRootsControllerCreate = func(wlrSocketPath string, useWin32VkCode bool) uintptr {
+		return b.newController("wlroots")
+	}
+	native.MaaCustomControllerCreate = func(ctrl unsafe.Pointer, ctrlArg uintptr) uintptr {
+		return b.newController("custom")
+	}
+	native.MaaGamepadControllerCreate = func(hWnd unsafe.Pointer, gamepadType native.MaaGamepadType, screencapMethod uint64) uintptr {
+		return b.newController("gamepad")
+	}
+	native.MaaMacOSControllerCreate = func(windowID uint32, screencapMethod native.MaaMacOSScreencapMethod, inputMethod native.MaaMacOSInputMethod) uintptr {
+		return b.newController("macos")
+	}
+	native.MaaAndroidNativeControllerCreate = func(configJSON string) uintptr {
+		return b.newController("android_native")
+	}
+	native.MaaReplayControllerCreate = func(recordingPath string) uintptr {
+		return b.newController("replay")
+	}
+	native.MaaRecordControllerCreate = func(inner uintptr, recordingPath string) uintptr {
+		return b.newController("record")
+	}
+	native.MaaControllerDestroy = b.destroy
+
+	native.MaaControllerAddSink = func(handle uintptr, cb native.MaaEventCallback, arg uintptr) int64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		ctrl, ok := lookup[*controller](b, handle)
+		if !ok {
+			return 0
+		}
+		id := b.newID()
+		ctrl.sinks.add(id, cb, arg)
+		return id
+	}
+	native.MaaControllerRemoveSink = func(handle uintptr, id int64) {
+		b.withController(handle, func(ctrl *controller) bool { ctrl.sinks.remove(id); return true })
+	}
+	native.MaaControllerClearSinks = func(handle uintptr) {
+		b.withController(handle, func(ctrl *controller) bool { ctrl.sinks.clear(); return true })
+	}
+	native.MaaControllerSetOption = func(handle uintptr, key native.MaaCtrlOption, value unsafe.Pointer, size uint64) bool {
+		return b.withController(handle, func(*controller) bool { return true })
+	}
+
+	native.MaaControllerPostConnection = func(handle uintptr) int64 {
+		return b.post(handle, "connect", nil, func(ctrl *controller) bool {
+			ctrl.connected = true
+			return true
+		})
+	}
+	native.MaaControllerPostClick = func(handle uintptr, x, y int32) int64 {
+		return b.postInput(handle, "click", map[string]any{"x": x, "y": y})
+	}
+	native.MaaControllerPostClickV2 = func(handle uintptr, x, y, contact, pressure int32) int64 {
+		return b.postInput(handle, "click", map[string]any{"x": x, "y": y, "contact": contact, "pressure": pressure})
+	}
+	native.MaaControllerPostSwipe = func(handle uintptr, x1, y1, x2, y2, duration int32) int64 {
+		return b.postInput(handle, "swipe", map[string]any{"x1": x1, "y1": y1, "x2": x2, "y2": y2, "duration": duration})
+	}
+	native.MaaControllerPostSwipeV2 = func(handle uintptr, x1, y1, x2, y2, duration, contact, pressure int32) int64 {
+		return b.postInput(handle, "swipe", map[string]any{
+			"x1": x1, "y1": y1, "x2": x2, "y2": y2, "duration": duration, "contact": contact, "pressure": pressure,
+		})
+	}
+	native.MaaControllerPostClickKey = func(handle uintptr, keycode int32) int64 {
+		return b.postInput(handle, "click_key", map[string]any{"keycode": keycode})
+	}
+	native.MaaControllerPostInputText = func(handle uintptr, text string) int64 {
+		return b.postInput(handle, "input_text", map[string]any{"text": strings.Clone(text)})
+	}
+	native.MaaControllerPostStartApp = func(handle uintptr, intent string) int64 {
+		return b.postInput(handle, "start_app", map[string]any{"intent": strings.Clone(intent)})
+	}
+	native.MaaControllerPostStopApp = func(handle uintptr, intent string) int64 {
+		return b.postInput(handle, "stop_app", map[string]any{"intent": strings.Clone(intent)})
+	}
+	native.MaaControllerPostTouchDown = func(handle uintptr, contact, x, y, pressure int32) int64 {
+		return b.postInput(handle, "touch_down", map[string]any{"contact": contact, "x": x, "y": y, "pressure": pressure})
+	}
+	native.MaaControllerPostTouchMove = func(handle uintptr, contact, x, y, pressure int32) int64 {
+		return b.postInput(handle, "touch_move", map[string]any{"contact": contact, "x": x, "y": y, "pressure": pressure})
+	}
+	native.MaaControllerPostTouchUp = func(handle uintptr, contact int32) int64 {
+		return b.postInput(handle, "touch_up", map[string]any{"contact": contact})
+	}
+	native.MaaControllerPostRelativeMove = func(handle uintptr, dx, dy int32) int64 {
+		return b.postInput(handle, "relative_move", map[string]any{"dx": dx, "dy": dy})
+	}
+	native.MaaControllerPostKeyDown = func(handle uintptr, keycode int32) int64 {
+		return b.postInput(handle, "key_down", map[string]any{"keycode": keycode})
+	}
+	native.MaaControllerPostKeyUp = func(handle uintptr, keycode int32) int64 {
+		return b.postInput(handle, "key_up", map[string]any{"keycode": keycode})
+	}
+	native.MaaControllerPostScroll = func(handle uintptr, dx, dy int32) int64 {
+		return b.postInput(handle, "scroll", map[string]any{"dx": dx, "dy": dy})
+	}
+	native.MaaControllerPostInactive = func(handle uintptr) int64 {
+		return b.postInput(handle, "inactive", nil)
+	}
+	native.MaaControllerPostShell = func(handle uintptr, cmd string, timeout int64) int64 {
+		cmd = strings.Clone(cmd)
+		return b.post(handle, "shell", map[string]any{"cmd": cmd, "timeout": timeout}, func(ctrl *controller) bool {
+			ctrl.shell = ""
+			return ctrl.connected
+		})
+	}
+	native.MaaControllerPostScreencap = func(handle uintptr) int64 {
+		uuid := b.controllerUUID(handle)
+		// Screencap is user code, so call it without holding b.mu.
+		var img image.Image
+		if b.Screencap != nil {
+			img = b.Screencap(uuid)
+		}
+		if img == nil {
+			img = image.NewRGBA(image.Rect(0, 0, 1280, 720))
+		}
+		return b.post(handle, "screencap", nil, func(ctrl *controller) bool {
+			if !ctrl.connected {
+				return false
+			}
+			ctrl.image = encodeBGR(img)
+			return true
+		})
+	}
+
+	native.MaaControllerGetShellOutput = func(handle uintptr, buffer uintptr) bool {
+		return b.withController(handle, func(ctrl *controller) bool { return b.setStringLocked(buffer, ctrl.shell) })
+	}
+	native.MaaControllerStatus = func(handle uintptr, id int64) int32 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if ctrl, ok := lookup[*controller](b, handle); ok {
+			return ctrl.jobs[id]
+		}
+		return statusInvalid
+	}
+	// Actions run synchronously, so every job has finished by the time its ID
+	// is returned.
+	native.MaaControllerWait = native.MaaControllerStatus
+	native.MaaControllerConnected = func(handle uintptr) bool {
+		return b.withController(handle, func(ctrl *controller) bool { return ctrl.connected })
+	}
+	native.MaaControllerCachedImage = func(handle uintptr, buffer uintptr) bool {
+		return b.withController(handle, func(ctrl *controller) bool {
+			img, ok := lookup[*imageBuffer](b, buffer)
+			if !ok || len(ctrl.image.data) == 0 {
+				return false
+			}
+			*img = ctrl.image
+			img.data = slices.Clone(ctrl.image.data)
+			return true
+		})
+	}
+	native.MaaControllerGetUuid = func(handle uintptr, buffer uintptr) bool {
+		return b.withController(handle, func(ctrl *controller) bool { return b.setStringLocked(buffer, ctrl.uuid) })
+	}
+	native.MaaControllerGetResolution = func(handle uintptr, width, height *int32) bool {
+		return b.withController(handle, func(ctrl *controller) bool {
+			if len(ctrl.image.data) == 0 {
+				return false
+			}
+			*width, *height = ctrl.image.width, ctrl.image.height
+			return true
+		})
+	}
+	native.MaaControllerGetInfo = func(handle uintptr, buffer uintptr) bool {
+		return b.withController(handle, func(ctrl *controller) bool {
+			info, _ := json.Marshal(map[string]any{"type": ctrl.kind, "uuid": ctrl.uuid, "connected": ctrl.connected})
+			return b.setStringLocked(buffer, string(info))
+		})
+	}
+}
+
+func (b *Backend) newController(kind string) uintptr {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ctrl := &controller{kind: kind, jobs: make(map[int64]int32)}
+	handle := b.newHandle(ctrl)
+	ctrl.uuid = fmt.Sprintf("maafake-%d", handle)
+	return handle
+}
+
+// withController calls fn with the controller of handle while holding b.mu,
+// and returns false if there is no such controller.
+func (b *Backend) withController(handle uintptr, fn func(ctrl *controller) bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ctrl, ok := lookup[*controller](b, handle)
+	return ok && fn(ctrl)
+}
+
+func (b *Backend) controllerUUID(handle uintptr) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ctrl, ok := lookup[*controller](b, handle); ok {
+		return ctrl.uuid
+	}
+	return ""
+}
+
+// postInput records an input action, which succeeds once connected.
+func (b *Backend) postInput(handle uintptr, kind string, params map[string]any) int64 {
+	return b.post(handle, kind, params, func(ctrl *controller) bool {
+		if !ctrl.connected {
+			return false
+		}
+		b.actions = append(b.actions, ControllerAction{UUID: ctrl.uuid, Kind: kind, Params: params})
+		return true
+	})
+}
+
+// post runs a controller action as a job, emitting the Controller.Action
+// events around it. run is called with b.mu held.
+func (b *Backend) post(handle uintptr, kind string, params map[string]any, run func(ctrl *controller) bool) int64 {
+	b.mu.Lock()
+	ctrl, ok := lookup[*controller](b, handle)
+	if !ok {
+		b.mu.Unlock()
+		return 0
+	}
+	id := b.newID()
+	ctrl.jobs[id] = statusRunning
+	sinks := ctrl.sinks.snapshot()
+	if params == nil {
+		params = map[string]any{}
+	}
+	detail := map[string]any{"ctrl_id": id, "uuid": ctrl.uuid, "action": kind, "param": params, "info": map[string]any{}}
+	b.mu.Unlock()
+
+	emit(sinks, handle, "Controller.Action.Starting", detail)
+
+	b.mu.Lock()
+	status, message := statusFailure, "Controller.Action.Failed"
+	if run(ctrl) {
+		status, message = statusSuccess, "Controller.Action.Succeeded"
+	}
+	ctrl.jobs[id] = status
+	b.mu.Unlock()
+
+	emit(sinks, handle, message, detail)
+	return id
+}
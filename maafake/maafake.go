@@ -0,0 +1,308 @@
+// Package maafake is an in-process fake of the MaaFramework libraries, so code
+// using the Go binding can be unit tested with plain go test.
+//
+// Install it instead of the libraries with maa.WithNativeBackend:
+//
+//	fake := maafake.New()
+//	fake.AddBundle("./resource", map[string]any{"Start": map[string]any{}})
+//	maa.Init(maa.WithNativeBackend(fake))
+//	defer maa.Release()
+//
+// The fake keeps handles, string, image and rect buffers, jobs, options and
+// event sinks like the framework does. Resources load the pipelines registered
+// with AddBundle, controllers record the actions posted to them and serve the
+// images of Screencap, and taskers run tasks with RunTask, which by default
+// runs the entry node alone. RunTask can drive a task node by node with Task,
+// including custom actions and recognitions registered on the resource.
+//
+// Pipeline recognition is not simulated: Context.RunTask, RunRecognition and
+// RunAction fail, custom controllers never call back into Go, and the agent
+// functions are not provided.
+package maafake
+
+import (
+	"encoding/json"
+	"image"
+	"maps"
+	"slices"
+	"sync"
+	"unsafe"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/native"
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/rect"
+)
+
+// Rect is a box as x, y, width and height.
+type Rect = rect.Rect
+
+// Version is returned by MaaVersion.
+const Version = "v0.0.0-maafake"
+
+// Job statuses, as in MaaStatus.
+const (
+	statusInvalid int32 = 0
+	statusPending int32 = 1000
+	statusRunning int32 = 2000
+	statusSuccess int32 = 3000
+	statusFailure int32 = 4000
+)
+
+// Backend is the fake. Its exported fields program its behaviour; set them
+// before Init.
+type Backend struct {
+	// Screencap returns the screen of the controller with the given UUID.
+	// By default every screenshot is a black 1280x720 image.
+	Screencap func(uuid string) image.Image
+	// RunTask runs a posted task and reports whether it succeeded.
+	// By default it runs the entry node with DefaultNode, failing if the
+	// entry is not a node of the bound resource.
+	RunTask func(t *Task) bool
+
+	mu      sync.Mutex
+	handle  uintptr
+	id      int64
+	objects map[uintptr]any
+
+	bundles       map[string]map[string]json.RawMessage
+	globalOptions map[int32][]byte
+	plugins       []string
+	actions       []ControllerAction
+
+	adbDevices []AdbDevice
+	windows    []DesktopWindow
+}
+
+// New creates a fake with no bundles.
+func New() *Backend {
+	return &Backend{
+		objects:       make(map[uintptr]any),
+		bundles:       make(map[string]map[string]json.RawMessage),
+		globalOptions: make(map[int32][]byte),
+	}
+}
+
+// AddBundle registers the pipeline loaded by PostBundle(path), as a map from
+// node name to node definition. Loading a path without a bundle fails.
+func (b *Backend) AddBundle(path string, pipeline map[string]any) error {
+	nodes := make(map[string]json.RawMessage, len(pipeline))
+	for name, node := range pipeline {
+		data, err := json.Marshal(node)
+		if err != nil {
+			return err
+		}
+		nodes[name] = data
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bundles[path] = nodes
+	return nil
+}
+
+// GlobalOption returns the raw value last set for a global option, e.g.
+// native.MaaGlobalOption_LogDir.
+func (b *Backend) GlobalOption(key native.MaaGlobalOption) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, ok := b.globalOptions[int32(key)]
+	return slices.Clone(value), ok
+}
+
+// ResourceOption returns the raw value last set for an option of the resource
+// handle, e.g. native.MaaResOption_InferenceDevice.
+func (b *Backend) ResourceOption(handle uintptr, key native.MaaResOption) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res, ok := lookup[*resource](b, handle)
+	if !ok {
+		return nil, false
+	}
+	value, ok := res.options[int32(key)]
+	return slices.Clone(value), ok
+}
+
+// Plugins returns the paths passed to MaaGlobalLoadPlugin.
+func (b *Backend) Plugins() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.plugins)
+}
+
+// Objects returns the number of live handles: buffers, resources, controllers,
+// taskers and contexts. Tests use it to find leaked buffers.
+func (b *Backend) Objects() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.objects)
+}
+
+// Install implements maa.NativeBackend.
+func (b *Backend) Install() error {
+	native.MaaVersion = func() string { return Version }
+	native.MaaGlobalSetOption = b.globalSetOption
+	native.MaaGlobalLoadPlugin = b.globalLoadPlugin
+
+	b.installBuffers()
+	b.installResource()
+	b.installController()
+	b.installTasker()
+	b.installContext()
+	b.installToolkit()
+	return nil
+}
+
+// Uninstall implements maa.NativeBackend. It stops the taskers and drops every
+// handle; the bundles and programmed behaviour are kept for the next Init.
+func (b *Backend) Uninstall() error {
+	b.mu.Lock()
+	objects := b.objects
+	b.objects = make(map[uintptr]any)
+	b.mu.Unlock()
+
+	for _, obj := range objects {
+		if t, ok := obj.(*tasker); ok {
+			t.close()
+		}
+	}
+	return nil
+}
+
+func (b *Backend) globalSetOption(key native.MaaGlobalOption, value unsafe.Pointer, valSize uint64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.globalOptions[int32(key)] = copyBytes(value, valSize)
+	return true
+}
+
+func (b *Backend) globalLoadPlugin(path string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.plugins = append(b.plugins, path)
+	return true
+}
+
+// newHandle registers obj and returns its handle. Callers hold b.mu.
+func (b *Backend) newHandle(obj any) uintptr {
+	b.handle++
+	b.objects[b.handle] = obj
+	return b.handle
+}
+
+// newID returns a fresh job, task, node, recognition or action ID. Callers hold b.mu.
+func (b *Backend) newID() int64 {
+	b.id++
+	return b.id
+}
+
+// lookup returns the object of handle if it has type T. Callers hold b.mu.
+func lookup[T any](b *Backend, handle uintptr) (T, bool) {
+	obj, ok := b.objects[handle].(T)
+	return obj, ok
+}
+
+func (b *Backend) destroy(handle uintptr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.objects, handle)
+}
+
+func copyBytes(ptr unsafe.Pointer, size uint64) []byte {
+	if ptr == nil || size == 0 {
+		return nil
+	}
+	return slices.Clone(unsafe.Slice((*byte)(ptr), size))
+}
+
+// sink is an event callback registered on a resource, controller or tasker.
+type sink struct {
+	callback native.MaaEventCallback
+	arg      uintptr
+}
+
+// sinkSet holds the sinks of one object, in registration order.
+type sinkSet struct {
+	ids   []int64
+	sinks map[int64]sink
+}
+
+func (s *sinkSet) add(id int64, cb native.MaaEventCallback, arg uintptr) {
+	if s.sinks == nil {
+		s.sinks = make(map[int64]sink)
+	}
+	s.ids = append(s.ids, id)
+	s.sinks[id] = sink{callback: cb, arg: arg}
+}
+
+func (s *sinkSet) remove(id int64) {
+	s.ids = slices.DeleteFunc(s.ids, func(v int64) bool { return v == id })
+	delete(s.sinks, id)
+}
+
+func (s *sinkSet) clear() {
+	s.ids = nil
+	s.sinks = nil
+}
+
+func (s *sinkSet) snapshot() []sink {
+	out := make([]sink, 0, len(s.ids))
+	for _, id := range s.ids {
+		out = append(out, s.sinks[id])
+	}
+	return out
+}
+
+// emit calls sinks with the event message and detail, as the framework does:
+// the strings are NUL-terminated and only valid during the call. Callers must
+// not hold b.mu, since sinks call back into the backend.
+func emit(sinks []sink, handle uintptr, message string, detail any) {
+	if len(sinks) == 0 {
+		return
+	}
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return
+	}
+	for _, s := range sinks {
+		msg := cString(message)
+		details := cString(string(data))
+		s.callback(handle, &msg[0], &details[0], s.arg)
+		// The framework reuses its buffers once the callback returns.
+		clear(msg)
+		clear(details)
+	}
+}
+
+func cString(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+// mergeNode applies a node override to base, replacing the top-level fields
+// the override sets.
+func mergeNode(base, override json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if len(base) > 0 && json.Unmarshal(base, &fields) != nil {
+		return override
+	}
+	var patch map[string]json.RawMessage
+	if json.Unmarshal(override, &patch) != nil {
+		return base
+	}
+	if fields == nil {
+		fields = make(map[string]json.RawMessage, len(patch))
+	}
+	maps.Copy(fields, patch)
+	data, _ := json.Marshal(fields)
+	return data
+}
+
+// applyOverride merges a pipeline override document into nodes.
+func applyOverride(nodes map[string]json.RawMessage, override string) bool {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(override), &patch); err != nil {
+		return false
+	}
+	for name, node := range patch {
+		nodes[name] = mergeNode(nodes[name], node)
+	}
+	return true
+}
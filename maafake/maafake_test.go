@@ -0,0 +1,225 @@
+package maafake_test
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/native"
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+var fake = maafake.New()
+
+func TestMain(m *testing.M) {
+	err := fake.AddBundle("bundle", map[string]any{
+		"Start": map[string]any{"next": []string{"Tap"}},
+		"Tap": map[string]any{
+			"recognition": "DirectHit",
+			"action":      map[string]any{"type": "Custom", "param": map[string]any{"custom_action": "Tap", "custom_action_param": map[string]any{"n": 1}}},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	fake.Screencap = func(uuid string) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+		img.Set(1, 1, color.RGBA{R: 10, G: 20, B: 30, A: 255})
+		return img
+	}
+
+	if err := maa.Init(maa.WithNativeBackend(fake), maa.WithLogDir("fake-logs")); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = maa.Release()
+	os.Exit(code)
+}
+
+func newTasker(t *testing.T) (*maa.Tasker, *maa.Resource, *maa.Controller) {
+	t.Helper()
+	res, err := maa.NewResource()
+	require.NoError(t, err)
+	t.Cleanup(res.Destroy)
+	require.True(t, res.PostBundle("bundle").Wait().Success())
+
+	ctrl, err := maa.NewBlankController()
+	require.NoError(t, err)
+	t.Cleanup(ctrl.Destroy)
+	require.True(t, ctrl.PostConnect().Wait().Success())
+
+	tasker, err := maa.NewTasker()
+	require.NoError(t, err)
+	t.Cleanup(tasker.Destroy)
+	require.NoError(t, tasker.BindResource(res))
+	require.NoError(t, tasker.BindController(ctrl))
+	require.True(t, tasker.Initialized())
+	return tasker, res, ctrl
+}
+
+func TestInit(t *testing.T) {
+	require.Equal(t, maafake.Version, maa.Version())
+	logDir, ok := fake.GlobalOption(native.MaaGlobalOption_LogDir)
+	require.True(t, ok)
+	require.Equal(t, "fake-logs", string(logDir))
+}
+
+func TestResource(t *testing.T) {
+	res, err := maa.NewResource()
+	require.NoError(t, err)
+	defer res.Destroy()
+
+	var events []string
+	res.OnResourceLoading(func(status maa.EventStatus, detail maa.ResourceLoadingDetail) {
+		events = append(events, detail.Path+" "+status.String())
+	})
+
+	require.False(t, res.PostBundle("missing").Wait().Success())
+	require.False(t, res.Loaded())
+	require.True(t, res.PostBundle("bundle").Wait().Success())
+	require.True(t, res.Loaded())
+	require.Equal(t, []string{"missing starting", "missing failed", "bundle starting", "bundle succeeded"}, events)
+
+	nodes, err := res.GetNodeList()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Start", "Tap"}, nodes)
+
+	hash, err := res.GetHash()
+	require.NoError(t, err)
+	require.NotEmpty(t, hash)
+}
+
+func TestController(t *testing.T) {
+	ctrl, err := maa.NewBlankController()
+	require.NoError(t, err)
+	defer ctrl.Destroy()
+
+	require.False(t, ctrl.PostClick(1, 2).Wait().Success(), "not connected")
+	require.True(t, ctrl.PostConnect().Wait().Success())
+	require.True(t, ctrl.PostClick(1, 2).Wait().Success())
+	require.True(t, ctrl.PostScreencap().Wait().Success())
+
+	img, err := ctrl.CacheImage()
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 4, 2), img.Bounds())
+	r, g, b, _ := img.At(1, 1).RGBA()
+	require.Equal(t, []uint32{10, 20, 30}, []uint32{r >> 8, g >> 8, b >> 8})
+
+	uuid, err := ctrl.GetUUID()
+	require.NoError(t, err)
+	actions := fake.Actions()
+	require.Equal(t, maafake.ControllerAction{UUID: uuid, Kind: "click", Params: map[string]any{"x": int32(1), "y": int32(2)}}, actions[len(actions)-1])
+}
+
+type tapAction struct {
+	mu   sync.Mutex
+	args []*maa.CustomActionArg
+	hits uint64
+}
+
+func (a *tapAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.args = append(a.args, arg)
+	if err := ctx.SetAnchor("Last", arg.CurrentTaskName); err != nil {
+		return false
+	}
+	hits, err := ctx.GetHitCount(arg.CurrentTaskName)
+	if err != nil {
+		return false
+	}
+	a.hits = hits
+	return true
+}
+
+func TestTask(t *testing.T) {
+	tasker, res, _ := newTasker(t)
+	action := &tapAction{}
+	require.NoError(t, res.RegisterCustomAction("Tap", action))
+
+	// The sinks run on the tasker worker, so they record instead of asserting.
+	var taskEvents, nodes []string
+	tasker.OnTaskerTask(func(status maa.EventStatus, detail maa.TaskerTaskDetail) {
+		taskEvents = append(taskEvents, detail.Entry+" "+status.String())
+	})
+	tasker.OnNodePipelineNodeInContext(func(ctx *maa.Context, status maa.EventStatus, detail maa.NodePipelineNodeDetail) {
+		if status == maa.EventStatusSucceeded {
+			anchor, _ := ctx.GetAnchor("Last")
+			nodes = append(nodes, detail.Name+"@"+anchor)
+		}
+	})
+
+	job := tasker.PostTask("Tap").Wait()
+	require.True(t, job.Success())
+	require.Equal(t, []string{"Tap starting", "Tap succeeded"}, taskEvents)
+	require.Equal(t, []string{"Tap@Tap"}, nodes)
+
+	require.Len(t, action.args, 1)
+	require.Equal(t, "Tap", action.args[0].CustomActionName)
+	require.JSONEq(t, `{"n":1}`, action.args[0].CustomActionParam)
+	require.True(t, action.args[0].RecognitionDetail.Hit)
+	require.Equal(t, uint64(1), action.hits)
+
+	detail, err := job.GetDetail()
+	require.NoError(t, err)
+	require.Equal(t, "Tap", detail.Entry)
+	require.Equal(t, maa.StatusSuccess, detail.Status)
+	require.Len(t, detail.Nodes, 1)
+	node, err := detail.Nodes[0].GetDetail()
+	require.NoError(t, err)
+	require.Equal(t, "Tap", node.Name)
+	require.True(t, node.RunCompleted)
+	require.Equal(t, "Custom", node.Action.Action)
+
+	require.False(t, tasker.PostTask("Missing").Wait().Success())
+}
+
+func TestRunTask(t *testing.T) {
+	fake.RunTask = func(task *maafake.Task) bool {
+		task.RunNode(maafake.Node{Name: "Start", Box: maafake.Rect{1, 2, 3, 4}})
+		task.RunNode(maafake.Node{Name: "Miss", Miss: true})
+		return task.RunNode(maafake.Node{Name: "End", Action: "Click", ActionFailed: true})
+	}
+	defer func() { fake.RunTask = nil }()
+
+	tasker, _, _ := newTasker(t)
+	job := tasker.PostTask("Start").Wait()
+	require.Equal(t, maa.StatusFailure, job.Status())
+
+	detail, err := job.GetDetail()
+	require.NoError(t, err)
+	require.Len(t, detail.Nodes, 2)
+
+	start, err := detail.Nodes[0].GetDetail()
+	require.NoError(t, err)
+	require.Equal(t, maa.Rect{1, 2, 3, 4}, start.Recognition.Box)
+	require.Equal(t, "DirectHit", start.Recognition.Algorithm)
+	require.True(t, start.RunCompleted)
+
+	end, err := detail.Nodes[1].GetDetail()
+	require.NoError(t, err)
+	require.False(t, end.RunCompleted)
+	require.False(t, end.Action.Success)
+}
+
+func TestObjects(t *testing.T) {
+	before := fake.Objects()
+	tasker, _, _ := newTasker(t)
+	require.True(t, tasker.PostTask("Start").Wait().Success())
+	require.Greater(t, fake.Objects(), before)
+
+	tasker.Destroy()
+	require.Equal(t, before+2, fake.Objects(), "resource and controller are still alive")
+}
+
+func TestAdbDevices(t *testing.T) {
+	fake.SetAdbDevices(maafake.AdbDevice{Name: "emulator", AdbPath: "adb", Address: "127.0.0.1:5555", Config: "{}"})
+	devices, err := maa.FindAdbDevices()
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	require.Equal(t, "127.0.0.1:5555", devices[0].Address)
+}
@@ -0,0 +1,277 @@
+package maafake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/native"
+)
+
+type customAction struct {
+	callback native.MaaCustomActionCallback
+	arg      uintptr
+}
+
+type customRecognition struct {
+	callback native.MaaCustomRecognitionCallback
+	arg      uintptr
+}
+
+type resource struct {
+	nodes   map[string]json.RawMessage
+	loaded  bool
+	hash    string
+	options map[int32][]byte
+
+	sinks        sinkSet
+	actions      map[string]customAction
+	recognitions map[string]customRecognition
+	jobs         map[int64]int32
+}
+
+func (b *Backend) installResource() {
+	native.MaaResourceCreate = func() uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.newHandle(&resource{
+			nodes:        make(map[string]json.RawMessage),
+			actions:      make(map[string]customAction),
+			recognitions: make(map[string]customRecognition),
+			jobs:         make(map[int64]int32),
+			options:      make(map[int32][]byte),
+		})
+	}
+	native.MaaResourceDestroy = b.destroy
+
+	native.MaaResourceAddSink = func(handle uintptr, cb native.MaaEventCallback, arg uintptr) int64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		res, ok := lookup[*resource](b, handle)
+		if !ok {
+			return 0
+		}
+		id := b.newID()
+		res.sinks.add(id, cb, arg)
+		return id
+	}
+	native.MaaResourceRemoveSink = func(handle uintptr, id int64) {
+		b.withResource(handle, func(res *resource) bool { res.sinks.remove(id); return true })
+	}
+	native.MaaResourceClearSinks = func(handle uintptr) {
+		b.withResource(handle, func(res *resource) bool { res.sinks.clear(); return true })
+	}
+
+	native.MaaResourceRegisterCustomRecognition = func(handle uintptr, name string, cb native.MaaCustomRecognitionCallback, arg uintptr) bool {
+		name = strings.Clone(name)
+		return b.withResource(handle, func(res *resource) bool {
+			res.recognitions[name] = customRecognition{callback: cb, arg: arg}
+			return true
+		})
+	}
+	native.MaaResourceUnregisterCustomRecognition = func(handle uintptr, name string) bool {
+		return b.withResource(handle, func(res *resource) bool {
+			_, ok := res.recognitions[name]
+			delete(res.recognitions, name)
+			return ok
+		})
+	}
+	native.MaaResourceClearCustomRecognition = func(handle uintptr) bool {
+		return b.withResource(handle, func(res *resource) bool { clear(res.recognitions); return true })
+	}
+	native.MaaResourceRegisterCustomAction = func(handle uintptr, name string, cb native.MaaCustomActionCallback, arg uintptr) bool {
+		name = strings.Clone(name)
+		return b.withResource(handle, func(res *resource) bool {
+			res.actions[name] = customAction{callback: cb, arg: arg}
+			return true
+		})
+	}
+	native.MaaResourceUnregisterCustomAction = func(handle uintptr, name string) bool {
+		return b.withResource(handle, func(res *resource) bool {
+			_, ok := res.actions[name]
+			delete(res.actions, name)
+			return ok
+		})
+	}
+	native.MaaResourceClearCustomAction = func(handle uintptr) bool {
+		return b.withResource(handle, func(res *resource) bool { clear(res.actions); return true })
+	}
+
+	native.MaaResourcePostBundle = b.loadBundle
+	native.MaaResourcePostPipeline = b.loadBundle
+	native.MaaResourcePostOcrModel = b.loadNothing
+	native.MaaResourcePostImage = b.loadNothing
+
+	native.MaaResourceOverridePipeline = func(handle uintptr, override string) bool {
+		return b.withResource(handle, func(res *resource) bool {
+			if !applyOverride(res.nodes, override) {
+				return false
+			}
+			res.hash = hashNodes(res.nodes)
+			return true
+		})
+	}
+	native.MaaResourceOverrideNext = func(handle uintptr, name string, nextList uintptr) bool {
+		name = strings.Clone(name)
+		next := b.stringList(nextList)
+		return b.withResource(handle, func(res *resource) bool {
+			if _, ok := res.nodes[name]; !ok {
+				return false
+			}
+			patch, _ := json.Marshal(map[string]any{"next": next})
+			res.nodes[name] = mergeNode(res.nodes[name], patch)
+			res.hash = hashNodes(res.nodes)
+			return true
+		})
+	}
+	native.MaaResourceOverrideImage = func(handle uintptr, name string, img uintptr) bool {
+		return b.withResource(handle, func(*resource) bool { return true })
+	}
+	native.MaaResourceGetNodeData = func(handle uintptr, name string, buffer uintptr) bool {
+		return b.withResource(handle, func(res *resource) bool {
+			node, ok := res.nodes[name]
+			return ok && b.setStringLocked(buffer, string(node))
+		})
+	}
+	native.MaaResourceClear = func(handle uintptr) bool {
+		return b.withResource(handle, func(res *resource) bool {
+			clear(res.nodes)
+			res.loaded = false
+			res.hash = ""
+			return true
+		})
+	}
+
+	native.MaaResourceStatus = func(handle uintptr, id int64) int32 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if res, ok := lookup[*resource](b, handle); ok {
+			return res.jobs[id]
+		}
+		return statusInvalid
+	}
+	// Loading is synchronous, so every job has finished by the time its ID
+	// is returned.
+	native.MaaResourceWait = native.MaaResourceStatus
+	native.MaaResourceLoaded = func(handle uintptr) bool {
+		return b.withResource(handle, func(res *resource) bool { return res.loaded })
+	}
+	native.MaaResourceSetOption = func(handle uintptr, key native.MaaResOption, value unsafe.Pointer, size uint64) bool {
+		return b.withResource(handle, func(res *resource) bool {
+			res.options[int32(key)] = copyBytes(value, size)
+			return true
+		})
+	}
+
+	native.MaaResourceGetHash = func(handle uintptr, buffer uintptr) bool {
+		return b.withResource(handle, func(res *resource) bool { return b.setStringLocked(buffer, res.hash) })
+	}
+	native.MaaResourceGetNodeList = func(handle uintptr, buffer uintptr) bool {
+		return b.withResource(handle, func(res *resource) bool {
+			return b.setStringListLocked(buffer, slices.Sorted(maps.Keys(res.nodes)))
+		})
+	}
+	native.MaaResourceGetCustomRecognitionList = func(handle uintptr, buffer uintptr) bool {
+		return b.withResource(handle, func(res *resource) bool {
+			return b.setStringListLocked(buffer, slices.Sorted(maps.Keys(res.recognitions)))
+		})
+	}
+	native.MaaResourceGetCustomActionList = func(handle uintptr, buffer uintptr) bool {
+		return b.withResource(handle, func(res *resource) bool {
+			return b.setStringListLocked(buffer, slices.Sorted(maps.Keys(res.actions)))
+		})
+	}
+	native.MaaResourceGetDefaultRecognitionParam = func(handle uintptr, recoType string, buffer uintptr) bool {
+		return b.withResource(handle, func(*resource) bool { return b.setStringLocked(buffer, "{}") })
+	}
+	native.MaaResourceGetDefaultActionParam = func(handle uintptr, actionType string, buffer uintptr) bool {
+		return b.withResource(handle, func(*resource) bool { return b.setStringLocked(buffer, "{}") })
+	}
+}
+
+// withResource calls fn with the resource of handle while holding b.mu, and
+// returns false if there is no such resource.
+func (b *Backend) withResource(handle uintptr, fn func(res *resource) bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res, ok := lookup[*resource](b, handle)
+	return ok && fn(res)
+}
+
+// stringList returns the values of a string list buffer.
+func (b *Backend) stringList(handle uintptr) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list, ok := lookup[*stringListBuffer](b, handle)
+	if !ok {
+		return nil
+	}
+	values := make([]string, 0, len(list.items))
+	for _, item := range list.items {
+		if str, ok := lookup[*stringBuffer](b, item); ok {
+			values = append(values, str.value)
+		}
+	}
+	return values
+}
+
+// loadBundle loads the pipeline registered with AddBundle for path.
+func (b *Backend) loadBundle(handle uintptr, path string) int64 {
+	path = strings.Clone(path)
+
+	b.mu.Lock()
+	res, ok := lookup[*resource](b, handle)
+	if !ok {
+		b.mu.Unlock()
+		return 0
+	}
+	id := b.newID()
+	res.jobs[id] = statusRunning
+	sinks := res.sinks.snapshot()
+	detail := map[string]any{"res_id": id, "hash": res.hash, "path": path}
+	b.mu.Unlock()
+
+	emit(sinks, handle, "Resource.Loading.Starting", detail)
+
+	b.mu.Lock()
+	bundle, found := b.bundles[path]
+	status, message := statusFailure, "Resource.Loading.Failed"
+	if found {
+		maps.Copy(res.nodes, bundle)
+		res.loaded = true
+		res.hash = hashNodes(res.nodes)
+		status, message = statusSuccess, "Resource.Loading.Succeeded"
+	}
+	res.jobs[id] = status
+	detail["hash"] = res.hash
+	b.mu.Unlock()
+
+	emit(sinks, handle, message, detail)
+	return id
+}
+
+// loadNothing accepts a model or image path without loading anything.
+func (b *Backend) loadNothing(handle uintptr, path string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res, ok := lookup[*resource](b, handle)
+	if !ok {
+		return 0
+	}
+	id := b.newID()
+	res.jobs[id] = statusSuccess
+	return id
+}
+
+func hashNodes(nodes map[string]json.RawMessage) string {
+	h := sha256.New()
+	for _, name := range slices.Sorted(maps.Keys(nodes)) {
+		h.Write([]byte(name))
+		h.Write(nodes[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
@@ -0,0 +1,278 @@
+package maafake
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+// Task is a task being run by RunTask.
+type Task struct {
+	// ID is the task ID.
+	ID int64
+	// Entry is the entry node.
+	Entry string
+
+	b   *Backend
+	ctx *taskContext
+}
+
+// Node is the outcome of running one pipeline node.
+type Node struct {
+	// Name is the node name.
+	Name string
+
+	// Recognition is the recognition type, "DirectHit" if empty. The
+	// recognition of type "Custom" calls the custom recognition registered on
+	// the resource as CustomRecognition, and hits if it succeeds. Any other
+	// recognition hits unless Miss is set, with Box and Detail as its result.
+	Recognition            string
+	CustomRecognition      string
+	CustomRecognitionParam string
+	Miss                   bool
+	Box                    Rect
+	Detail                 string
+
+	// Action is the action type, "DoNothing" if empty. The action of type
+	// "Custom" calls the custom action registered on the resource as
+	// CustomAction. Any other action succeeds unless ActionFailed is set.
+	Action            string
+	CustomAction      string
+	CustomActionParam string
+	ActionFailed      bool
+}
+
+// Stopping reports whether the tasker was asked to stop.
+func (t *Task) Stopping() bool {
+	t.b.mu.Lock()
+	defer t.b.mu.Unlock()
+	return t.ctx.tasker.stopping
+}
+
+// HasNode reports whether name is a node of the pipeline.
+func (t *Task) HasNode(name string) bool {
+	_, ok := t.NodeJSON(name)
+	return ok
+}
+
+// NodeJSON returns the definition of a node, with the overrides of the task
+// applied.
+func (t *Task) NodeJSON(name string) (string, bool) {
+	t.b.mu.Lock()
+	defer t.b.mu.Unlock()
+	node, ok := t.b.nodeLocked(t.ctx, name)
+	return string(node), ok
+}
+
+// DefaultNode returns the node that hits and acts as its pipeline definition
+// says. Only custom recognitions and actions are actually run.
+func (t *Task) DefaultNode(name string) Node {
+	node := Node{Name: name}
+	data, ok := t.NodeJSON(name)
+	if !ok {
+		return node
+	}
+	var def map[string]json.RawMessage
+	if json.Unmarshal([]byte(data), &def) != nil {
+		return node
+	}
+
+	var customReco, customAction map[string]json.RawMessage
+	node.Recognition, customReco = pipelineType(def["recognition"], def)
+	node.Action, customAction = pipelineType(def["action"], def)
+	node.CustomRecognition = unquote(customReco["custom_recognition"])
+	node.CustomRecognitionParam = paramString(customReco["custom_recognition_param"])
+	node.CustomAction = unquote(customAction["custom_action"])
+	node.CustomActionParam = paramString(customAction["custom_action_param"])
+	return node
+}
+
+// pipelineType reads a recognition or action field, which is either a type
+// name with its parameters beside it or an object with type and param.
+func pipelineType(field json.RawMessage, node map[string]json.RawMessage) (string, map[string]json.RawMessage) {
+	var v2 struct {
+		Type  string                     `json:"type"`
+		Param map[string]json.RawMessage `json:"param"`
+	}
+	if json.Unmarshal(field, &v2) == nil {
+		return v2.Type, v2.Param
+	}
+	return unquote(field), node
+}
+
+func unquote(data json.RawMessage) string {
+	var s string
+	json.Unmarshal(data, &s)
+	return s
+}
+
+func paramString(data json.RawMessage) string {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		return s
+	}
+	return string(data)
+}
+
+// RunNode recognizes and runs a node, emitting the node events and recording
+// the details the tasker reports. It returns whether the node was hit and
+// its action succeeded.
+func (t *Task) RunNode(n Node) bool {
+	if n.Recognition == "" {
+		n.Recognition = "DirectHit"
+	}
+	if n.Action == "" {
+		n.Action = "DoNothing"
+	}
+	b, ctx := t.b, t.ctx
+	sinks := b.contextSinks(ctx)
+
+	b.mu.Lock()
+	recoID := b.newID()
+	b.mu.Unlock()
+	recoDetail := map[string]any{"task_id": t.ID, "reco_id": recoID, "name": n.Name}
+	emit(sinks, ctx.handle, "Node.Recognition.Starting", recoDetail)
+
+	hit, box, detail := !n.Miss, n.Box, n.Detail
+	if n.Recognition == "Custom" {
+		hit, box, detail = t.runCustomRecognition(n)
+	}
+	if detail == "" {
+		detail = "{}"
+	}
+
+	b.mu.Lock()
+	ctx.tasker.recos[recoID] = &recoRecord{name: n.Name, algorithm: n.Recognition, hit: hit, box: box, detail: detail}
+	b.mu.Unlock()
+	if !hit {
+		emit(sinks, ctx.handle, "Node.Recognition.Failed", recoDetail)
+		return false
+	}
+	emit(sinks, ctx.handle, "Node.Recognition.Succeeded", recoDetail)
+
+	b.mu.Lock()
+	nodeID := b.newID()
+	actionID := b.newID()
+	node := &nodeRecord{name: n.Name, recoID: recoID}
+	ctx.tasker.nodes[nodeID] = node
+	ctx.task.nodes = append(ctx.task.nodes, nodeID)
+	ctx.task.hitCounts[n.Name]++
+	b.mu.Unlock()
+	nodeDetail := map[string]any{"task_id": t.ID, "node_id": nodeID, "name": n.Name}
+	actionDetail := map[string]any{"task_id": t.ID, "action_id": actionID, "name": n.Name}
+	emit(sinks, ctx.handle, "Node.PipelineNode.Starting", nodeDetail)
+	emit(sinks, ctx.handle, "Node.Action.Starting", actionDetail)
+
+	success := !n.ActionFailed
+	if n.Action == "Custom" {
+		success = t.runCustomAction(n, recoID, box)
+	}
+
+	b.mu.Lock()
+	ctx.tasker.actions[actionID] = &actionRecord{name: n.Name, action: n.Action, box: box, success: success, detail: "{}"}
+	node.actionID = actionID
+	node.completed = success
+	b.mu.Unlock()
+
+	status := "Failed"
+	if success {
+		status = "Succeeded"
+	}
+	emit(sinks, ctx.handle, "Node.Action."+status, actionDetail)
+	emit(sinks, ctx.handle, "Node.PipelineNode."+status, nodeDetail)
+	return success
+}
+
+// NextList emits the events of evaluating the next list of a node.
+func (t *Task) NextList(name string, next []string) {
+	list := make([]map[string]any, 0, len(next))
+	for _, n := range next {
+		list = append(list, map[string]any{"name": n, "jump_back": false, "anchor": false})
+	}
+	detail := map[string]any{"task_id": t.ID, "name": name, "list": list}
+	sinks := t.b.contextSinks(t.ctx)
+	emit(sinks, t.ctx.handle, "Node.NextList.Starting", detail)
+	emit(sinks, t.ctx.handle, "Node.NextList.Succeeded", detail)
+}
+
+// Path returns the names of the nodes run so far.
+func (t *Task) Path() []string {
+	t.b.mu.Lock()
+	defer t.b.mu.Unlock()
+	path := make([]string, 0, len(t.ctx.task.nodes))
+	for _, id := range t.ctx.task.nodes {
+		path = append(path, t.ctx.tasker.nodes[id].name)
+	}
+	return slices.Clip(path)
+}
+
+func (t *Task) runCustomRecognition(n Node) (bool, Rect, string) {
+	b, ctx := t.b, t.ctx
+
+	b.mu.Lock()
+	res, _ := lookup[*resource](b, ctx.tasker.res)
+	var reco customRecognition
+	if res != nil {
+		reco = res.recognitions[n.CustomRecognition]
+	}
+	img := &imageBuffer{}
+	if ctrl, ok := lookup[*controller](b, ctx.tasker.ctrl); ok {
+		*img = ctrl.image
+		img.data = slices.Clone(ctrl.image.data)
+	}
+	imgHandle := b.newHandle(img)
+	roi := b.newHandle(&rectBuffer{})
+	outBox := b.newHandle(&rectBuffer{})
+	outDetail := b.newHandle(&stringBuffer{})
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for _, h := range []uintptr{imgHandle, roi, outBox, outDetail} {
+			delete(b.objects, h)
+		}
+	}()
+
+	if reco.callback == nil {
+		return false, Rect{}, ""
+	}
+	ret := reco.callback(
+		ctx.handle, t.ID,
+		cStringPtr(n.Name), cStringPtr(n.CustomRecognition), cStringPtr(n.CustomRecognitionParam),
+		imgHandle, roi, reco.arg, outBox, outDetail,
+	)
+	if ret == 0 {
+		return false, Rect{}, `{"all":[],"best":null,"filtered":[]}`
+	}
+
+	r := b.rect(outBox)
+	box := Rect{int(r.x), int(r.y), int(r.w), int(r.h)}
+	result := map[string]any{"box": box, "detail": b.getString(outDetail)}
+	detail, _ := json.Marshal(map[string]any{"all": []any{result}, "best": result, "filtered": []any{result}})
+	return true, box, string(detail)
+}
+
+func (t *Task) runCustomAction(n Node, recoID int64, box Rect) bool {
+	b, ctx := t.b, t.ctx
+
+	b.mu.Lock()
+	res, _ := lookup[*resource](b, ctx.tasker.res)
+	var action customAction
+	if res != nil {
+		action = res.actions[n.CustomAction]
+	}
+	boxHandle := b.newHandle(&rectBuffer{x: int32(box[0]), y: int32(box[1]), w: int32(box[2]), h: int32(box[3])})
+	b.mu.Unlock()
+
+	defer b.destroy(boxHandle)
+
+	if action.callback == nil {
+		return false
+	}
+	ret := action.callback(
+		ctx.handle, t.ID,
+		cStringPtr(n.Name), cStringPtr(n.CustomAction), cStringPtr(n.CustomActionParam),
+		recoID, boxHandle, action.arg,
+	)
+	return ret != 0
+}
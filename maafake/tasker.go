@@ -0,0 +1,470 @@
+package maafake
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/native"
+)
+
+type taskRecord struct {
+	id       int64
+	entry    string
+	override map[string]json.RawMessage
+	nodes    []int64
+	status   int32
+	done     chan struct{}
+
+	anchors   map[string]string
+	hitCounts map[string]uint64
+}
+
+type nodeRecord struct {
+	name      string
+	recoID    int64
+	actionID  int64
+	completed bool
+}
+
+type recoRecord struct {
+	name      string
+	algorithm string
+	hit       bool
+	box       Rect
+	detail    string
+}
+
+type actionRecord struct {
+	name    string
+	action  string
+	box     Rect
+	success bool
+	detail  string
+}
+
+type tasker struct {
+	handle uintptr
+	res    uintptr
+	ctrl   uintptr
+
+	sinks        sinkSet
+	contextSinks sinkSet
+
+	tasks   map[int64]*taskRecord
+	nodes   map[int64]*nodeRecord
+	recos   map[int64]*recoRecord
+	actions map[int64]*actionRecord
+
+	pending  []*taskRecord
+	running  bool
+	stopping bool
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// close stops the worker and fails the tasks that have not started.
+func (t *tasker) close() {
+	t.stopOnce.Do(func() { close(t.stop) })
+}
+
+func (b *Backend) installTasker() {
+	native.MaaTaskerCreate = func() uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		t := &tasker{
+			tasks:   make(map[int64]*taskRecord),
+			nodes:   make(map[int64]*nodeRecord),
+			recos:   make(map[int64]*recoRecord),
+			actions: make(map[int64]*actionRecord),
+			wake:    make(chan struct{}, 1),
+			stop:    make(chan struct{}),
+		}
+		t.handle = b.newHandle(t)
+		go b.work(t)
+		return t.handle
+	}
+	native.MaaTaskerDestroy = func(handle uintptr) {
+		b.mu.Lock()
+		t, ok := lookup[*tasker](b, handle)
+		delete(b.objects, handle)
+		b.mu.Unlock()
+		if ok {
+			t.close()
+		}
+	}
+
+	native.MaaTaskerAddSink = func(handle uintptr, cb native.MaaEventCallback, arg uintptr) int64 {
+		return b.addTaskerSink(handle, cb, arg, func(t *tasker) *sinkSet { return &t.sinks })
+	}
+	native.MaaTaskerRemoveSink = func(handle uintptr, id int64) {
+		b.withTasker(handle, func(t *tasker) bool { t.sinks.remove(id); return true })
+	}
+	native.MaaTaskerClearSinks = func(handle uintptr) {
+		b.withTasker(handle, func(t *tasker) bool { t.sinks.clear(); return true })
+	}
+	native.MaaTaskerAddContextSink = func(handle uintptr, cb native.MaaEventCallback, arg uintptr) int64 {
+		return b.addTaskerSink(handle, cb, arg, func(t *tasker) *sinkSet { return &t.contextSinks })
+	}
+	native.MaaTaskerRemoveContextSink = func(handle uintptr, id int64) {
+		b.withTasker(handle, func(t *tasker) bool { t.contextSinks.remove(id); return true })
+	}
+	native.MaaTaskerClearContextSinks = func(handle uintptr) {
+		b.withTasker(handle, func(t *tasker) bool { t.contextSinks.clear(); return true })
+	}
+	native.MaaTaskerSetOption = func(handle uintptr, key native.MaaTaskerOption, value unsafe.Pointer, size uint64) bool {
+		return b.withTasker(handle, func(*tasker) bool { return true })
+	}
+
+	native.MaaTaskerBindResource = func(handle, res uintptr) bool {
+		return b.withTasker(handle, func(t *tasker) bool {
+			if _, ok := lookup[*resource](b, res); !ok && res != 0 {
+				return false
+			}
+			t.res = res
+			return true
+		})
+	}
+	native.MaaTaskerBindController = func(handle, ctrl uintptr) bool {
+		return b.withTasker(handle, func(t *tasker) bool {
+			if _, ok := lookup[*controller](b, ctrl); !ok && ctrl != 0 {
+				return false
+			}
+			t.ctrl = ctrl
+			return true
+		})
+	}
+	native.MaaTaskerInited = func(handle uintptr) bool {
+		return b.withTasker(handle, b.initedLocked)
+	}
+	native.MaaTaskerGetResource = func(handle uintptr) uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if t, ok := lookup[*tasker](b, handle); ok {
+			return t.res
+		}
+		return 0
+	}
+	native.MaaTaskerGetController = func(handle uintptr) uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if t, ok := lookup[*tasker](b, handle); ok {
+			return t.ctrl
+		}
+		return 0
+	}
+
+	native.MaaTaskerPostTask = func(handle uintptr, entry, override string) int64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		t, ok := lookup[*tasker](b, handle)
+		if !ok || !b.initedLocked(t) {
+			return 0
+		}
+		nodes := make(map[string]json.RawMessage)
+		if override != "" && !applyOverride(nodes, override) {
+			return 0
+		}
+		task := b.newTaskLocked(t, strings.Clone(entry))
+		task.override = nodes
+		task.status = statusPending
+		t.pending = append(t.pending, task)
+		t.running = true
+		select {
+		case t.wake <- struct{}{}:
+		default:
+		}
+		return task.id
+	}
+	// Direct recognitions and actions are not simulated.
+	native.MaaTaskerPostRecognition = func(handle uintptr, recoType, recoParam string, img uintptr) int64 {
+		return b.postFailed(handle)
+	}
+	native.MaaTaskerPostAction = func(handle uintptr, actionType, actionParam string, box uintptr, recoDetail string) int64 {
+		return b.postFailed(handle)
+	}
+	native.MaaTaskerStatus = func(handle uintptr, id int64) int32 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if task := b.taskLocked(handle, id); task != nil {
+			return task.status
+		}
+		return statusInvalid
+	}
+	native.MaaTaskerWait = func(handle uintptr, id int64) int32 {
+		b.mu.Lock()
+		task := b.taskLocked(handle, id)
+		b.mu.Unlock()
+		if task == nil {
+			return statusInvalid
+		}
+		<-task.done
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return task.status
+	}
+	native.MaaTaskerRunning = func(handle uintptr) bool {
+		return b.withTasker(handle, func(t *tasker) bool { return t.running })
+	}
+	native.MaaTaskerPostStop = func(handle uintptr) int64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		t, ok := lookup[*tasker](b, handle)
+		if !ok {
+			return 0
+		}
+		t.stopping = t.running
+		task := b.newTaskLocked(t, "")
+		task.status = statusSuccess
+		close(task.done)
+		return task.id
+	}
+	native.MaaTaskerStopping = func(handle uintptr) bool {
+		return b.withTasker(handle, func(t *tasker) bool { return t.stopping })
+	}
+	native.MaaTaskerClearCache = func(handle uintptr) bool {
+		return b.withTasker(handle, func(t *tasker) bool {
+			clear(t.nodes)
+			clear(t.recos)
+			clear(t.actions)
+			return true
+		})
+	}
+
+	native.MaaTaskerGetTaskDetail = func(handle uintptr, taskID int64, entry, nodeIDList uintptr, size *uint64, status *int32) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		task := b.taskLocked(handle, taskID)
+		if task == nil || !b.setStringLocked(entry, task.entry) {
+			return false
+		}
+		if nodeIDList == 0 {
+			*size = uint64(len(task.nodes))
+			return true
+		}
+		// nodeIDList is the address of the caller's int64 array.
+		list := *(**int64)(unsafe.Pointer(&nodeIDList))
+		n := copy(unsafe.Slice(list, *size), task.nodes)
+		*size = uint64(n)
+		if status != nil {
+			*status = task.status
+		}
+		return true
+	}
+	native.MaaTaskerGetNodeDetail = func(handle uintptr, nodeID int64, name uintptr, recoID, actionID *int64, completed *bool) bool {
+		return b.withTasker(handle, func(t *tasker) bool {
+			node, ok := t.nodes[nodeID]
+			if !ok || !b.setStringLocked(name, node.name) {
+				return false
+			}
+			*recoID, *actionID = node.recoID, node.actionID
+			*(*uint8)(unsafe.Pointer(completed)) = boolByte(node.completed)
+			return true
+		})
+	}
+	native.MaaTaskerGetRecognitionDetail = func(handle uintptr, recoID int64, name, algorithm uintptr, hit *bool, box, detail, raw, draws uintptr) bool {
+		return b.withTasker(handle, func(t *tasker) bool {
+			reco, ok := t.recos[recoID]
+			if !ok {
+				return false
+			}
+			*(*uint8)(unsafe.Pointer(hit)) = boolByte(reco.hit)
+			return b.setStringLocked(name, reco.name) &&
+				b.setStringLocked(algorithm, reco.algorithm) &&
+				b.setRectLocked(box, reco.box) &&
+				b.setStringLocked(detail, reco.detail)
+		})
+	}
+	native.MaaTaskerGetActionDetail = func(handle uintptr, actionID int64, name, action, box uintptr, success *bool, detail uintptr) bool {
+		return b.withTasker(handle, func(t *tasker) bool {
+			act, ok := t.actions[actionID]
+			if !ok {
+				return false
+			}
+			*(*uint8)(unsafe.Pointer(success)) = boolByte(act.success)
+			return b.setStringLocked(name, act.name) &&
+				b.setStringLocked(action, act.action) &&
+				b.setRectLocked(box, act.box) &&
+				b.setStringLocked(detail, act.detail)
+		})
+	}
+	native.MaaTaskerGetWaitFreezesDetail = func(handle uintptr, wfID int64, name, phase uintptr, success *bool, elapsedMs *uint64, recoIDList uintptr, recoIDListSize *uint64, roi uintptr) bool {
+		return false
+	}
+	native.MaaTaskerGetLatestNode = func(handle uintptr, name string, latestID *int64) bool {
+		return b.withTasker(handle, func(t *tasker) bool {
+			var latest int64
+			for id, node := range t.nodes {
+				if node.name == name && id > latest {
+					latest = id
+				}
+			}
+			*latestID = latest
+			return latest != 0
+		})
+	}
+	native.MaaTaskerOverridePipeline = func(handle uintptr, taskID int64, override string) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		task := b.taskLocked(handle, taskID)
+		return task != nil && task.status <= statusRunning && applyOverride(task.override, override)
+	}
+}
+
+// withTasker calls fn with the tasker of handle while holding b.mu, and
+// returns false if there is no such tasker.
+func (b *Backend) withTasker(handle uintptr, fn func(t *tasker) bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := lookup[*tasker](b, handle)
+	return ok && fn(t)
+}
+
+func (b *Backend) addTaskerSink(handle uintptr, cb native.MaaEventCallback, arg uintptr, set func(t *tasker) *sinkSet) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := lookup[*tasker](b, handle)
+	if !ok {
+		return 0
+	}
+	id := b.newID()
+	set(t).add(id, cb, arg)
+	return id
+}
+
+// initedLocked reports whether t has a loaded resource and a connected
+// controller. Callers hold b.mu.
+func (b *Backend) initedLocked(t *tasker) bool {
+	res, ok := lookup[*resource](b, t.res)
+	if !ok || !res.loaded {
+		return false
+	}
+	ctrl, ok := lookup[*controller](b, t.ctrl)
+	return ok && ctrl.connected
+}
+
+func (b *Backend) newTaskLocked(t *tasker, entry string) *taskRecord {
+	task := &taskRecord{
+		id:        b.newID(),
+		entry:     entry,
+		override:  make(map[string]json.RawMessage),
+		done:      make(chan struct{}),
+		anchors:   make(map[string]string),
+		hitCounts: make(map[string]uint64),
+	}
+	t.tasks[task.id] = task
+	return task
+}
+
+func (b *Backend) taskLocked(handle uintptr, id int64) *taskRecord {
+	t, ok := lookup[*tasker](b, handle)
+	if !ok {
+		return nil
+	}
+	return t.tasks[id]
+}
+
+func (b *Backend) postFailed(handle uintptr) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := lookup[*tasker](b, handle)
+	if !ok {
+		return 0
+	}
+	task := b.newTaskLocked(t, "")
+	task.status = statusFailure
+	close(task.done)
+	return task.id
+}
+
+// work runs the tasks posted to t one at a time until t is closed.
+func (b *Backend) work(t *tasker) {
+	for {
+		select {
+		case <-t.stop:
+			b.mu.Lock()
+			pending := t.pending
+			t.pending = nil
+			t.running = false
+			for _, task := range pending {
+				task.status = statusFailure
+				close(task.done)
+			}
+			b.mu.Unlock()
+			return
+		case <-t.wake:
+		}
+
+		for {
+			b.mu.Lock()
+			if len(t.pending) == 0 {
+				t.running = false
+				t.stopping = false
+				b.mu.Unlock()
+				break
+			}
+			task := t.pending[0]
+			t.pending = t.pending[1:]
+			stopping := t.stopping
+			if stopping {
+				task.status = statusFailure
+			} else {
+				task.status = statusRunning
+			}
+			b.mu.Unlock()
+
+			if stopping {
+				close(task.done)
+				continue
+			}
+			b.run(t, task)
+		}
+	}
+}
+
+func (b *Backend) run(t *tasker, task *taskRecord) {
+	b.mu.Lock()
+	ctx := &taskContext{tasker: t, task: task, override: make(map[string]json.RawMessage)}
+	ctx.handle = b.newHandle(ctx)
+	sinks := t.sinks.snapshot()
+	detail := map[string]any{"task_id": task.id, "entry": task.entry, "uuid": "", "hash": ""}
+	if ctrl, ok := lookup[*controller](b, t.ctrl); ok {
+		detail["uuid"] = ctrl.uuid
+	}
+	if res, ok := lookup[*resource](b, t.res); ok {
+		detail["hash"] = res.hash
+	}
+	b.mu.Unlock()
+
+	emit(sinks, t.handle, "Tasker.Task.Starting", detail)
+
+	runTask := b.RunTask
+	if runTask == nil {
+		runTask = func(task *Task) bool {
+			return task.HasNode(task.Entry) && task.RunNode(task.DefaultNode(task.Entry))
+		}
+	}
+	ok := runTask(&Task{ID: task.id, Entry: task.entry, b: b, ctx: ctx})
+
+	b.mu.Lock()
+	status, message := statusFailure, "Tasker.Task.Failed"
+	if ok {
+		status, message = statusSuccess, "Tasker.Task.Succeeded"
+	}
+	task.status = status
+	delete(b.objects, ctx.handle)
+	b.mu.Unlock()
+
+	emit(sinks, t.handle, message, detail)
+	close(task.done)
+}
+
+func boolByte(v bool) uint8 {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,187 @@
+package maafake
+
+import (
+	"slices"
+	"unsafe"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/native"
+)
+
+// AdbDevice is a device found by the ADB device search.
+type AdbDevice struct {
+	Name             string
+	AdbPath          string
+	Address          string
+	ScreencapMethods uint64
+	InputMethods     uint64
+	Config           string
+}
+
+// DesktopWindow is a window found by the desktop window search.
+type DesktopWindow struct {
+	Handle     unsafe.Pointer
+	ClassName  string
+	WindowName string
+}
+
+type adbDeviceList struct {
+	items []uintptr
+}
+
+type desktopWindowList struct {
+	items []uintptr
+}
+
+// SetAdbDevices sets the devices found by the ADB device search.
+func (b *Backend) SetAdbDevices(devices ...AdbDevice) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.adbDevices = slices.Clone(devices)
+}
+
+// SetDesktopWindows sets the windows found by the desktop window search.
+func (b *Backend) SetDesktopWindows(windows ...DesktopWindow) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.windows = slices.Clone(windows)
+}
+
+func (b *Backend) installToolkit() {
+	native.MaaToolkitConfigInitOption = func(userPath, defaultJSON string) bool { return true }
+
+	native.MaaToolkitAdbDeviceListCreate = func() uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.newHandle(&adbDeviceList{})
+	}
+	native.MaaToolkitAdbDeviceListDestroy = func(handle uintptr) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if list, ok := lookup[*adbDeviceList](b, handle); ok {
+			for _, item := range list.items {
+				delete(b.objects, item)
+			}
+		}
+		delete(b.objects, handle)
+	}
+	native.MaaToolkitAdbDeviceFind = func(handle uintptr) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.findAdbDevicesLocked(handle, "")
+	}
+	native.MaaToolkitAdbDeviceFindSpecified = func(adbPath string, handle uintptr) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.findAdbDevicesLocked(handle, adbPath)
+	}
+	native.MaaToolkitAdbDeviceListSize = func(handle uintptr) uint64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*adbDeviceList](b, handle)
+		if list == nil {
+			return 0
+		}
+		return uint64(len(list.items))
+	}
+	native.MaaToolkitAdbDeviceListAt = func(handle uintptr, index uint64) uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*adbDeviceList](b, handle)
+		if list == nil || index >= uint64(len(list.items)) {
+			return 0
+		}
+		return list.items[index]
+	}
+	native.MaaToolkitAdbDeviceGetName = func(h uintptr) string { return b.adbDevice(h).Name }
+	native.MaaToolkitAdbDeviceGetAdbPath = func(h uintptr) string { return b.adbDevice(h).AdbPath }
+	native.MaaToolkitAdbDeviceGetAddress = func(h uintptr) string { return b.adbDevice(h).Address }
+	native.MaaToolkitAdbDeviceGetScreencapMethods = func(h uintptr) uint64 { return b.adbDevice(h).ScreencapMethods }
+	native.MaaToolkitAdbDeviceGetInputMethods = func(h uintptr) uint64 { return b.adbDevice(h).InputMethods }
+	native.MaaToolkitAdbDeviceGetConfig = func(h uintptr) string { return b.adbDevice(h).Config }
+
+	native.MaaToolkitDesktopWindowListCreate = func() uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.newHandle(&desktopWindowList{})
+	}
+	native.MaaToolkitDesktopWindowListDestroy = func(handle uintptr) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if list, ok := lookup[*desktopWindowList](b, handle); ok {
+			for _, item := range list.items {
+				delete(b.objects, item)
+			}
+		}
+		delete(b.objects, handle)
+	}
+	native.MaaToolkitDesktopWindowFindAll = func(handle uintptr) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, ok := lookup[*desktopWindowList](b, handle)
+		if !ok {
+			return false
+		}
+		for _, w := range b.windows {
+			list.items = append(list.items, b.newHandle(&w))
+		}
+		return true
+	}
+	native.MaaToolkitDesktopWindowListSize = func(handle uintptr) uint64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*desktopWindowList](b, handle)
+		if list == nil {
+			return 0
+		}
+		return uint64(len(list.items))
+	}
+	native.MaaToolkitDesktopWindowListAt = func(handle uintptr, index uint64) uintptr {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list, _ := lookup[*desktopWindowList](b, handle)
+		if list == nil || index >= uint64(len(list.items)) {
+			return 0
+		}
+		return list.items[index]
+	}
+	native.MaaToolkitDesktopWindowGetHandle = func(h uintptr) unsafe.Pointer { return b.desktopWindow(h).Handle }
+	native.MaaToolkitDesktopWindowGetClassName = func(h uintptr) string { return b.desktopWindow(h).ClassName }
+	native.MaaToolkitDesktopWindowGetWindowName = func(h uintptr) string { return b.desktopWindow(h).WindowName }
+
+	native.MaaToolkitMacOSCheckPermission = func(perm native.MaaMacOSPermission) bool { return true }
+	native.MaaToolkitMacOSRequestPermission = func(perm native.MaaMacOSPermission) bool { return true }
+	native.MaaToolkitMacOSRevealPermissionSettings = func(perm native.MaaMacOSPermission) bool { return true }
+}
+
+// findAdbDevicesLocked fills a device list with the devices using adbPath,
+// or all devices if adbPath is empty. Callers hold b.mu.
+func (b *Backend) findAdbDevicesLocked(handle uintptr, adbPath string) bool {
+	list, ok := lookup[*adbDeviceList](b, handle)
+	if !ok {
+		return false
+	}
+	for _, d := range b.adbDevices {
+		if adbPath == "" || d.AdbPath == adbPath {
+			list.items = append(list.items, b.newHandle(&d))
+		}
+	}
+	return true
+}
+
+func (b *Backend) adbDevice(handle uintptr) AdbDevice {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if d, ok := lookup[*AdbDevice](b, handle); ok {
+		return *d
+	}
+	return AdbDevice{}
+}
+
+func (b *Backend) desktopWindow(handle uintptr) DesktopWindow {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if w, ok := lookup[*DesktopWindow](b, handle); ok {
+		return *w
+	}
+	return DesktopWindow{}
+}
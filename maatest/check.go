@@ -0,0 +1,98 @@
+package maatest
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+)
+
+// Check compares r with the expectations of c and returns one message per
+// unmet expectation.
+func Check(c Case, r *Result) []string {
+	var failures []string
+
+	want := c.Status
+	if want == maa.StatusInvalid {
+		want = maa.StatusSuccess
+	}
+	if r.Status != want {
+		failures = append(failures, fmt.Sprintf("status: got %s, want %s", r.Status, want))
+	}
+
+	path := r.Path()
+	if c.Path != nil && !slices.Equal(path, c.Path) {
+		failures = append(failures, "path mismatch (- want, + got):\n"+DiffPaths(c.Path, path))
+	}
+
+	for _, name := range c.Hit {
+		if !slices.Contains(path, name) {
+			failures = append(failures, fmt.Sprintf("node %s was not hit; path: %s", name, strings.Join(path, " -> ")))
+		}
+	}
+	for _, name := range c.NotHit {
+		if slices.Contains(path, name) {
+			failures = append(failures, fmt.Sprintf("node %s was hit; path: %s", name, strings.Join(path, " -> ")))
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Boxes)) {
+		want := c.Boxes[name]
+		node, ok := r.Node(name)
+		switch {
+		case !ok:
+			failures = append(failures, fmt.Sprintf("box of %s: node was not hit", name))
+		case !boxWithin(node.Box, want, c.BoxTolerance):
+			failures = append(failures, fmt.Sprintf("box of %s: got %v, want %v ± %d", name, node.Box, want, c.BoxTolerance))
+		}
+	}
+	return failures
+}
+
+func boxWithin(got, want maa.Rect, tolerance int) bool {
+	for i := range got {
+		if d := got[i] - want[i]; d > tolerance || d < -tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffPaths returns a line diff of two node paths. Nodes only in want are
+// prefixed with "-", nodes only in got with "+", and common nodes with spaces.
+func DiffPaths(want, got []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of want[i:] and got[j:].
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var b strings.Builder
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			fmt.Fprintf(&b, "  %s\n", want[i])
+			i++
+			j++
+		case i < len(want) && (j == len(got) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&b, "- %s\n", want[i])
+			i++
+		default:
+			fmt.Fprintf(&b, "+ %s\n", got[j])
+			j++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package maatest
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+)
+
+// ControllerFactory creates the controller of a case. The controller is
+// destroyed when the case finishes.
+type ControllerFactory func() (*maa.Controller, error)
+
+// Replay replays a recording written by a record controller.
+func Replay(path string) ControllerFactory {
+	return func() (*maa.Controller, error) {
+		return maa.NewReplayController(path)
+	}
+}
+
+// Images shows imgs in order. The screen moves to the next image after every
+// completed input action, as described on ScriptedController, and stays on the
+// last one.
+func Images(imgs ...image.Image) ControllerFactory {
+	return func() (*maa.Controller, error) {
+		if len(imgs) == 0 {
+			return nil, errors.New("no images")
+		}
+		return maa.NewCustomController(NewScriptedController(imgs...))
+	}
+}
+
+// ImageFiles is Images with the images decoded from PNG or JPEG files.
+func ImageFiles(paths ...string) ControllerFactory {
+	return func() (*maa.Controller, error) {
+		imgs := make([]image.Image, 0, len(paths))
+		for _, path := range paths {
+			img, err := decodeImage(path)
+			if err != nil {
+				return nil, err
+			}
+			imgs = append(imgs, img)
+		}
+		return Images(imgs...)()
+	}
+}
+
+// ImageDir is ImageFiles with the PNG and JPEG files of dir in name order.
+func ImageDir(dir string) ControllerFactory {
+	return func() (*maa.Controller, error) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		var paths []string
+		for _, e := range entries {
+			switch strings.ToLower(filepath.Ext(e.Name())) {
+			case ".png", ".jpg", ".jpeg":
+				if !e.IsDir() {
+					paths = append(paths, filepath.Join(dir, e.Name()))
+				}
+			}
+		}
+		slices.Sort(paths)
+		return ImageFiles(paths...)()
+	}
+}
+
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// ScriptedController is the custom controller behind Images. Every input
+// action succeeds, and each completed one moves the screen to the next image:
+// Click, Swipe, ClickKey, InputText and Scroll, a key press once the key is
+// released, and a touch gesture once its last contact is lifted. TouchDown,
+// TouchMove and RelativeMove alone do not change the screen, so a long press or
+// a multi-touch gesture sees one image until it ends.
+type ScriptedController struct {
+	maa.BlankController
+
+	images []image.Image
+
+	mu       sync.Mutex
+	current  int
+	contacts map[int32]bool
+}
+
+// NewScriptedController creates a controller showing imgs in order.
+func NewScriptedController(imgs ...image.Image) *ScriptedController {
+	return &ScriptedController{images: imgs}
+}
+
+// Current returns the index of the image on screen.
+func (c *ScriptedController) Current() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+func (c *ScriptedController) RequestUUID() (string, bool) {
+	return "maatest-scripted", true
+}
+
+func (c *ScriptedController) Screencap() (image.Image, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.images) == 0 {
+		return nil, false
+	}
+	return c.images[c.current], true
+}
+
+func (c *ScriptedController) advance() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advanceLocked()
+	return true
+}
+
+func (c *ScriptedController) advanceLocked() {
+	if c.current < len(c.images)-1 {
+		c.current++
+	}
+}
+
+func (c *ScriptedController) Click(x, y int32) bool { return c.advance() }
+
+func (c *ScriptedController) Swipe(x1, y1, x2, y2, duration int32) bool { return c.advance() }
+
+func (c *ScriptedController) TouchDown(contact, x, y, pressure int32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.contacts == nil {
+		c.contacts = make(map[int32]bool)
+	}
+	c.contacts[contact] = true
+	return true
+}
+
+func (c *ScriptedController) TouchMove(contact, x, y, pressure int32) bool { return true }
+
+func (c *ScriptedController) TouchUp(contact int32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.contacts, contact)
+	if len(c.contacts) == 0 {
+		c.advanceLocked()
+	}
+	return true
+}
+
+func (c *ScriptedController) KeyDown(keycode int32) bool { return true }
+
+func (c *ScriptedController) KeyUp(keycode int32) bool { return c.advance() }
+
+func (c *ScriptedController) RelativeMove(dx, dy int32) bool { return true }
+
+func (c *ScriptedController) ClickKey(keycode int32) bool { return c.advance() }
+
+func (c *ScriptedController) InputText(text string) bool { return c.advance() }
+
+func (c *ScriptedController) Scroll(dx, dy int32) bool { return c.advance() }
@@ -0,0 +1,210 @@
+// Package maatest runs table-driven pipeline tests.
+//
+// Each Case names a controller, the bundles to load, an entry and an optional
+// override, plus what the run is expected to do: the sequence of executed
+// nodes, the nodes hit and not hit, recognition boxes and the final status.
+//
+//	func TestPipeline(t *testing.T) {
+//		maatest.Run(t, []maatest.Case{{
+//			Name:       "wilderness",
+//			Controller: maatest.Replay("./data_set/PipelineSmoking/MaaRecording.jsonl"),
+//			Bundles:    []string{"./data_set/PipelineSmoking/resource"},
+//			Entry:      "Wilderness",
+//			NotHit:     []string{"Flee"},
+//		}})
+//	}
+//
+// To find the nodes a suite never runs, share one maa.CoverageCollector as
+// Case.Coverage and report its profile with maa.NewCoverageReport.
+//
+// maa.Init must have been called, typically in TestMain.
+package maatest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+)
+
+// Case is one pipeline run and its expectations.
+type Case struct {
+	Name       string
+	Controller ControllerFactory
+	// Bundles are loaded in order, so later bundles override earlier ones.
+	Bundles  []string
+	Entry    string
+	Override any
+
+	// Path is the expected sequence of executed nodes. It is not checked when nil.
+	Path []string
+	// Hit lists nodes that must be executed.
+	Hit []string
+	// NotHit lists nodes that must not be executed.
+	NotHit []string
+	// Boxes maps a node to the box its first recognition is expected to find.
+	Boxes map[string]maa.Rect
+	// BoxTolerance is the distance each coordinate of a box may be off by.
+	BoxTolerance int
+	// Status is the expected final status. The zero value means maa.StatusSuccess.
+	Status maa.Status
+
+	// Coverage, if set, records the pipeline coverage of the run. Share one
+	// collector between cases to get the coverage of a whole suite.
+	Coverage *maa.CoverageCollector
+}
+
+// NodeResult is one executed node of a Result.
+type NodeResult struct {
+	Name string
+	// Algorithm, Hit and Box describe the recognition of the node, if any.
+	Algorithm string
+	Hit       bool
+	Box       maa.Rect
+	// Action and ActionSuccess describe the action of the node, if any.
+	Action        string
+	ActionSuccess bool
+	Completed     bool
+}
+
+// Result is what a Case run did.
+type Result struct {
+	Status maa.Status
+	Nodes  []NodeResult
+}
+
+// Path returns the names of the executed nodes in order.
+func (r *Result) Path() []string {
+	path := make([]string, len(r.Nodes))
+	for i, n := range r.Nodes {
+		path[i] = n.Name
+	}
+	return path
+}
+
+// Node returns the first execution of name.
+func (r *Result) Node(name string) (NodeResult, bool) {
+	for _, n := range r.Nodes {
+		if n.Name == name {
+			return n, true
+		}
+	}
+	return NodeResult{}, false
+}
+
+// Run runs every case as a subtest of t and checks its expectations.
+func Run(t *testing.T, cases []Case) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			RunCase(t, c)
+		})
+	}
+}
+
+// RunCase runs c, reports every unmet expectation on t and returns the result.
+// It stops the test if the case could not be run.
+func RunCase(t testing.TB, c Case) *Result {
+	t.Helper()
+	result, err := Execute(c)
+	if err != nil {
+		t.Fatalf("maatest: %v", err)
+		return nil
+	}
+	for _, failure := range Check(c, result) {
+		t.Error(failure)
+	}
+	return result
+}
+
+// Execute runs c without checking its expectations.
+func Execute(c Case) (*Result, error) {
+	if c.Controller == nil {
+		return nil, errors.New("case has no controller")
+	}
+	if c.Entry == "" {
+		return nil, errors.New("case has no entry")
+	}
+
+	res, err := maa.NewResource()
+	if err != nil {
+		return nil, err
+	}
+	defer res.Destroy()
+	for _, bundle := range c.Bundles {
+		if !res.PostBundle(bundle).Wait().Success() {
+			return nil, fmt.Errorf("failed to load bundle %s", bundle)
+		}
+	}
+
+	ctrl, err := c.Controller()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create controller: %w", err)
+	}
+	defer ctrl.Destroy()
+	if !ctrl.PostConnect().Wait().Success() {
+		return nil, errors.New("failed to connect controller")
+	}
+
+	tasker, err := maa.NewTasker()
+	if err != nil {
+		return nil, err
+	}
+	defer tasker.Destroy()
+	if err := tasker.BindResource(res); err != nil {
+		return nil, err
+	}
+	if err := tasker.BindController(ctrl); err != nil {
+		return nil, err
+	}
+	if !tasker.Initialized() {
+		return nil, errors.New("tasker failed to initialize")
+	}
+	if c.Coverage != nil {
+		c.Coverage.Attach(tasker)
+	}
+
+	var job *maa.TaskJob
+	if c.Override != nil {
+		job = tasker.PostTask(c.Entry, c.Override)
+	} else {
+		job = tasker.PostTask(c.Entry)
+	}
+	job.Wait()
+	if err := job.Error(); err != nil {
+		return nil, err
+	}
+
+	detail, err := job.GetDetail()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task detail: %w", err)
+	}
+	nodes := make([]*maa.NodeDetail, 0, len(detail.Nodes))
+	for _, ref := range detail.Nodes {
+		node, err := ref.GetDetail()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node detail: %w", err)
+		}
+		nodes = append(nodes, node)
+	}
+	return newResult(job.Status(), nodes), nil
+}
+
+func newResult(status maa.Status, nodes []*maa.NodeDetail) *Result {
+	r := &Result{Status: status, Nodes: make([]NodeResult, 0, len(nodes))}
+	for _, node := range nodes {
+		n := NodeResult{Name: node.Name, Completed: node.RunCompleted}
+		if reco := node.Recognition; reco != nil {
+			n.Algorithm = reco.Algorithm
+			n.Hit = reco.Hit
+			n.Box = reco.Box
+		}
+		if act := node.Action; act != nil {
+			n.Action = act.Action
+			n.ActionSuccess = act.Success
+		}
+		r.Nodes = append(r.Nodes, n)
+	}
+	return r
+}
@@ -0,0 +1,136 @@
+package maatest
+
+import (
+	"image"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/MaaXYZ/maa-framework-go/v4"
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	fake := maafake.New()
+	// Node definitions are returned as registered, so use the normalized form.
+	err := fake.AddBundle("bundle", map[string]any{
+		"Start": map[string]any{
+			"next":     []map[string]any{{"name": "End"}},
+			"on_error": []map[string]any{{"name": "Recover"}},
+		},
+		"End": map[string]any{},
+	})
+	if err != nil {
+		panic(err)
+	}
+	if err := maa.Init(maa.WithNativeBackend(fake)); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = maa.Release()
+	os.Exit(code)
+}
+
+func TestDiffPaths(t *testing.T) {
+	got := DiffPaths([]string{"Start", "Fight", "End"}, []string{"Start", "Flee", "Rest", "End"})
+	require.Equal(t, "  Start\n- Fight\n+ Flee\n+ Rest\n  End\n", got)
+
+	require.Equal(t, "+ A\n", DiffPaths(nil, []string{"A"}))
+	require.Equal(t, "- A\n", DiffPaths([]string{"A"}, nil))
+}
+
+func TestCheck(t *testing.T) {
+	result := newResult(maa.StatusFailure, []*maa.NodeDetail{
+		{Name: "Start", Recognition: &maa.RecognitionDetail{Algorithm: "OCR", Hit: true, Box: maa.Rect{10, 10, 50, 20}}, RunCompleted: true},
+		{Name: "Flee", Action: &maa.ActionDetail{Action: "Click", Success: true}},
+	})
+	require.Equal(t, []string{"Start", "Flee"}, result.Path())
+
+	c := Case{
+		Path:         []string{"Start", "Fight"},
+		Hit:          []string{"Start", "Fight"},
+		NotHit:       []string{"Flee"},
+		Boxes:        map[string]maa.Rect{"Start": {12, 9, 50, 20}, "Fight": {}},
+		BoxTolerance: 1,
+	}
+	failures := Check(c, result)
+	require.Len(t, failures, 6)
+	require.Equal(t, "status: got failure, want success", failures[0])
+	require.Contains(t, failures[1], "- Fight\n+ Flee\n")
+	require.Equal(t, "node Fight was not hit; path: Start -> Flee", failures[2])
+	require.Equal(t, "node Flee was hit; path: Start -> Flee", failures[3])
+	require.Equal(t, "box of Fight: node was not hit", failures[4])
+	require.True(t, strings.HasPrefix(failures[5], "box of Start: got [10 10 50 20]"), failures[5])
+
+	c = Case{
+		Path:         []string{"Start", "Flee"},
+		Hit:          []string{"Flee"},
+		Boxes:        map[string]maa.Rect{"Start": {12, 9, 50, 20}},
+		BoxTolerance: 2,
+		Status:       maa.StatusFailure,
+	}
+	require.Empty(t, Check(c, result))
+}
+
+func TestScriptedController(t *testing.T) {
+	first := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	second := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	c := NewScriptedController(first, second)
+
+	img, ok := c.Screencap()
+	require.True(t, ok)
+	require.Same(t, first, img)
+
+	require.True(t, c.Click(0, 0))
+	require.True(t, c.ClickKey(4))
+	require.Equal(t, 1, c.Current(), "stays on the last image")
+	img, _ = c.Screencap()
+	require.Same(t, second, img)
+
+	_, ok = NewScriptedController().Screencap()
+	require.False(t, ok)
+
+	// A two-finger long press moves on once both contacts are lifted.
+	c = NewScriptedController(first, second, first)
+	require.True(t, c.TouchDown(0, 1, 1, 0))
+	require.True(t, c.TouchDown(1, 2, 2, 0))
+	require.True(t, c.TouchMove(1, 3, 3, 0))
+	require.True(t, c.TouchUp(0))
+	require.Equal(t, 0, c.Current())
+	require.True(t, c.TouchUp(1))
+	require.Equal(t, 1, c.Current())
+
+	require.True(t, c.KeyDown(4))
+	require.True(t, c.RelativeMove(1, 1))
+	require.Equal(t, 1, c.Current())
+	require.True(t, c.KeyUp(4))
+	require.Equal(t, 2, c.Current())
+}
+
+func TestExecuteCoverage(t *testing.T) {
+	coverage := maa.NewCoverageCollector()
+	for range 2 {
+		result := RunCase(t, Case{
+			Controller: Images(image.NewRGBA(image.Rect(0, 0, 1, 1))),
+			Bundles:    []string{"bundle"},
+			Entry:      "Start",
+			Path:       []string{"Start"},
+			Coverage:   coverage,
+		})
+		require.NotNil(t, result)
+	}
+
+	profile := coverage.Profile()
+	require.Equal(t, uint64(2), profile.Tasks)
+	require.Equal(t, &maa.NodeCoverage{Entered: 2, RecognitionHit: 2, ActionSucceeded: 2}, profile.Nodes["Start"])
+
+	res, err := maa.NewResource()
+	require.NoError(t, err)
+	defer res.Destroy()
+	require.True(t, res.PostBundle("bundle").Wait().Success())
+	report, err := maa.NewCoverageReport(profile, res)
+	require.NoError(t, err)
+	require.Equal(t, []string{"End"}, report.UncoveredNodes())
+	require.Equal(t, []maa.CoverageBranch{maa.CoverageBranchRecognitionMiss, maa.CoverageBranchOnError}, report.Nodes[1].Uncovered)
+}
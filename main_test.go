@@ -3,13 +3,59 @@ package maa
 import (
 	"os"
 	"testing"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
 )
 
+var testInitOptions = []InitOption{
+	WithLogDir("./test/debug"),
+	WithStdoutLevel(LoggingLevelOff),
+}
+
 func TestMain(m *testing.M) {
-	Init(
-		WithLogDir("./test/debug"),
-		WithStdoutLevel(LoggingLevelOff),
-	)
+	Init(testInitOptions...)
 
 	os.Exit(m.Run())
 }
+
+// useFakeBackend switches the framework to a new maafake backend for the rest
+// of the test, and back to the libraries once it ends.
+func useFakeBackend(t *testing.T) *maafake.Backend {
+	t.Helper()
+	wasInited := IsInited()
+	require.NoError(t, Release())
+
+	fake := maafake.New()
+	require.NoError(t, Init(WithNativeBackend(fake)))
+	t.Cleanup(func() {
+		_ = Release()
+		if wasInited {
+			_ = Init(testInitOptions...)
+		}
+	})
+	return fake
+}
+
+// newFakeTasker returns an initialized tasker on fake, with a resource loading
+// pipeline and a connected blank controller.
+func newFakeTasker(t *testing.T, fake *maafake.Backend, pipeline map[string]any) *Tasker {
+	t.Helper()
+	require.NoError(t, fake.AddBundle("fake-bundle", pipeline))
+	res, err := NewResource()
+	require.NoError(t, err)
+	t.Cleanup(res.Destroy)
+	require.True(t, res.PostBundle("fake-bundle").Wait().Success())
+
+	ctrl, err := NewBlankController()
+	require.NoError(t, err)
+	t.Cleanup(ctrl.Destroy)
+	require.True(t, ctrl.PostConnect().Wait().Success())
+
+	tasker, err := NewTasker()
+	require.NoError(t, err)
+	t.Cleanup(tasker.Destroy)
+	require.NoError(t, tasker.BindResource(res))
+	require.NoError(t, tasker.BindController(ctrl))
+	return tasker
+}
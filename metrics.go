@@ -0,0 +1,475 @@
+package maa
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsOverflowLabel replaces label values beyond the cardinality limit.
+const MetricsOverflowLabel = "__other__"
+
+// DefaultMetricsBuckets are the histogram buckets, in seconds, used by default.
+var DefaultMetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type metricsConfig struct {
+	namespace      string
+	buckets        []float64
+	maxLabelValues int
+}
+
+// MetricsOption configures a MetricsCollector.
+type MetricsOption func(*metricsConfig)
+
+// WithMetricsNamespace sets the prefix of every metric name. Defaults to "maa".
+func WithMetricsNamespace(namespace string) MetricsOption {
+	return func(cfg *metricsConfig) {
+		cfg.namespace = namespace
+	}
+}
+
+// WithMetricsBuckets sets the upper bounds, in seconds, of every histogram.
+// Defaults to DefaultMetricsBuckets.
+func WithMetricsBuckets(buckets []float64) MetricsOption {
+	return func(cfg *metricsConfig) {
+		cfg.buckets = buckets
+	}
+}
+
+// WithMetricsMaxLabelValues bounds how many distinct values the entry, node,
+// algorithm and action labels may take per metric. Further values are reported
+// as MetricsOverflowLabel. Defaults to 200.
+func WithMetricsMaxLabelValues(n int) MetricsOption {
+	return func(cfg *metricsConfig) {
+		cfg.maxLabelValues = n
+	}
+}
+
+// MetricsCollector derives Prometheus metrics from tasker, controller and resource
+// events. It implements http.Handler and serves the text exposition format.
+//
+// Every attached object is labeled with a device name chosen by the caller.
+// Resource paths, task IDs and other unbounded values are never used as labels.
+type MetricsCollector struct {
+	cfg metricsConfig
+	now func() time.Time
+
+	mu         sync.Mutex
+	families   []*metricFamily
+	started    map[string]time.Time
+	algorithms map[string]string
+
+	tasks             *metricFamily
+	taskDuration      *metricFamily
+	nodeHits          *metricFamily
+	recognitions      *metricFamily
+	recoDuration      *metricFamily
+	ctrlActions       *metricFamily
+	ctrlDuration      *metricFamily
+	resourceLoads     *metricFamily
+	resourceDuration  *metricFamily
+	droppedLabelValue *metricFamily
+}
+
+// NewMetricsCollector creates a collector with no attached objects.
+func NewMetricsCollector(opts ...MetricsOption) *MetricsCollector {
+	cfg := metricsConfig{
+		namespace:      "maa",
+		buckets:        DefaultMetricsBuckets,
+		maxLabelValues: 200,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	cfg.buckets = slices.Sorted(slices.Values(cfg.buckets))
+	if cfg.maxLabelValues < 1 {
+		cfg.maxLabelValues = 1
+	}
+
+	m := &MetricsCollector{
+		cfg:        cfg,
+		now:        time.Now,
+		started:    make(map[string]time.Time),
+		algorithms: make(map[string]string),
+	}
+	m.tasks = m.family("tasks_total", "Finished tasks by entry and status.", metricCounter, "device", "entry", "status")
+	m.taskDuration = m.family("task_duration_seconds", "Task duration by entry.", metricHistogram, "device", "entry")
+	m.nodeHits = m.family("node_hits_total", "Pipeline nodes run by node and status.", metricCounter, "device", "node", "status")
+	m.recognitions = m.family("recognitions_total", "Recognitions by node, algorithm and result (hit or miss).", metricCounter, "device", "node", "algorithm", "result")
+	m.recoDuration = m.family("recognition_duration_seconds", "Recognition duration by node and algorithm.", metricHistogram, "device", "node", "algorithm")
+	m.ctrlActions = m.family("controller_actions_total", "Controller actions by action and status.", metricCounter, "device", "action", "status")
+	m.ctrlDuration = m.family("controller_action_duration_seconds", "Controller action latency by action.", metricHistogram, "device", "action")
+	m.resourceLoads = m.family("resource_loads_total", "Resource loads by status.", metricCounter, "device", "status")
+	m.resourceDuration = m.family("resource_load_duration_seconds", "Resource load duration.", metricHistogram, "device")
+	m.droppedLabelValue = m.family("metrics_label_overflow_total", "Observations whose label value was replaced by "+MetricsOverflowLabel+".", metricCounter, "metric")
+	return m
+}
+
+func recognitionAlgorithmOf(ctx *Context, name string) string {
+	if ctx == nil {
+		return "unknown"
+	}
+	node, err := ctx.GetNode(name)
+	if err != nil || node == nil {
+		return "unknown"
+	}
+	if node.Recognition == nil || node.Recognition.Type == "" {
+		return string(RecognitionTypeDirectHit)
+	}
+	return string(node.Recognition.Type)
+}
+
+// Attach registers the collector on tasker under device and returns the tasker
+// and context sink IDs.
+func (m *MetricsCollector) Attach(tasker *Tasker, device string) (sinkID, contextSinkID int64) {
+	sink := &metricsSink{m: m, device: device}
+	return tasker.AddSink(sink), tasker.AddContextSink(sink)
+}
+
+// AttachController registers the collector on ctrl under device and returns the sink ID.
+func (m *MetricsCollector) AttachController(ctrl *Controller, device string) int64 {
+	return ctrl.AddSink(&metricsSink{m: m, device: device})
+}
+
+// AttachResource registers the collector on res under device and returns the sink ID.
+func (m *MetricsCollector) AttachResource(res *Resource, device string) int64 {
+	return res.AddSink(&metricsSink{m: m, device: device})
+}
+
+// ServeHTTP implements http.Handler.
+func (m *MetricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format.
+func (m *MetricsCollector) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range m.families {
+		f.write(cw, m.cfg.buckets)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+	return cw.n, cw.err
+}
+
+// metricsSink is the sink registered for one device.
+type metricsSink struct {
+	m      *MetricsCollector
+	device string
+}
+
+// OnTaskerTask implements TaskerEventSink.
+func (s *metricsSink) OnTaskerTask(tasker *Tasker, status EventStatus, detail TaskerTaskDetail) {
+	elapsed, done := s.m.span(fmt.Sprintf("%s/task/%d", s.device, detail.TaskID), status)
+	if !done {
+		return
+	}
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.tasks.add(s.m, 1, s.device, detail.Entry, status.String())
+	if elapsed >= 0 {
+		s.m.taskDuration.observe(s.m, elapsed, s.device, detail.Entry)
+	}
+}
+
+// OnNodePipelineNode implements ContextEventSink.
+func (s *metricsSink) OnNodePipelineNode(ctx *Context, status EventStatus, detail NodePipelineNodeDetail) {
+	if status == EventStatusStarting {
+		return
+	}
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.nodeHits.add(s.m, 1, s.device, detail.Name, status.String())
+}
+
+// OnNodeRecognitionNode implements ContextEventSink.
+func (s *metricsSink) OnNodeRecognitionNode(ctx *Context, status EventStatus, detail NodeRecognitionNodeDetail) {
+}
+
+// OnNodeActionNode implements ContextEventSink.
+func (s *metricsSink) OnNodeActionNode(ctx *Context, status EventStatus, detail NodeActionNodeDetail) {
+}
+
+// OnNodeNextList implements ContextEventSink.
+func (s *metricsSink) OnNodeNextList(ctx *Context, status EventStatus, detail NodeNextListDetail) {}
+
+// OnNodeRecognition implements ContextEventSink.
+func (s *metricsSink) OnNodeRecognition(ctx *Context, status EventStatus, detail NodeRecognitionDetail) {
+	elapsed, done := s.m.span(fmt.Sprintf("%s/reco/%d/%s", s.device, detail.TaskID, detail.Name), status)
+	if !done {
+		return
+	}
+	algorithm := s.m.algorithmOf(ctx, detail.Name)
+	result := "hit"
+	if status != EventStatusSucceeded {
+		result = "miss"
+	}
+
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.recognitions.add(s.m, 1, s.device, detail.Name, algorithm, result)
+	if elapsed >= 0 {
+		s.m.recoDuration.observe(s.m, elapsed, s.device, detail.Name, algorithm)
+	}
+}
+
+// OnNodeAction implements ContextEventSink.
+func (s *metricsSink) OnNodeAction(ctx *Context, status EventStatus, detail NodeActionDetail) {}
+
+// OnControllerAction implements ControllerEventSink.
+func (s *metricsSink) OnControllerAction(ctrl *Controller, status EventStatus, detail ControllerActionDetail) {
+	elapsed, done := s.m.span(fmt.Sprintf("%s/ctrl/%d", s.device, detail.CtrlID), status)
+	if !done {
+		return
+	}
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.ctrlActions.add(s.m, 1, s.device, detail.Action, status.String())
+	if elapsed >= 0 {
+		s.m.ctrlDuration.observe(s.m, elapsed, s.device, detail.Action)
+	}
+}
+
+// OnResourceLoading implements ResourceEventSink.
+func (s *metricsSink) OnResourceLoading(res *Resource, status EventStatus, detail ResourceLoadingDetail) {
+	elapsed, done := s.m.span(fmt.Sprintf("%s/res/%d/%s", s.device, detail.ResID, detail.Path), status)
+	if !done {
+		return
+	}
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.resourceLoads.add(s.m, 1, s.device, status.String())
+	if elapsed >= 0 {
+		s.m.resourceDuration.observe(s.m, elapsed, s.device)
+	}
+}
+
+// span records the start of key on Starting and otherwise returns the elapsed
+// seconds since then, or -1 if the start was not seen.
+func (m *MetricsCollector) span(key string, status EventStatus) (float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if status == EventStatusStarting {
+		m.started[key] = now
+		return 0, false
+	}
+	start, ok := m.started[key]
+	if !ok {
+		return -1, true
+	}
+	delete(m.started, key)
+	return now.Sub(start).Seconds(), true
+}
+
+func (m *MetricsCollector) algorithmOf(ctx *Context, node string) string {
+	m.mu.Lock()
+	algorithm, ok := m.algorithms[node]
+	m.mu.Unlock()
+	if ok {
+		return algorithm
+	}
+	algorithm = recognitionAlgorithmOf(ctx, node)
+	m.mu.Lock()
+	// Async sinks have no context to look the node up, so their guess is not cached.
+	if ctx != nil && len(m.algorithms) < m.cfg.maxLabelValues {
+		m.algorithms[node] = algorithm
+	}
+	m.mu.Unlock()
+	return algorithm
+}
+
+type metricType string
+
+const (
+	metricCounter   metricType = "counter"
+	metricHistogram metricType = "histogram"
+)
+
+type metricFamily struct {
+	name   string
+	help   string
+	typ    metricType
+	labels []string
+	// values tracks the distinct values of every label but "device", "status" and "result".
+	values []map[string]struct{}
+	series map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labels  []string
+	value   float64
+	buckets []uint64
+	count   uint64
+}
+
+// family must be called before the collector is shared.
+func (m *MetricsCollector) family(name, help string, typ metricType, labels ...string) *metricFamily {
+	f := &metricFamily{
+		name:   m.cfg.namespace + "_" + name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		values: make([]map[string]struct{}, len(labels)),
+		series: make(map[string]*metricSeries),
+	}
+	for i := range f.values {
+		f.values[i] = make(map[string]struct{})
+	}
+	m.families = append(m.families, f)
+	return f
+}
+
+// bounded labels are capped by maxLabelValues; device, status and result are
+// chosen by the caller or drawn from a fixed set.
+func boundedLabel(name string) bool {
+	return name != "device" && name != "status" && name != "result"
+}
+
+// seriesFor must be called with m.mu held.
+func (f *metricFamily) seriesFor(m *MetricsCollector, values []string) *metricSeries {
+	values = slices.Clone(values)
+	for i, v := range values {
+		if !boundedLabel(f.labels[i]) {
+			continue
+		}
+		if _, ok := f.values[i][v]; ok {
+			continue
+		}
+		if len(f.values[i]) >= m.cfg.maxLabelValues {
+			values[i] = MetricsOverflowLabel
+			if f != m.droppedLabelValue {
+				m.droppedLabelValue.add(m, 1, f.name)
+			}
+			continue
+		}
+		f.values[i][v] = struct{}{}
+	}
+
+	key := strings.Join(values, "\x00")
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{labels: values}
+		if f.typ == metricHistogram {
+			s.buckets = make([]uint64, len(m.cfg.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// add must be called with m.mu held.
+func (f *metricFamily) add(m *MetricsCollector, delta float64, values ...string) {
+	f.seriesFor(m, values).value += delta
+}
+
+// observe must be called with m.mu held.
+func (f *metricFamily) observe(m *MetricsCollector, v float64, values ...string) {
+	s := f.seriesFor(m, values)
+	for i, le := range m.cfg.buckets {
+		if v <= le {
+			s.buckets[i]++
+		}
+	}
+	s.value += v
+	s.count++
+}
+
+func (f *metricFamily) write(w io.Writer, buckets []float64) {
+	if len(f.series) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeMetricHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+
+	for _, key := range slices.Sorted(maps.Keys(f.series)) {
+		s := f.series[key]
+		labels := f.formatLabels(s.labels)
+		if f.typ == metricCounter {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, labels, formatMetricValue(s.value))
+			continue
+		}
+		for i, le := range buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.formatLabels(s.labels, "le", formatMetricValue(le)), s.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.formatLabels(s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, labels, formatMetricValue(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, labels, s.count)
+	}
+}
+
+func (f *metricFamily) formatLabels(values []string, extra ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range f.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeMetricLabel(values[i]))
+		b.WriteByte('"')
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeMetricLabel(extra[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	metricHelpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeMetricLabel(s string) string { return metricLabelEscaper.Replace(s) }
+
+func escapeMetricHelp(s string) string { return metricHelpEscaper.Replace(s) }
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,109 @@
+package maa
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMetricsCollector(opts ...MetricsOption) (*MetricsCollector, func(time.Duration)) {
+	m := NewMetricsCollector(opts...)
+	clock := time.Unix(0, 0)
+	m.now = func() time.Time { return clock }
+	return m, func(d time.Duration) { clock = clock.Add(d) }
+}
+
+func TestMetricsCollector_Exposition(t *testing.T) {
+	m, step := newTestMetricsCollector(WithMetricsBuckets([]float64{1, 0.1}))
+	// Without a context, as for async sinks, the algorithm is unknown.
+	sink := &metricsSink{m: m, device: `emu "1"`}
+
+	sink.OnTaskerTask(nil, EventStatusStarting, TaskerTaskDetail{TaskID: 1, Entry: "Start"})
+	sink.OnNodeRecognition(nil, EventStatusStarting, NodeRecognitionDetail{TaskID: 1, Name: "A"})
+	step(50 * time.Millisecond)
+	sink.OnNodeRecognition(nil, EventStatusSucceeded, NodeRecognitionDetail{TaskID: 1, Name: "A"})
+	sink.OnNodeRecognition(nil, EventStatusStarting, NodeRecognitionDetail{TaskID: 1, Name: "A"})
+	step(500 * time.Millisecond)
+	sink.OnNodeRecognition(nil, EventStatusFailed, NodeRecognitionDetail{TaskID: 1, Name: "A"})
+	sink.OnNodePipelineNode(nil, EventStatusSucceeded, NodePipelineNodeDetail{TaskID: 1, Name: "A"})
+	sink.OnControllerAction(nil, EventStatusStarting, ControllerActionDetail{CtrlID: 3, Action: "click"})
+	step(20 * time.Millisecond)
+	sink.OnControllerAction(nil, EventStatusSucceeded, ControllerActionDetail{CtrlID: 3, Action: "click"})
+	sink.OnResourceLoading(nil, EventStatusStarting, ResourceLoadingDetail{ResID: 1, Path: "/bundle"})
+	step(2 * time.Second)
+	sink.OnResourceLoading(nil, EventStatusSucceeded, ResourceLoadingDetail{ResID: 1, Path: "/bundle"})
+	sink.OnTaskerTask(nil, EventStatusFailed, TaskerTaskDetail{TaskID: 1, Entry: "Start"})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	out := rec.Body.String()
+
+	for _, line := range []string{
+		`# TYPE maa_tasks_total counter`,
+		`maa_tasks_total{device="emu \"1\"",entry="Start",status="failed"} 1`,
+		`maa_node_hits_total{device="emu \"1\"",node="A",status="succeeded"} 1`,
+		`maa_recognitions_total{device="emu \"1\"",node="A",algorithm="unknown",result="hit"} 1`,
+		`maa_recognitions_total{device="emu \"1\"",node="A",algorithm="unknown",result="miss"} 1`,
+		`# TYPE maa_recognition_duration_seconds histogram`,
+		`maa_recognition_duration_seconds_bucket{device="emu \"1\"",node="A",algorithm="unknown",le="0.1"} 1`,
+		`maa_recognition_duration_seconds_bucket{device="emu \"1\"",node="A",algorithm="unknown",le="1"} 2`,
+		`maa_recognition_duration_seconds_bucket{device="emu \"1\"",node="A",algorithm="unknown",le="+Inf"} 2`,
+		`maa_recognition_duration_seconds_sum{device="emu \"1\"",node="A",algorithm="unknown"} 0.55`,
+		`maa_recognition_duration_seconds_count{device="emu \"1\"",node="A",algorithm="unknown"} 2`,
+		`maa_controller_action_duration_seconds_count{device="emu \"1\"",action="click"} 1`,
+		`maa_resource_load_duration_seconds_bucket{device="emu \"1\"",le="1"} 0`,
+		`maa_resource_loads_total{device="emu \"1\"",status="succeeded"} 1`,
+		`maa_task_duration_seconds_sum{device="emu \"1\"",entry="Start"} 2.57`,
+	} {
+		require.Contains(t, out, line+"\n")
+	}
+	require.NotContains(t, out, "/bundle")
+}
+
+func TestMetricsCollector_BoundedLabels(t *testing.T) {
+	m, _ := newTestMetricsCollector(WithMetricsMaxLabelValues(2))
+	sink := &metricsSink{m: m, device: "d"}
+	for _, name := range []string{"A", "B", "C", "D", "A"} {
+		sink.OnNodePipelineNode(nil, EventStatusSucceeded, NodePipelineNodeDetail{Name: name})
+	}
+
+	var b strings.Builder
+	_, err := m.WriteTo(&b)
+	require.NoError(t, err)
+	out := b.String()
+	require.Contains(t, out, `maa_node_hits_total{device="d",node="A",status="succeeded"} 2`)
+	require.Contains(t, out, `maa_node_hits_total{device="d",node="__other__",status="succeeded"} 2`)
+	require.Contains(t, out, `maa_metrics_label_overflow_total{metric="maa_node_hits_total"} 2`)
+	require.NotContains(t, out, `node="C"`)
+}
+
+func TestMetricsCollector_RecognitionAlgorithm(t *testing.T) {
+	fake := useFakeBackend(t)
+	fake.RunTask = func(task *maafake.Task) bool {
+		task.RunNode(task.DefaultNode("Match"))
+		miss := task.DefaultNode("Direct")
+		miss.Miss = true
+		task.RunNode(miss)
+		return true
+	}
+	tasker := newFakeTasker(t, fake, map[string]any{
+		"Match":  map[string]any{"recognition": map[string]any{"type": "TemplateMatch", "param": map[string]any{"template": []string{"a.png"}}}},
+		"Direct": map[string]any{},
+	})
+
+	m := NewMetricsCollector()
+	m.Attach(tasker, "d")
+	require.True(t, tasker.PostTask("Match").Wait().Success())
+
+	var b strings.Builder
+	_, err := m.WriteTo(&b)
+	require.NoError(t, err)
+	out := b.String()
+	require.Contains(t, out, `maa_recognitions_total{device="d",node="Match",algorithm="TemplateMatch",result="hit"} 1`+"\n")
+	require.Contains(t, out, `maa_recognitions_total{device="d",node="Direct",algorithm="DirectHit",result="miss"} 1`+"\n")
+}
@@ -0,0 +1,492 @@
+package maa
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"time"
+)
+
+type reportConfig struct {
+	title        string
+	maxImageSide int
+}
+
+// ReportOption configures an HTML run report.
+type ReportOption func(*reportConfig)
+
+// WithReportTitle sets the title of the report. Defaults to the task entry.
+func WithReportTitle(title string) ReportOption {
+	return func(cfg *reportConfig) {
+		cfg.title = title
+	}
+}
+
+// WithReportMaxImageSize downscales embedded images so that neither side exceeds
+// maxSide pixels. 0, the default, embeds images at their original size.
+func WithReportMaxImageSize(maxSide int) ReportOption {
+	return func(cfg *reportConfig) {
+		cfg.maxImageSide = maxSide
+	}
+}
+
+// WriteTraceReport writes a self-contained HTML report of a traced task to w.
+// Recognition images are only available if the collector was created with
+// WithTraceImages and the tasker ran with debug mode or save_draw enabled.
+func WriteTraceReport(w io.Writer, task *TraceTask, opts ...ReportOption) error {
+	if task == nil {
+		return errors.New("nil trace task")
+	}
+	cfg := reportConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	view := reportView{
+		Title:     cfg.title,
+		Generated: time.Now().Format(time.RFC3339),
+		Task:      newReportTask(task, cfg),
+	}
+	if view.Title == "" {
+		view.Title = "MaaFramework run report: " + task.Entry
+	}
+	view.Failures = collectReportFailures(view.Task, nil)
+	return reportTemplate.Execute(w, view)
+}
+
+// WriteTaskReport writes a self-contained HTML report of a finished task to w,
+// built from Tasker.GetTaskDetail. Such a report has no timings.
+func WriteTaskReport(w io.Writer, tasker *Tasker, taskID int64, opts ...ReportOption) error {
+	if tasker == nil {
+		return ErrInvalidTasker
+	}
+	task, err := traceFromTaskDetail(tasker, taskID)
+	if err != nil {
+		return err
+	}
+	return WriteTraceReport(w, task, opts...)
+}
+
+// reportTaskSource is the part of Tasker used to build a report without a trace.
+type reportTaskSource interface {
+	GetTaskDetail(taskId int64) (*TaskDetail, error)
+	GetNodeDetail(nodeId int64) (*NodeDetail, error)
+}
+
+// traceFromTaskDetail converts a task detail into an untimed trace.
+func traceFromTaskDetail(source reportTaskSource, taskID int64) (*TraceTask, error) {
+	detail, err := source.GetTaskDetail(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if detail == nil {
+		return nil, fmt.Errorf("task %d not found", taskID)
+	}
+
+	task := &TraceTask{
+		TaskID: uint64(detail.ID),
+		Entry:  detail.Entry,
+		Status: traceStatusOf(detail.Status.Success(), detail.Status.Failure()),
+	}
+	for _, ref := range detail.Nodes {
+		nodeDetail, err := source.GetNodeDetail(ref.ID())
+		if err != nil {
+			return nil, err
+		}
+		if nodeDetail == nil {
+			continue
+		}
+		node := &TraceNode{
+			Kind:   TraceNodePipeline,
+			NodeID: uint64(nodeDetail.ID),
+			Name:   nodeDetail.Name,
+			Status: traceStatusOf(nodeDetail.RunCompleted, !nodeDetail.RunCompleted),
+		}
+		if reco := nodeDetail.Recognition; reco != nil {
+			box := reco.Box
+			node.Recognitions = append(node.Recognitions, &TraceRecognition{
+				RecoID:    uint64(reco.ID),
+				Name:      reco.Name,
+				Status:    traceStatusOf(reco.Hit, !reco.Hit),
+				Algorithm: reco.Algorithm,
+				Hit:       reco.Hit,
+				Box:       &box,
+				Results:   rawJSONOrNil(reco.DetailJson),
+				Detail:    reco,
+			})
+		}
+		if action := nodeDetail.Action; action != nil {
+			box := action.Box
+			node.Action = &TraceAction{
+				ActionID: uint64(action.ID),
+				Name:     action.Name,
+				Status:   traceStatusOf(action.Success, !action.Success),
+				Action:   action.Action,
+				Box:      &box,
+				Success:  action.Success,
+				Result:   rawJSONOrNil(action.DetailJson),
+				Detail:   action,
+			}
+		}
+		task.Nodes = append(task.Nodes, node)
+	}
+	return task, nil
+}
+
+func traceStatusOf(succeeded, failed bool) string {
+	switch {
+	case succeeded:
+		return EventStatusSucceeded.String()
+	case failed:
+		return EventStatusFailed.String()
+	default:
+		return EventStatusUnknown.String()
+	}
+}
+
+type reportView struct {
+	Title     string
+	Generated string
+	Task      reportTask
+	Failures  []string
+}
+
+type reportTask struct {
+	TaskID   uint64
+	Entry    string
+	Status   string
+	Duration string
+	Nodes    []reportNode
+}
+
+type reportNode struct {
+	Index        int
+	Name         string
+	Kind         TraceNodeKind
+	Status       string
+	Offset       string
+	Duration     string
+	Bar          template.CSS
+	Failure      string
+	NextLists    []reportNextList
+	Recognitions []reportRecognition
+	Action       *reportAction
+}
+
+type reportNextList struct {
+	Candidates string
+	Status     string
+	Duration   string
+	Recos      []reportRecognition
+}
+
+type reportRecognition struct {
+	RecoID    uint64
+	Name      string
+	Algorithm string
+	Status    string
+	Hit       bool
+	Box       string
+	Duration  string
+	Results   string
+	Raw       template.URL
+	Draws     []template.URL
+	SubTasks  []reportTask
+}
+
+type reportAction struct {
+	ActionID uint64
+	Name     string
+	Action   string
+	Status   string
+	Success  bool
+	Box      string
+	Duration string
+	Result   string
+	SubTasks []reportTask
+}
+
+func newReportTask(task *TraceTask, cfg reportConfig) reportTask {
+	view := reportTask{
+		TaskID:   task.TaskID,
+		Entry:    task.Entry,
+		Status:   task.Status,
+		Duration: reportDuration(task.Start, task.End),
+	}
+	total := task.End.Sub(task.Start)
+	for i, node := range task.Nodes {
+		n := reportNode{
+			Index:    i + 1,
+			Name:     node.Name,
+			Kind:     node.Kind,
+			Status:   node.Status,
+			Duration: reportDuration(node.Start, node.End),
+		}
+		if !task.Start.IsZero() && !node.Start.IsZero() {
+			n.Offset = fmt.Sprintf("+%d ms", node.Start.Sub(task.Start).Milliseconds())
+			if total > 0 && !node.End.IsZero() {
+				left := float64(node.Start.Sub(task.Start)) / float64(total) * 100
+				width := max(float64(node.End.Sub(node.Start))/float64(total)*100, 0.5)
+				n.Bar = template.CSS(fmt.Sprintf("margin-left:%.2f%%;width:%.2f%%", left, width))
+			}
+		}
+		for _, next := range node.NextLists {
+			names := make([]string, len(next.List))
+			for j, item := range next.List {
+				names[j] = item.Name
+			}
+			l := reportNextList{
+				Candidates: strings.Join(names, ", "),
+				Status:     next.Status,
+				Duration:   reportDuration(next.Start, next.End),
+			}
+			for _, reco := range next.Recognitions {
+				l.Recos = append(l.Recos, newReportRecognition(reco, cfg))
+			}
+			n.NextLists = append(n.NextLists, l)
+		}
+		for _, reco := range node.Recognitions {
+			n.Recognitions = append(n.Recognitions, newReportRecognition(reco, cfg))
+		}
+		if node.Action != nil {
+			n.Action = newReportAction(node.Action, cfg)
+		}
+		n.Failure = reportNodeFailure(node)
+		view.Nodes = append(view.Nodes, n)
+	}
+	return view
+}
+
+func newReportRecognition(reco *TraceRecognition, cfg reportConfig) reportRecognition {
+	r := reportRecognition{
+		RecoID:    reco.RecoID,
+		Name:      reco.Name,
+		Algorithm: reco.Algorithm,
+		Status:    reco.Status,
+		Hit:       reco.Hit,
+		Duration:  reportDuration(reco.Start, reco.End),
+		Results:   reportJSON(reco.Results),
+	}
+	if reco.Box != nil {
+		r.Box = fmt.Sprint(*reco.Box)
+	}
+	if reco.Detail != nil {
+		r.Raw = reportImage(reco.Detail.Raw, cfg.maxImageSide)
+		for _, draw := range reco.Detail.Draws {
+			if uri := reportImage(draw, cfg.maxImageSide); uri != "" {
+				r.Draws = append(r.Draws, uri)
+			}
+		}
+	}
+	for _, sub := range reco.SubTasks {
+		r.SubTasks = append(r.SubTasks, newReportTask(sub, cfg))
+	}
+	return r
+}
+
+func newReportAction(action *TraceAction, cfg reportConfig) *reportAction {
+	a := &reportAction{
+		ActionID: action.ActionID,
+		Name:     action.Name,
+		Action:   action.Action,
+		Status:   action.Status,
+		Success:  action.Success,
+		Duration: reportDuration(action.Start, action.End),
+		Result:   reportJSON(action.Result),
+	}
+	if action.Box != nil {
+		a.Box = fmt.Sprint(*action.Box)
+	}
+	if action.Detail != nil && action.Detail.Result != nil {
+		if data, err := json.MarshalIndent(action.Detail.Result.Value(), "", "  "); err == nil {
+			a.Result = string(action.Detail.Result.Type()) + " " + string(data)
+		}
+	}
+	for _, sub := range action.SubTasks {
+		a.SubTasks = append(a.SubTasks, newReportTask(sub, cfg))
+	}
+	return a
+}
+
+// reportNodeFailure explains why a node failed, or returns "" if it did not.
+func reportNodeFailure(node *TraceNode) string {
+	for _, next := range node.NextLists {
+		if next.Status != EventStatusFailed.String() {
+			continue
+		}
+		names := make([]string, len(next.List))
+		for i, item := range next.List {
+			names[i] = item.Name
+		}
+		return fmt.Sprintf("none of the next nodes was recognized: %s", strings.Join(names, ", "))
+	}
+	if action := node.Action; action != nil && action.Status == EventStatusFailed.String() {
+		return fmt.Sprintf("action %s (%s) failed", action.Name, action.Action)
+	}
+	for _, reco := range node.Recognitions {
+		if reco.Status == EventStatusFailed.String() {
+			return fmt.Sprintf("recognition %s did not hit", reco.Name)
+		}
+	}
+	if node.Status == EventStatusFailed.String() {
+		return "node failed"
+	}
+	return ""
+}
+
+func collectReportFailures(task reportTask, out []string) []string {
+	for _, node := range task.Nodes {
+		if node.Failure != "" {
+			out = append(out, fmt.Sprintf("task %d, #%d %s: %s", task.TaskID, node.Index, node.Name, node.Failure))
+		}
+		recos := node.Recognitions
+		for _, l := range node.NextLists {
+			recos = append(recos, l.Recos...)
+		}
+		for _, reco := range recos {
+			for _, sub := range reco.SubTasks {
+				out = collectReportFailures(sub, out)
+			}
+		}
+		if node.Action != nil {
+			for _, sub := range node.Action.SubTasks {
+				out = collectReportFailures(sub, out)
+			}
+		}
+	}
+	return out
+}
+
+func reportDuration(start, end time.Time) string {
+	if start.IsZero() || end.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d ms", end.Sub(start).Milliseconds())
+}
+
+func reportJSON(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// reportImage encodes img as a PNG data URI, downscaled to maxSide if positive.
+func reportImage(img image.Image, maxSide int) template.URL {
+	if img == nil || img.Bounds().Empty() {
+		return ""
+	}
+	if maxSide > 0 {
+		img = capImageSize(img, maxSide)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return ""
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+// capImageSize scales img down with nearest-neighbor sampling so that neither
+// side exceeds maxSide, keeping the aspect ratio.
+func capImageSize(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+	nw, nh := maxSide, maxSide
+	if w >= h {
+		nh = max(h*maxSide/w, 1)
+	} else {
+		nw = max(w*maxSide/h, 1)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	for y := range nh {
+		sy := b.Min.Y + y*h/nh
+		for x := range nw {
+			dst.Set(x, y, img.At(b.Min.X+x*w/nw, sy))
+		}
+	}
+	return dst
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body{font-family:system-ui,sans-serif;margin:2em;color:#222}
+h1{font-size:1.4em}
+table{border-collapse:collapse;width:100%}
+td,th{border-bottom:1px solid #ddd;padding:4px 8px;text-align:left;vertical-align:top}
+.succeeded{color:#1a7f37}.failed{color:#cf222e}.starting,.unknown{color:#9a6700}
+.bar{background:#e8eef7;height:10px;min-width:200px}
+.bar div{background:#4a7bd0;height:10px}
+details{margin:4px 0 4px 1em}
+pre{background:#f6f8fa;padding:6px;overflow:auto;max-height:20em}
+img{max-width:100%;border:1px solid #ccc;margin:2px}
+.failures li{color:#cf222e}
+.sub{border-left:3px solid #ddd;padding-left:1em}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{.Generated}}</p>
+{{if .Failures}}<h2>Failures</h2><ul class="failures">{{range .Failures}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{template "task" .Task}}
+</body>
+</html>
+{{define "task"}}
+<h2>Task {{.TaskID}} {{.Entry}} <span class="{{.Status}}">{{.Status}}</span>{{with .Duration}} · {{.}}{{end}}</h2>
+<table>
+<tr><th>#</th><th>Node</th><th>Status</th><th>Start</th><th>Duration</th><th>Timeline</th></tr>
+{{range .Nodes}}
+<tr>
+<td>{{.Index}}</td>
+<td>{{.Name}} <small>{{.Kind}}</small>{{with .Failure}}<br><span class="failed">{{.}}</span>{{end}}</td>
+<td class="{{.Status}}">{{.Status}}</td>
+<td>{{.Offset}}</td>
+<td>{{.Duration}}</td>
+<td>{{if .Bar}}<div class="bar"><div style="{{.Bar}}"></div></div>{{end}}</td>
+</tr>
+<tr><td></td><td colspan="5">
+{{range .NextLists}}
+<details{{if eq .Status "failed"}} open{{end}}><summary>next [{{.Candidates}}] <span class="{{.Status}}">{{.Status}}</span>{{with .Duration}} · {{.}}{{end}}</summary>
+{{range .Recos}}{{template "reco" .}}{{end}}
+</details>
+{{end}}
+{{range .Recognitions}}{{template "reco" .}}{{end}}
+{{with .Action}}
+<details><summary>action {{.Name}} {{.Action}} <span class="{{.Status}}">{{.Status}}</span>{{with .Duration}} · {{.}}{{end}}</summary>
+<p>success: {{.Success}}{{with .Box}} · box {{.}}{{end}}</p>
+{{with .Result}}<pre>{{.}}</pre>{{end}}
+{{range .SubTasks}}<div class="sub">{{template "task" .}}</div>{{end}}
+</details>
+{{end}}
+</td></tr>
+{{end}}
+</table>
+{{end}}
+{{define "reco"}}
+<details><summary>recognition #{{.RecoID}} {{.Name}} {{.Algorithm}} <span class="{{.Status}}">{{if .Hit}}hit{{else}}{{.Status}}{{end}}</span>{{with .Duration}} · {{.}}{{end}}</summary>
+{{with .Box}}<p>box {{.}}</p>{{end}}
+{{with .Raw}}<p>raw<br><img src="{{.}}" alt="raw"></p>{{end}}
+{{if .Draws}}<p>draws<br>{{range .Draws}}<img src="{{.}}" alt="draw">{{end}}</p>{{end}}
+{{with .Results}}<pre>{{.}}</pre>{{end}}
+{{range .SubTasks}}<div class="sub">{{template "task" .}}</div>{{end}}
+</details>
+{{end}}
+`))
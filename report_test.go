@@ -0,0 +1,96 @@
+package maa
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testReportImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	return img
+}
+
+func TestCapImageSize(t *testing.T) {
+	img := testReportImage(40, 20)
+	require.Same(t, image.Image(img), capImageSize(img, 40))
+
+	capped := capImageSize(img, 10)
+	require.Equal(t, image.Rect(0, 0, 10, 5), capped.Bounds())
+	require.Equal(t, image.Rect(0, 0, 1, 10), capImageSize(testReportImage(2, 40), 10).Bounds())
+}
+
+func TestWriteTraceReport(t *testing.T) {
+	start := time.Unix(100, 0)
+	raw := testReportImage(64, 32)
+	task := &TraceTask{
+		TaskID: 1, Entry: "Start", Status: "failed",
+		Start: start, End: start.Add(100 * time.Millisecond),
+		Nodes: []*TraceNode{{
+			Kind: TraceNodePipeline, Name: "Start", Status: "failed",
+			Start: start, End: start.Add(80 * time.Millisecond),
+			NextLists: []*TraceNextList{{
+				Name: "Start", List: []NextItem{{Name: "A"}, {Name: "<B>"}}, Status: "failed",
+				Recognitions: []*TraceRecognition{{
+					RecoID: 7, Name: "A", Status: "failed", Algorithm: "TemplateMatch",
+					Box:     &Rect{1, 2, 3, 4},
+					Results: []byte(`{"best":null}`),
+					Detail:  &RecognitionDetail{Raw: raw, Draws: []image.Image{raw}},
+				}},
+			}},
+		}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteTraceReport(&buf, task, WithReportMaxImageSize(16)))
+	out := buf.String()
+
+	require.Contains(t, out, "<title>MaaFramework run report: Start</title>")
+	require.Contains(t, out, "none of the next nodes was recognized: A, &lt;B&gt;")
+	require.Equal(t, 2, strings.Count(out, `src="data:image/png;base64,`))
+	require.Contains(t, out, "margin-left:0.00%;width:80.00%")
+	require.Contains(t, out, "80 ms")
+	require.NotContains(t, out, "ZgotmplZ")
+
+	require.Error(t, WriteTraceReport(&buf, nil))
+}
+
+type fakeReportSource struct{}
+
+func (fakeReportSource) GetTaskDetail(taskId int64) (*TaskDetail, error) {
+	return &TaskDetail{ID: taskId, Entry: "Main", Status: StatusSuccess, Nodes: []NodeRef{{id: 3}}}, nil
+}
+
+func (fakeReportSource) GetNodeDetail(nodeId int64) (*NodeDetail, error) {
+	return &NodeDetail{
+		ID:           nodeId,
+		Name:         "Main",
+		RunCompleted: true,
+		Recognition:  &RecognitionDetail{ID: 4, Name: "Main", Algorithm: "OCR", Hit: true, Box: Rect{0, 0, 5, 5}},
+		Action:       &ActionDetail{ID: 5, Name: "Main", Action: "Click", Success: true, DetailJson: `{"point":[1,1]}`},
+	}, nil
+}
+
+func TestTraceFromTaskDetail(t *testing.T) {
+	task, err := traceFromTaskDetail(fakeReportSource{}, 9)
+	require.NoError(t, err)
+	require.Equal(t, "succeeded", task.Status)
+	require.Len(t, task.Nodes, 1)
+	require.True(t, task.Nodes[0].Recognitions[0].Hit)
+	require.Equal(t, "Click", task.Nodes[0].Action.Action)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteTraceReport(&buf, task, WithReportTitle("run")))
+	require.Contains(t, buf.String(), "<title>run</title>")
+	require.Contains(t, buf.String(), "&#34;point&#34;")
+}
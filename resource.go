@@ -44,9 +44,6 @@ func NewResource() (*Resource, error) {
 func (r *Resource) Destroy() {
 	store.ResStore.Lock()
 	value := store.ResStore.Get(r.handle)
-	for _, id := range value.SinkIDToEventCallbackID {
-		unregisterEventCallback(id)
-	}
 	for _, id := range value.CustomRecognizersCallbackID {
 		unregisterCustomRecognition(id)
 	}
@@ -56,7 +53,14 @@ func (r *Resource) Destroy() {
 	store.ResStore.Del(r.handle)
 	store.ResStore.Unlock()
 
+	// Unregistering waits for the async sink handlers still running.
+	for _, id := range value.SinkIDToEventCallbackID {
+		unregisterEventCallback(id)
+	}
+
 	native.MaaResourceDestroy(r.handle)
+
+	removeMaterializedDirs(value.MaterializedDirs)
 }
 
 func (r *Resource) setOption(key native.MaaResOption, value unsafe.Pointer, valSize uintptr) error {
@@ -100,9 +104,31 @@ func (r *Resource) setInference(ep native.MaaInferenceExecutionProvider, deviceI
 	if err := r.setInferenceDevice(deviceID); err != nil {
 		return err
 	}
+	store.ResStore.Update(r.handle, func(v *store.ResStoreValue) {
+		v.InferenceSet = true
+		v.InferenceExecutionProvider = int32(ep)
+		v.InferenceDevice = int32(deviceID)
+	})
 	return nil
 }
 
+// newLike creates an empty resource with the inference settings of r.
+func (r *Resource) newLike() (*Resource, error) {
+	value := store.ResStore.Get(r.handle)
+	res, err := NewResource()
+	if err != nil {
+		return nil, err
+	}
+	if value.InferenceSet {
+		ep := native.MaaInferenceExecutionProvider(value.InferenceExecutionProvider)
+		if err := res.setInference(ep, native.MaaInferenceDevice(value.InferenceDevice)); err != nil {
+			res.Destroy()
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
 // UseCPU uses CPU for inference.
 func (r *Resource) UseCPU() error {
 	return r.setInference(native.MaaInferenceExecutionProvider_CPU, native.MaaInferenceDevice_CPU)
@@ -406,13 +432,19 @@ func (r *Resource) GetNode(name string) (*Node, error) {
 	return &node, nil
 }
 
-// Clear clears loaded content.
-// This method fails if resources are currently loading.
+// Clear clears loaded content and removes the temporary directories of the
+// FS loaders. This method fails if resources are currently loading.
 func (r *Resource) Clear() error {
-	if native.MaaResourceClear(r.handle) {
-		return nil
+	if !native.MaaResourceClear(r.handle) {
+		return errors.New("failed to clear resource")
 	}
-	return errors.New("failed to clear resource")
+	var dirs []string
+	store.ResStore.Update(r.handle, func(v *store.ResStoreValue) {
+		dirs = v.MaterializedDirs
+		v.MaterializedDirs = nil
+	})
+	removeMaterializedDirs(dirs)
+	return nil
 }
 
 // status returns the loading status of a resource identified by id.
@@ -607,8 +639,9 @@ func (r *Resource) GetDefaultActionParam(actionType ActionType) (ActionParam, er
 
 // AddSink adds a event callback sink and returns the sink ID.
 // The sink ID can be used to remove the sink later.
-func (r *Resource) AddSink(sink ResourceEventSink) int64 {
-	id := registerEventCallback(sink)
+// By default the sink is called on the framework thread; see WithSinkAsync.
+func (r *Resource) AddSink(sink ResourceEventSink, opts ...SinkOption) int64 {
+	id := registerEventCallback(sink, opts...)
 	sinkId := native.MaaResourceAddSink(
 		r.handle,
 		_MaaEventCallbackAgent,
@@ -624,22 +657,35 @@ func (r *Resource) AddSink(sink ResourceEventSink) int64 {
 
 // RemoveSink removes a event callback sink by sink ID.
 func (r *Resource) RemoveSink(sinkId int64) {
+	var id uint64
 	store.ResStore.Update(r.handle, func(v *store.ResStoreValue) {
-		unregisterEventCallback(v.SinkIDToEventCallbackID[sinkId])
+		id = v.SinkIDToEventCallbackID[sinkId]
 		delete(v.SinkIDToEventCallbackID, sinkId)
 	})
+	unregisterEventCallback(id)
 
 	native.MaaResourceRemoveSink(r.handle, sinkId)
 }
 
+// SinkDropped returns the number of events the async sink sinkId dropped
+// because its queue was full.
+func (r *Resource) SinkDropped(sinkId int64) uint64 {
+	store.ResStore.Lock()
+	id := store.ResStore.Get(r.handle).SinkIDToEventCallbackID[sinkId]
+	store.ResStore.Unlock()
+	return sinkDropped(id)
+}
+
 // ClearSinks clears all event callback sinks.
 func (r *Resource) ClearSinks() {
+	var ids map[int64]uint64
 	store.ResStore.Update(r.handle, func(v *store.ResStoreValue) {
-		for _, id := range v.SinkIDToEventCallbackID {
-			unregisterEventCallback(id)
-		}
+		ids = v.SinkIDToEventCallbackID
 		v.SinkIDToEventCallbackID = make(map[int64]uint64)
 	})
+	for _, id := range ids {
+		unregisterEventCallback(id)
+	}
 
 	native.MaaResourceClearSinks(r.handle)
 }
@@ -0,0 +1,135 @@
+package maa
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/store"
+)
+
+// PostBundleFS asynchronously loads a bundle from fsys and returns a Job.
+// root is the bundle directory inside fsys; use "." for the root of fsys.
+//
+// fsys can be any fs.FS, including an embed.FS or a *zip.Reader. Since the
+// native loader only reads from disk, the files are first copied into a
+// temporary directory owned by the resource, which is removed by Clear or
+// Destroy.
+func (r *Resource) PostBundleFS(fsys fs.FS, root string) (*Job, error) {
+	dir, err := r.materializeFS(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	return r.PostBundle(dir), nil
+}
+
+// PostPipelineFS asynchronously loads a pipeline directory or json/jsonc file from fsys.
+// See PostBundleFS for how fsys is read.
+func (r *Resource) PostPipelineFS(fsys fs.FS, path string) (*Job, error) {
+	target, err := r.materializeFSPath(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	return r.PostPipeline(target), nil
+}
+
+// PostImageFS asynchronously loads an image directory or image file from fsys.
+// See PostBundleFS for how fsys is read.
+func (r *Resource) PostImageFS(fsys fs.FS, path string) (*Job, error) {
+	target, err := r.materializeFSPath(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	return r.PostImage(target), nil
+}
+
+// PostOcrModelFS asynchronously loads an OCR model directory from fsys.
+// See PostBundleFS for how fsys is read.
+func (r *Resource) PostOcrModelFS(fsys fs.FS, root string) (*Job, error) {
+	dir, err := r.materializeFS(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	return r.PostOcrModel(dir), nil
+}
+
+// PostBundleZip asynchronously loads a bundle from the zip archive at zipPath.
+// root is the bundle directory inside the archive; use "." for the archive root.
+func (r *Resource) PostBundleZip(zipPath, root string) (*Job, error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle archive: %w", err)
+	}
+	defer zr.Close()
+	return r.PostBundleFS(&zr.Reader, root)
+}
+
+// materializeFS copies the directory root of fsys into a new temporary directory
+// tracked by the resource and returns that directory.
+func (r *Resource) materializeFS(fsys fs.FS, root string) (string, error) {
+	sub, err := fs.Sub(fsys, root)
+	if err != nil {
+		return "", fmt.Errorf("invalid bundle root %q: %w", root, err)
+	}
+	info, err := fs.Stat(sub, ".")
+	if err != nil {
+		return "", fmt.Errorf("invalid bundle root %q: %w", root, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("bundle root %q is not a directory", root)
+	}
+
+	dir, err := r.newMaterializedDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.CopyFS(dir, sub); err != nil {
+		return "", fmt.Errorf("failed to copy bundle %q: %w", root, err)
+	}
+	return dir, nil
+}
+
+// materializeFSPath copies path, a file or directory of fsys, into a new temporary
+// directory tracked by the resource and returns the copied path.
+func (r *Resource) materializeFSPath(fsys fs.FS, path string) (string, error) {
+	info, err := fs.Stat(fsys, path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path %q: %w", path, err)
+	}
+	if info.IsDir() {
+		return r.materializeFS(fsys, path)
+	}
+
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %q: %w", path, err)
+	}
+	dir, err := r.newMaterializedDir()
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, info.Name())
+	if err := os.WriteFile(target, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to copy %q: %w", path, err)
+	}
+	return target, nil
+}
+
+func (r *Resource) newMaterializedDir() (string, error) {
+	dir, err := os.MkdirTemp("", "maa-resource-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create resource temp dir: %w", err)
+	}
+	store.ResStore.Update(r.handle, func(v *store.ResStoreValue) {
+		v.MaterializedDirs = append(v.MaterializedDirs, dir)
+	})
+	return dir, nil
+}
+
+func removeMaterializedDirs(dirs []string) {
+	for _, dir := range dirs {
+		_ = os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,127 @@
+package maa
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+// newFSTestResource returns a Resource backed only by store bookkeeping,
+// which is all the materialization helpers touch.
+func newFSTestResource(t *testing.T) *Resource {
+	t.Helper()
+	handle := uintptr(0xF5F5)
+	store.ResStore.Lock()
+	store.ResStore.Set(handle, store.ResStoreValue{})
+	store.ResStore.Unlock()
+	t.Cleanup(func() {
+		store.ResStore.Lock()
+		value := store.ResStore.Get(handle)
+		store.ResStore.Del(handle)
+		store.ResStore.Unlock()
+		removeMaterializedDirs(value.MaterializedDirs)
+		for _, dir := range value.MaterializedDirs {
+			_, err := os.Stat(dir)
+			require.True(t, os.IsNotExist(err))
+		}
+	})
+	return &Resource{handle: handle}
+}
+
+var testBundleFS = fstest.MapFS{
+	"assets/bundle/pipeline/main.json": {Data: []byte(`{"Start":{}}`)},
+	"assets/bundle/image/a.png":        {Data: []byte("png")},
+	"assets/other.txt":                 {Data: []byte("other")},
+}
+
+func TestResource_MaterializeFS(t *testing.T) {
+	res := newFSTestResource(t)
+
+	dir, err := res.materializeFS(testBundleFS, "assets/bundle")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "pipeline", "main.json"))
+	require.NoError(t, err)
+	require.Equal(t, `{"Start":{}}`, string(data))
+	_, err = os.Stat(filepath.Join(dir, "other.txt"))
+	require.True(t, os.IsNotExist(err))
+
+	store.ResStore.Lock()
+	dirs := store.ResStore.Get(res.handle).MaterializedDirs
+	store.ResStore.Unlock()
+	require.Equal(t, []string{dir}, dirs)
+}
+
+func TestResource_MaterializeFS_Invalid(t *testing.T) {
+	res := newFSTestResource(t)
+
+	_, err := res.materializeFS(testBundleFS, "missing")
+	require.Error(t, err)
+
+	_, err = res.materializeFS(testBundleFS, "assets/other.txt")
+	require.Error(t, err)
+
+	_, err = res.materializeFS(testBundleFS, "../escape")
+	require.Error(t, err)
+}
+
+func TestResource_MaterializeFSPath_File(t *testing.T) {
+	res := newFSTestResource(t)
+
+	target, err := res.materializeFSPath(testBundleFS, "assets/bundle/pipeline/main.json")
+	require.NoError(t, err)
+	require.Equal(t, "main.json", filepath.Base(target))
+
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	require.Equal(t, `{"Start":{}}`, string(data))
+}
+
+func TestResource_MaterializeFS_Zip(t *testing.T) {
+	res := newFSTestResource(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("bundle/pipeline/main.json")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`{"Zip":{}}`))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	dir, err := res.materializeFS(zr, "bundle")
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "pipeline", "main.json"))
+	require.NoError(t, err)
+	require.Equal(t, `{"Zip":{}}`, string(data))
+}
+
+func TestResource_ClearRemovesMaterializedDirs(t *testing.T) {
+	useFakeBackend(t)
+	res, err := NewResource()
+	require.NoError(t, err)
+	defer res.Destroy()
+
+	job, err := res.PostBundleFS(testBundleFS, "assets/bundle")
+	require.NoError(t, err)
+	job.Wait()
+	store.ResStore.Lock()
+	dirs := store.ResStore.Get(res.handle).MaterializedDirs
+	store.ResStore.Unlock()
+	require.Len(t, dirs, 1)
+
+	require.NoError(t, res.Clear())
+	_, err = os.Stat(dirs[0])
+	require.True(t, os.IsNotExist(err))
+	store.ResStore.Lock()
+	require.Empty(t, store.ResStore.Get(res.handle).MaterializedDirs)
+	store.ResStore.Unlock()
+}
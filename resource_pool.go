@@ -0,0 +1,256 @@
+package maa
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/store"
+)
+
+// InferenceMode selects the inference execution provider of a pooled resource.
+type InferenceMode int
+
+const (
+	// InferenceModeAuto keeps the framework default, see Resource.UseAutoExecutionProvider.
+	InferenceModeAuto InferenceMode = iota
+	// InferenceModeCPU applies Resource.UseCPU.
+	InferenceModeCPU
+	// InferenceModeDirectml applies Resource.UseDirectml with ResourceInference.Device.
+	InferenceModeDirectml
+	// InferenceModeCoreml applies Resource.UseCoreml with ResourceInference.Device.
+	InferenceModeCoreml
+)
+
+// ResourceInference describes the inference settings of a pooled resource.
+type ResourceInference struct {
+	Mode   InferenceMode
+	Device InferenceDevice
+}
+
+func (i ResourceInference) apply(r *Resource) error {
+	switch i.Mode {
+	case InferenceModeAuto:
+		return nil
+	case InferenceModeCPU:
+		return r.UseCPU()
+	case InferenceModeDirectml:
+		return r.UseDirectml(i.Device)
+	case InferenceModeCoreml:
+		return r.UseCoreml(i.Device)
+	default:
+		return fmt.Errorf("unknown inference mode: %d", i.Mode)
+	}
+}
+
+// ResourcePoolKey identifies a pooled resource.
+// Bundles are loaded in order, so the same paths in a different order are a different key.
+type ResourcePoolKey struct {
+	Bundles   []string
+	Inference ResourceInference
+}
+
+func (k ResourcePoolKey) id() string {
+	paths := make([]string, len(k.Bundles))
+	for i, path := range k.Bundles {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		paths[i] = filepath.Clean(path)
+	}
+	return fmt.Sprintf("%d/%d\x00%s", k.Inference.Mode, k.Inference.Device, strings.Join(paths, "\x00"))
+}
+
+var (
+	ErrResourcePoolNoBundles = errors.New("resource pool key needs at least one bundle")
+	ErrResourceReleased      = errors.New("pooled resource already released")
+)
+
+type resourcePoolEntry struct {
+	key   ResourcePoolKey
+	ready chan struct{}
+	res   *Resource
+	err   error
+	refs  int
+	// regMu serializes custom registrations on the shared resource.
+	regMu sync.Mutex
+}
+
+// ResourcePool shares loaded resources between taskers that use the same bundles
+// and inference settings. Resources are created and loaded on first Acquire and
+// destroyed when the last PooledResource is released.
+type ResourcePool struct {
+	mu      sync.Mutex
+	entries map[string]*resourcePoolEntry
+}
+
+// NewResourcePool creates an empty resource pool.
+func NewResourcePool() *ResourcePool {
+	return &ResourcePool{entries: make(map[string]*resourcePoolEntry)}
+}
+
+// Acquire returns a reference to the resource for key, creating and loading it if
+// no one holds it yet. Concurrent callers for the same key wait for a single load.
+// The returned PooledResource must be released with Release.
+func (p *ResourcePool) Acquire(key ResourcePoolKey) (*PooledResource, error) {
+	if len(key.Bundles) == 0 {
+		return nil, ErrResourcePoolNoBundles
+	}
+	id := key.id()
+
+	p.mu.Lock()
+	entry, ok := p.entries[id]
+	if ok {
+		entry.refs++
+		p.mu.Unlock()
+		<-entry.ready
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		return &PooledResource{pool: p, id: id, entry: entry}, nil
+	}
+	entry = &resourcePoolEntry{
+		key:   ResourcePoolKey{Bundles: append([]string(nil), key.Bundles...), Inference: key.Inference},
+		ready: make(chan struct{}),
+		refs:  1,
+	}
+	p.entries[id] = entry
+	p.mu.Unlock()
+
+	entry.res, entry.err = p.open(entry.key)
+	if entry.err != nil {
+		p.mu.Lock()
+		delete(p.entries, id)
+		p.mu.Unlock()
+	}
+	close(entry.ready)
+
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return &PooledResource{pool: p, id: id, entry: entry}, nil
+}
+
+func (p *ResourcePool) open(key ResourcePoolKey) (*Resource, error) {
+	res, err := NewResource()
+	if err != nil {
+		return nil, err
+	}
+	if err := key.Inference.apply(res); err != nil {
+		res.Destroy()
+		return nil, err
+	}
+	for _, path := range key.Bundles {
+		if status := res.PostBundle(path).Wait().Status(); !status.Success() {
+			res.Destroy()
+			return nil, fmt.Errorf("failed to load bundle %s: %s", path, status)
+		}
+	}
+	return res, nil
+}
+
+// Refs returns the number of live references to the resource for key.
+func (p *ResourcePool) Refs(key ResourcePoolKey) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.entries[key.id()]
+	if !ok {
+		return 0
+	}
+	return entry.refs
+}
+
+// Len returns the number of resources currently held by the pool.
+func (p *ResourcePool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+func (p *ResourcePool) release(id string, entry *resourcePoolEntry) {
+	p.mu.Lock()
+	entry.refs--
+	last := entry.refs == 0
+	if last && p.entries[id] == entry {
+		delete(p.entries, id)
+	}
+	p.mu.Unlock()
+
+	if last && entry.err == nil {
+		entry.res.Destroy()
+	}
+}
+
+// PooledResource is a reference to a shared Resource obtained from ResourcePool.Acquire.
+// The Resource must not be destroyed directly; call Release instead.
+type PooledResource struct {
+	pool  *ResourcePool
+	id    string
+	entry *resourcePoolEntry
+	once  sync.Once
+}
+
+// Resource returns the shared resource.
+func (p *PooledResource) Resource() *Resource {
+	return p.entry.res
+}
+
+// Key returns the key the resource was acquired with.
+func (p *PooledResource) Key() ResourcePoolKey {
+	return p.entry.key
+}
+
+// Release drops this reference. The resource is destroyed once every reference
+// has been released. Releasing twice returns ErrResourceReleased.
+func (p *PooledResource) Release() error {
+	released := false
+	p.once.Do(func() {
+		released = true
+		p.pool.release(p.id, p.entry)
+	})
+	if !released {
+		return ErrResourceReleased
+	}
+	return nil
+}
+
+// RegisterCustomRecognition registers recognition on the shared resource unless a
+// custom recognition with the same name is already registered, in which case the
+// existing one is kept. It reports whether the runner was registered.
+func (p *PooledResource) RegisterCustomRecognition(name string, recognition CustomRecognitionRunner) (bool, error) {
+	p.entry.regMu.Lock()
+	defer p.entry.regMu.Unlock()
+
+	if p.hasCustomRegistration(func(v store.ResStoreValue) map[string]uint64 { return v.CustomRecognizersCallbackID }, name) {
+		return false, nil
+	}
+	if err := p.entry.res.RegisterCustomRecognition(name, recognition); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RegisterCustomAction registers action on the shared resource unless a custom
+// action with the same name is already registered, in which case the existing
+// one is kept. It reports whether the runner was registered.
+func (p *PooledResource) RegisterCustomAction(name string, action CustomActionRunner) (bool, error) {
+	p.entry.regMu.Lock()
+	defer p.entry.regMu.Unlock()
+
+	if p.hasCustomRegistration(func(v store.ResStoreValue) map[string]uint64 { return v.CustomActionsCallbackID }, name) {
+		return false, nil
+	}
+	if err := p.entry.res.RegisterCustomAction(name, action); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *PooledResource) hasCustomRegistration(ids func(store.ResStoreValue) map[string]uint64, name string) bool {
+	store.ResStore.Lock()
+	defer store.ResStore.Unlock()
+	_, ok := ids(store.ResStore.Get(p.entry.res.handle))[name]
+	return ok
+}
@@ -0,0 +1,138 @@
+package maa
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/native"
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+type poolTestAction struct{}
+
+func (poolTestAction) Run(*Context, *CustomActionArg) bool { return true }
+
+type poolTestRecognition struct{}
+
+func (poolTestRecognition) Run(*Context, *CustomRecognitionArg) (*CustomRecognitionResult, bool) {
+	return nil, false
+}
+
+// newFakeResourcePool returns a pool on the fake backend, which can load the
+// bundles "base" and "overlay".
+func newFakeResourcePool(t *testing.T) (*ResourcePool, *maafake.Backend) {
+	t.Helper()
+	fake := useFakeBackend(t)
+	require.NoError(t, fake.AddBundle("base", map[string]any{"Base": map[string]any{}}))
+	require.NoError(t, fake.AddBundle("overlay", map[string]any{"Overlay": map[string]any{}}))
+	return NewResourcePool(), fake
+}
+
+func TestResourcePool_Sharing(t *testing.T) {
+	pool, fake := newFakeResourcePool(t)
+	key := ResourcePoolKey{Bundles: []string{"base", "overlay"}}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		refs []*PooledResource
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ref, err := pool.Acquire(key)
+			require.NoError(t, err)
+			mu.Lock()
+			refs = append(refs, ref)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, fake.Objects(), "a single resource is created")
+	require.Equal(t, 8, pool.Refs(key))
+	for _, ref := range refs[1:] {
+		require.Same(t, refs[0].Resource(), ref.Resource())
+	}
+	nodes, err := refs[0].Resource().GetNodeList()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Base", "Overlay"}, nodes)
+
+	other, err := pool.Acquire(ResourcePoolKey{Bundles: []string{"base", "overlay"}, Inference: ResourceInference{Mode: InferenceModeCPU}})
+	require.NoError(t, err)
+	require.NotSame(t, refs[0].Resource(), other.Resource())
+	ep, ok := fake.ResourceOption(other.Resource().handle, native.MaaResOption_InferenceExecutionProvider)
+	require.True(t, ok)
+	require.EqualValues(t, native.MaaInferenceExecutionProvider_CPU, binary.NativeEndian.Uint32(ep))
+	reordered, err := pool.Acquire(ResourcePoolKey{Bundles: []string{"overlay", "base"}})
+	require.NoError(t, err)
+	require.NotSame(t, refs[0].Resource(), reordered.Resource())
+	require.Equal(t, 3, pool.Len())
+
+	for _, ref := range refs[:7] {
+		require.NoError(t, ref.Release())
+	}
+	require.ErrorIs(t, refs[0].Release(), ErrResourceReleased)
+	require.Equal(t, 3, fake.Objects())
+	require.Equal(t, 1, pool.Refs(key))
+
+	require.NoError(t, refs[7].Release())
+	require.Equal(t, 2, fake.Objects(), "the last release destroys the resource")
+	require.Equal(t, 0, pool.Refs(key))
+
+	require.NoError(t, other.Release())
+	require.NoError(t, reordered.Release())
+	require.Equal(t, 0, pool.Len())
+	require.Equal(t, 0, fake.Objects())
+
+	// A released key is loaded again on the next Acquire.
+	ref, err := pool.Acquire(key)
+	require.NoError(t, err)
+	require.NotSame(t, refs[0].Resource(), ref.Resource())
+	require.NoError(t, ref.Release())
+}
+
+func TestResourcePool_LoadFailure(t *testing.T) {
+	pool, fake := newFakeResourcePool(t)
+
+	// The fake has no bundle at missing, so loading it fails.
+	_, err := pool.Acquire(ResourcePoolKey{Bundles: []string{"base", "missing"}})
+	require.ErrorContains(t, err, "failed to load bundle missing")
+	require.Equal(t, 0, fake.Objects(), "the resource is destroyed")
+	require.Equal(t, 0, pool.Len())
+
+	_, err = pool.Acquire(ResourcePoolKey{})
+	require.ErrorIs(t, err, ErrResourcePoolNoBundles)
+}
+
+func TestPooledResource_RegisterIsIdempotent(t *testing.T) {
+	pool, _ := newFakeResourcePool(t)
+	ref, err := pool.Acquire(ResourcePoolKey{Bundles: []string{"base"}})
+	require.NoError(t, err)
+	defer ref.Release()
+	other, err := pool.Acquire(ResourcePoolKey{Bundles: []string{"base"}})
+	require.NoError(t, err)
+	defer other.Release()
+
+	registered, err := ref.RegisterCustomAction("Tap", poolTestAction{})
+	require.NoError(t, err)
+	require.True(t, registered)
+	registered, err = ref.RegisterCustomRecognition("Digits", poolTestRecognition{})
+	require.NoError(t, err)
+	require.True(t, registered)
+
+	// Another user of the shared resource keeps the first registrations.
+	registered, err = other.RegisterCustomAction("Tap", poolTestAction{})
+	require.NoError(t, err)
+	require.False(t, registered)
+	registered, err = other.RegisterCustomRecognition("Digits", poolTestRecognition{})
+	require.NoError(t, err)
+	require.False(t, registered)
+
+	actions, err := ref.Resource().GetCustomActionList()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Tap"}, actions)
+}
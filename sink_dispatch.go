@@ -0,0 +1,208 @@
+package maa
+
+import (
+	"sync"
+)
+
+const defaultSinkQueueCapacity = 256
+
+type sinkConfig struct {
+	async    bool
+	capacity int
+	overflow OverflowPolicy
+}
+
+// SinkOption configures how events are delivered to a sink.
+type SinkOption func(*sinkConfig)
+
+// WithSinkAsync delivers the events of the sink on a Go worker instead of the
+// framework thread, so a slow handler does not hold up the task.
+//
+// Event payloads are copied and queued in order; each async sink has its own
+// queue and worker, so events reach one sink in the order they were emitted.
+// The native context only lives for the duration of the framework callback, so
+// async context sinks receive a nil *Context, as EventBus subscribers do. Sinks
+// that need the Context, such as Debugger and ContextStateRestorer, must be
+// added synchronously.
+//
+// Removing an async sink, or destroying its owner, waits for the handler that
+// is running, so a handler must not remove its own sink or destroy its owner.
+func WithSinkAsync() SinkOption {
+	return func(cfg *sinkConfig) {
+		cfg.async = true
+	}
+}
+
+// WithSinkCapacity sets the number of events an async sink can queue. It defaults to 256.
+// It implies WithSinkAsync.
+func WithSinkCapacity(capacity int) SinkOption {
+	return func(cfg *sinkConfig) {
+		cfg.async = true
+		cfg.capacity = max(capacity, 1)
+	}
+}
+
+// WithSinkOverflow sets what happens to an event when the queue of an async
+// sink is full. It defaults to OverflowDropNewest. It implies WithSinkAsync.
+func WithSinkOverflow(policy OverflowPolicy) SinkOption {
+	return func(cfg *sinkConfig) {
+		cfg.async = true
+		cfg.overflow = policy
+	}
+}
+
+func newSinkConfig(opts []SinkOption) sinkConfig {
+	cfg := sinkConfig{capacity: defaultSinkQueueCapacity}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	return cfg
+}
+
+// sinkEvent is a copied framework event waiting in an async sink queue.
+type sinkEvent struct {
+	handle  uintptr
+	msg     string
+	details []byte
+}
+
+// sinkQueue is the ordered event queue of one async sink.
+type sinkQueue struct {
+	capacity int
+	overflow OverflowPolicy
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	events  []sinkEvent
+	busy    bool
+	closed  bool
+	dropped uint64
+}
+
+func newSinkQueue(cb *eventCallback, cfg sinkConfig) *sinkQueue {
+	q := &sinkQueue{
+		capacity: cfg.capacity,
+		overflow: cfg.overflow,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	go q.run(cb)
+	return q
+}
+
+func (q *sinkQueue) push(ev sinkEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+
+	if len(q.events) >= q.capacity {
+		switch q.overflow {
+		case OverflowBlock:
+			for len(q.events) >= q.capacity && !q.closed {
+				q.cond.Wait()
+			}
+			if q.closed {
+				return
+			}
+		case OverflowDropOldest:
+			q.events[0] = sinkEvent{}
+			q.events = q.events[1:]
+			q.dropped++
+		default:
+			q.dropped++
+			return
+		}
+	}
+
+	q.events = append(q.events, ev)
+	q.cond.Broadcast()
+}
+
+func (q *sinkQueue) run(cb *eventCallback) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		for len(q.events) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.closed {
+			return
+		}
+
+		ev := q.events[0]
+		q.events[0] = sinkEvent{}
+		q.events = q.events[1:]
+		q.busy = true
+		q.cond.Broadcast()
+		q.mu.Unlock()
+
+		cb.handleRaw(ev.handle, ev.msg, ev.details)
+
+		q.mu.Lock()
+		q.busy = false
+		q.cond.Broadcast()
+	}
+}
+
+// flush waits until every queued event was handled.
+func (q *sinkQueue) flush() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for (len(q.events) > 0 || q.busy) && !q.closed {
+		q.cond.Wait()
+	}
+}
+
+// close stops the worker and waits for the event it is handling, if any.
+// Queued events that were not handled yet are discarded and publishers blocked
+// on a full queue are released.
+func (q *sinkQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.events = nil
+	q.cond.Broadcast()
+	for q.busy {
+		q.cond.Wait()
+	}
+}
+
+func (q *sinkQueue) droppedEvents() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// FlushSinks waits until every event queued for an async sink was handled.
+// Events emitted while it waits are waited for too. It is meant for tests and
+// for shutdown: call it before removing sinks, since removal discards the
+// events still queued.
+func FlushSinks() {
+	eventCallbacksMutex.RLock()
+	var queues []*sinkQueue
+	for _, cb := range eventCallbacks {
+		if cb.queue != nil {
+			queues = append(queues, cb.queue)
+		}
+	}
+	eventCallbacksMutex.RUnlock()
+
+	for _, q := range queues {
+		q.flush()
+	}
+}
+
+// sinkDropped returns the number of events the async sink registered as
+// callback id dropped, or 0 for synchronous and unknown sinks.
+func sinkDropped(id uint64) uint64 {
+	eventCallbacksMutex.RLock()
+	cb, ok := eventCallbacks[id]
+	eventCallbacksMutex.RUnlock()
+	if !ok || cb.queue == nil {
+		return 0
+	}
+	return cb.queue.droppedEvents()
+}
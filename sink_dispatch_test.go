@@ -0,0 +1,219 @@
+package maa
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// blockingTaskerSink records task IDs and blocks each call until release is closed.
+type blockingTaskerSink struct {
+	release chan struct{}
+	entered chan struct{}
+
+	mu  sync.Mutex
+	ids []uint64
+}
+
+func (s *blockingTaskerSink) OnTaskerTask(_ *Tasker, _ EventStatus, detail TaskerTaskDetail) {
+	select {
+	case s.entered <- struct{}{}:
+	default:
+	}
+	<-s.release
+	s.mu.Lock()
+	s.ids = append(s.ids, detail.TaskID)
+	s.mu.Unlock()
+}
+
+func (s *blockingTaskerSink) taskIDs() []uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint64(nil), s.ids...)
+}
+
+func newBlockingTaskerSink() *blockingTaskerSink {
+	return &blockingTaskerSink{release: make(chan struct{}), entered: make(chan struct{}, 1)}
+}
+
+func cString(s string) *byte {
+	b := append([]byte(s), 0)
+	return &b[0]
+}
+
+func emitTaskerTask(id uint64, taskID uint64) {
+	details := []byte(`{"task_id":` + strconv.FormatUint(taskID, 10) + `,"entry":"Start"}`)
+	buf := append(details, 0)
+	_MaaEventCallbackAgent(0, cString(EventTaskerTask.Starting()), &buf[0], uintptr(id))
+	// The framework reuses its buffers after the callback returns.
+	clear(buf)
+}
+
+func TestSinkDispatch_AsyncOrdered(t *testing.T) {
+	sink := newBlockingTaskerSink()
+	id := registerEventCallback(sink, WithSinkAsync())
+	defer unregisterEventCallback(id)
+
+	done := make(chan struct{})
+	go func() {
+		for i := range 5 {
+			emitTaskerTask(id, uint64(i+1))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("async sink blocked the callback")
+	}
+
+	close(sink.release)
+	FlushSinks()
+	require.Equal(t, []uint64{1, 2, 3, 4, 5}, sink.taskIDs())
+	require.Zero(t, sinkDropped(id))
+}
+
+func TestSinkDispatch_Overflow(t *testing.T) {
+	newest := newBlockingTaskerSink()
+	newestID := registerEventCallback(newest, WithSinkCapacity(2))
+	defer unregisterEventCallback(newestID)
+	oldest := newBlockingTaskerSink()
+	oldestID := registerEventCallback(oldest, WithSinkCapacity(2), WithSinkOverflow(OverflowDropOldest))
+	defer unregisterEventCallback(oldestID)
+
+	// The first event is taken by the worker, which blocks in the sink.
+	emitTaskerTask(newestID, 1)
+	emitTaskerTask(oldestID, 1)
+	<-newest.entered
+	<-oldest.entered
+	for i := 2; i <= 5; i++ {
+		emitTaskerTask(newestID, uint64(i))
+		emitTaskerTask(oldestID, uint64(i))
+	}
+
+	close(newest.release)
+	close(oldest.release)
+	FlushSinks()
+	require.Equal(t, []uint64{1, 2, 3}, newest.taskIDs())
+	require.Equal(t, uint64(2), sinkDropped(newestID))
+	require.Equal(t, []uint64{1, 4, 5}, oldest.taskIDs())
+	require.Equal(t, uint64(2), sinkDropped(oldestID))
+}
+
+func TestSinkDispatch_BlockReleasedOnRemove(t *testing.T) {
+	sink := newBlockingTaskerSink()
+	id := registerEventCallback(sink, WithSinkCapacity(1), WithSinkOverflow(OverflowBlock))
+
+	emitTaskerTask(id, 1)
+	<-sink.entered
+	emitTaskerTask(id, 2)
+
+	done := make(chan struct{})
+	go func() {
+		emitTaskerTask(id, 3)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("publisher did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	removed := make(chan struct{})
+	go func() {
+		unregisterEventCallback(id)
+		close(removed)
+	}()
+	<-done
+
+	// Removal waits for the handler that is running.
+	select {
+	case <-removed:
+		t.Fatal("removal did not wait for the running handler")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(sink.release)
+	<-removed
+	require.Equal(t, []uint64{1}, sink.taskIDs())
+	require.Zero(t, sinkDropped(id))
+}
+
+func TestSinkDispatch_AsyncContextIsNil(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		ctxs []*Context
+	)
+	record := func(ctx *Context, _ EventStatus, _ NodePipelineNodeDetail) {
+		mu.Lock()
+		defer mu.Unlock()
+		ctxs = append(ctxs, ctx)
+	}
+	syncID := registerEventCallback(&contextEventSinkAdapter{onNodePipelineNode: record})
+	defer unregisterEventCallback(syncID)
+	asyncID := registerEventCallback(&contextEventSinkAdapter{onNodePipelineNode: record}, WithSinkAsync())
+	defer unregisterEventCallback(asyncID)
+
+	details := cString(`{"task_id":1,"name":"Start"}`)
+	_MaaEventCallbackAgent(42, cString(EventNodePipelineNode.Starting()), details, uintptr(syncID))
+	_MaaEventCallbackAgent(42, cString(EventNodePipelineNode.Starting()), details, uintptr(asyncID))
+	FlushSinks()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []*Context{{handle: 42}, nil}, ctxs)
+}
+
+func TestSinkDispatch_SyncByDefault(t *testing.T) {
+	var got []uint64
+	id := registerEventCallback(&taskerEventSinkAdapter{onTaskerTask: func(_ EventStatus, d TaskerTaskDetail) {
+		got = append(got, d.TaskID)
+	}})
+	defer unregisterEventCallback(id)
+
+	emitTaskerTask(id, 7)
+	require.Equal(t, []uint64{7}, got)
+}
+
+func TestSinkDispatch_RemoveWhileHandlerUsesTasker(t *testing.T) {
+	fake := useFakeBackend(t)
+	tasker := newFakeTasker(t, fake, map[string]any{"Start": map[string]any{}})
+
+	entered := make(chan struct{})
+	proceed := make(chan struct{})
+	var once sync.Once
+	var sinkID int64
+	handler := func(EventStatus, TaskerTaskDetail) {
+		once.Do(func() {
+			close(entered)
+			<-proceed
+			// Both calls take the tasker store lock.
+			tasker.SinkDropped(sinkID)
+			tasker.RemoveSink(tasker.AddSink(&taskerEventSinkAdapter{}))
+		})
+	}
+	sinkID = tasker.AddSink(&taskerEventSinkAdapter{onTaskerTask: handler}, WithSinkAsync())
+	require.True(t, tasker.PostTask("Start").Wait().Success())
+	<-entered
+
+	removed := make(chan struct{})
+	go func() {
+		tasker.RemoveSink(sinkID)
+		close(removed)
+	}()
+	// Removal waits for the running handler.
+	select {
+	case <-removed:
+		t.Fatal("removal did not wait for the running handler")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(proceed)
+	select {
+	case <-removed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("removal deadlocked with the handler")
+	}
+}
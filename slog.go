@@ -0,0 +1,358 @@
+package maa
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Events logged by SlogSink for the runners wrapped with WrapCustomAction and
+// WrapCustomRecognition. The framework itself never emits them.
+const (
+	EventCustomAction      = Event("Custom.Action")
+	EventCustomRecognition = Event("Custom.Recognition")
+)
+
+var defaultSlogLevels = map[Event]slog.Level{
+	EventResourceLoading:     slog.LevelInfo,
+	EventControllerAction:    slog.LevelDebug,
+	EventTaskerTask:          slog.LevelInfo,
+	EventNodePipelineNode:    slog.LevelInfo,
+	EventNodeRecognitionNode: slog.LevelDebug,
+	EventNodeActionNode:      slog.LevelDebug,
+	EventNodeNextList:        slog.LevelDebug,
+	EventNodeRecognition:     slog.LevelDebug,
+	EventNodeAction:          slog.LevelDebug,
+	EventNodeWaitFreezes:     slog.LevelDebug,
+	EventCustomAction:        slog.LevelDebug,
+	EventCustomRecognition:   slog.LevelDebug,
+}
+
+type slogConfig struct {
+	levels            map[Event]slog.Level
+	failureLevel      slog.Level
+	recognitionDetail bool
+}
+
+// SlogOption configures a SlogSink.
+type SlogOption func(*slogConfig)
+
+// WithSlogLevel sets the level event is logged at.
+func WithSlogLevel(event Event, level slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.levels[event] = level
+	}
+}
+
+// WithSlogFailureLevel sets the lowest level failed events are logged at.
+// It defaults to slog.LevelWarn. Recognition events are not raised, since
+// their failure only means the target was not found.
+func WithSlogFailureLevel(level slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.failureLevel = level
+	}
+}
+
+// WithSlogRecognitionDetail controls whether finished Node.Recognition events
+// query the recognition detail to log the hit and box. It is enabled by default.
+func WithSlogRecognitionDetail(enabled bool) SlogOption {
+	return func(c *slogConfig) {
+		c.recognitionDetail = enabled
+	}
+}
+
+// SlogSink logs framework events with a *slog.Logger.
+// It implements TaskerEventSink, ContextEventSink, NodeWaitFreezesEventSink,
+// ControllerEventSink and ResourceEventSink.
+//
+// Every record has the event name as message and a status attribute, plus
+// task_id, node, reco_id, box and hit where the event carries them.
+type SlogSink struct {
+	logger *slog.Logger
+	cfg    slogConfig
+
+	// recognition fetches the detail of a finished recognition.
+	recognition func(ctx *Context, recoID uint64) *RecognitionDetail
+}
+
+// NewSlogSink creates a sink that logs to logger. A nil logger means slog.Default.
+func NewSlogSink(logger *slog.Logger, opts ...SlogOption) *SlogSink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	cfg := slogConfig{
+		levels:            make(map[Event]slog.Level, len(defaultSlogLevels)),
+		failureLevel:      slog.LevelWarn,
+		recognitionDetail: true,
+	}
+	for event, level := range defaultSlogLevels {
+		cfg.levels[event] = level
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &SlogSink{
+		logger:      logger,
+		cfg:         cfg,
+		recognition: slogRecognitionDetail,
+	}
+}
+
+func slogRecognitionDetail(ctx *Context, recoID uint64) *RecognitionDetail {
+	if ctx == nil {
+		return nil
+	}
+	detail, err := ctx.GetTasker().GetRecognitionDetail(int64(recoID))
+	if err != nil {
+		return nil
+	}
+	return detail
+}
+
+// Logger returns the logger the sink writes to.
+func (s *SlogSink) Logger() *slog.Logger {
+	return s.logger
+}
+
+// Attach registers the sink on tasker, makes its logger the one returned by
+// Context.Logger and returns both sink IDs.
+func (s *SlogSink) Attach(tasker *Tasker) (sinkID, contextSinkID int64) {
+	tasker.SetLogger(s.logger)
+	return tasker.AddSink(s), tasker.AddContextSink(s)
+}
+
+func (s *SlogSink) level(event Event, status EventStatus) slog.Level {
+	level, ok := s.cfg.levels[event]
+	if !ok {
+		level = slog.LevelInfo
+	}
+	if status == EventStatusFailed && level < s.cfg.failureLevel && !isRecognitionEvent(event) {
+		level = s.cfg.failureLevel
+	}
+	return level
+}
+
+func isRecognitionEvent(event Event) bool {
+	switch event {
+	case EventNodeRecognitionNode, EventNodeRecognition, EventCustomRecognition:
+		return true
+	}
+	return false
+}
+
+// log writes one record if its level is enabled. attrs is only called then,
+// so it may do work that is too costly for discarded records.
+func (s *SlogSink) log(event Event, status EventStatus, attrs func() []slog.Attr) {
+	level := s.level(event, status)
+	if !s.logger.Enabled(context.Background(), level) {
+		return
+	}
+	s.logger.LogAttrs(context.Background(), level, event.String(), append(attrs(), slog.String("status", status.String()))...)
+}
+
+// OnTaskerTask implements TaskerEventSink.
+func (s *SlogSink) OnTaskerTask(_ *Tasker, status EventStatus, detail TaskerTaskDetail) {
+	s.log(EventTaskerTask, status, func() []slog.Attr {
+		return []slog.Attr{
+			slog.Uint64("task_id", detail.TaskID),
+			slog.String("entry", detail.Entry),
+			slog.String("uuid", detail.UUID),
+		}
+	})
+}
+
+// OnNodePipelineNode implements ContextEventSink.
+func (s *SlogSink) OnNodePipelineNode(_ *Context, status EventStatus, detail NodePipelineNodeDetail) {
+	s.log(EventNodePipelineNode, status, func() []slog.Attr {
+		return []slog.Attr{
+			slog.Uint64("task_id", detail.TaskID),
+			slog.Uint64("node_id", detail.NodeID),
+			slog.String("node", detail.Name),
+		}
+	})
+}
+
+// OnNodeRecognitionNode implements ContextEventSink.
+func (s *SlogSink) OnNodeRecognitionNode(_ *Context, status EventStatus, detail NodeRecognitionNodeDetail) {
+	s.log(EventNodeRecognitionNode, status, func() []slog.Attr {
+		return []slog.Attr{
+			slog.Uint64("task_id", detail.TaskID),
+			slog.Uint64("node_id", detail.NodeID),
+			slog.String("node", detail.Name),
+		}
+	})
+}
+
+// OnNodeActionNode implements ContextEventSink.
+func (s *SlogSink) OnNodeActionNode(_ *Context, status EventStatus, detail NodeActionNodeDetail) {
+	s.log(EventNodeActionNode, status, func() []slog.Attr {
+		return []slog.Attr{
+			slog.Uint64("task_id", detail.TaskID),
+			slog.Uint64("node_id", detail.NodeID),
+			slog.String("node", detail.Name),
+		}
+	})
+}
+
+// OnNodeNextList implements ContextEventSink.
+func (s *SlogSink) OnNodeNextList(_ *Context, status EventStatus, detail NodeNextListDetail) {
+	s.log(EventNodeNextList, status, func() []slog.Attr {
+		next := make([]string, len(detail.List))
+		for i, item := range detail.List {
+			next[i] = item.Name
+		}
+		return []slog.Attr{
+			slog.Uint64("task_id", detail.TaskID),
+			slog.String("node", detail.Name),
+			slog.Any("next", next),
+		}
+	})
+}
+
+// OnNodeRecognition implements ContextEventSink.
+func (s *SlogSink) OnNodeRecognition(ctx *Context, status EventStatus, detail NodeRecognitionDetail) {
+	s.log(EventNodeRecognition, status, func() []slog.Attr {
+		attrs := []slog.Attr{
+			slog.Uint64("task_id", detail.TaskID),
+			slog.Uint64("reco_id", detail.RecognitionID),
+			slog.String("node", detail.Name),
+		}
+		if status == EventStatusStarting || !s.cfg.recognitionDetail {
+			return attrs
+		}
+		reco := s.recognition(ctx, detail.RecognitionID)
+		if reco == nil {
+			return attrs
+		}
+		attrs = append(attrs,
+			slog.String("algorithm", reco.Algorithm),
+			slog.Bool("hit", reco.Hit),
+		)
+		if reco.Hit {
+			attrs = append(attrs, slog.Any("box", reco.Box))
+		}
+		return attrs
+	})
+}
+
+// OnNodeAction implements ContextEventSink.
+func (s *SlogSink) OnNodeAction(_ *Context, status EventStatus, detail NodeActionDetail) {
+	s.log(EventNodeAction, status, func() []slog.Attr {
+		return []slog.Attr{
+			slog.Uint64("task_id", detail.TaskID),
+			slog.Uint64("action_id", detail.ActionID),
+			slog.String("node", detail.Name),
+		}
+	})
+}
+
+// OnNodeWaitFreezes implements NodeWaitFreezesEventSink.
+func (s *SlogSink) OnNodeWaitFreezes(_ *Context, status EventStatus, detail NodeWaitFreezesDetail) {
+	s.log(EventNodeWaitFreezes, status, func() []slog.Attr {
+		return []slog.Attr{
+			slog.Uint64("task_id", detail.TaskID),
+			slog.Uint64("wf_id", detail.WaitFreezesID),
+			slog.String("node", detail.Name),
+			slog.String("phase", detail.Phase),
+		}
+	})
+}
+
+// OnControllerAction implements ControllerEventSink.
+func (s *SlogSink) OnControllerAction(_ *Controller, status EventStatus, detail ControllerActionDetail) {
+	s.log(EventControllerAction, status, func() []slog.Attr {
+		return []slog.Attr{
+			slog.Uint64("ctrl_id", detail.CtrlID),
+			slog.String("action", detail.Action),
+		}
+	})
+}
+
+// OnResourceLoading implements ResourceEventSink.
+func (s *SlogSink) OnResourceLoading(_ *Resource, status EventStatus, detail ResourceLoadingDetail) {
+	s.log(EventResourceLoading, status, func() []slog.Attr {
+		return []slog.Attr{
+			slog.Uint64("res_id", detail.ResID),
+			slog.String("path", detail.Path),
+			slog.String("hash", detail.Hash),
+		}
+	})
+}
+
+// WrapCustomAction returns a runner that logs every call of action as a
+// Custom.Action event. During the call ctx.Logger returns the sink logger with
+// the task_id and node attributes already attached.
+func (s *SlogSink) WrapCustomAction(action CustomActionRunner) CustomActionRunner {
+	return &slogCustomAction{s: s, action: action}
+}
+
+// WrapCustomRecognition returns a runner that logs every call of recognition as
+// a Custom.Recognition event. During the call ctx.Logger returns the sink logger
+// with the task_id and node attributes already attached.
+func (s *SlogSink) WrapCustomRecognition(recognition CustomRecognitionRunner) CustomRecognitionRunner {
+	return &slogCustomRecognition{s: s, recognition: recognition}
+}
+
+type slogCustomAction struct {
+	s      *SlogSink
+	action CustomActionRunner
+}
+
+func (a *slogCustomAction) Run(ctx *Context, arg *CustomActionArg) bool {
+	attrs := []slog.Attr{
+		slog.Int64("task_id", arg.TaskID),
+		slog.String("node", arg.CurrentTaskName),
+		slog.String("name", arg.CustomActionName),
+	}
+	if arg.RecognitionDetail != nil {
+		attrs = append(attrs, slog.Int64("reco_id", arg.RecognitionDetail.ID))
+	}
+	attrs = append(attrs, slog.Any("box", arg.Box))
+
+	ctx.logger = a.s.logger.With("task_id", arg.TaskID, "node", arg.CurrentTaskName)
+	a.s.log(EventCustomAction, EventStatusStarting, func() []slog.Attr { return attrs })
+
+	start := time.Now()
+	ok := a.action.Run(ctx, arg)
+	status := EventStatusSucceeded
+	if !ok {
+		status = EventStatusFailed
+	}
+	a.s.log(EventCustomAction, status, func() []slog.Attr {
+		return append(attrs, slog.Duration("duration", time.Since(start)))
+	})
+	return ok
+}
+
+type slogCustomRecognition struct {
+	s           *SlogSink
+	recognition CustomRecognitionRunner
+}
+
+func (r *slogCustomRecognition) Run(ctx *Context, arg *CustomRecognitionArg) (*CustomRecognitionResult, bool) {
+	attrs := []slog.Attr{
+		slog.Int64("task_id", arg.TaskID),
+		slog.String("node", arg.CurrentTaskName),
+		slog.String("name", arg.CustomRecognitionName),
+		slog.Any("roi", arg.Roi),
+	}
+
+	ctx.logger = r.s.logger.With("task_id", arg.TaskID, "node", arg.CurrentTaskName)
+	r.s.log(EventCustomRecognition, EventStatusStarting, func() []slog.Attr { return attrs })
+
+	start := time.Now()
+	result, ok := r.recognition.Run(ctx, arg)
+	hit := ok && result != nil
+	status := EventStatusSucceeded
+	if !hit {
+		status = EventStatusFailed
+	}
+	r.s.log(EventCustomRecognition, status, func() []slog.Attr {
+		done := append(attrs, slog.Bool("hit", hit))
+		if hit {
+			done = append(done, slog.Any("box", result.Box))
+		}
+		return append(done, slog.Duration("duration", time.Since(start)))
+	})
+	return result, ok
+}
@@ -0,0 +1,137 @@
+package maa
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSlogSink(t *testing.T, opts ...SlogOption) (*SlogSink, func() []map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	sink := NewSlogSink(logger, opts...)
+	return sink, func() []map[string]any {
+		var records []map[string]any
+		dec := json.NewDecoder(&buf)
+		for dec.More() {
+			var record map[string]any
+			require.NoError(t, dec.Decode(&record))
+			delete(record, "time")
+			delete(record, "duration")
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+func TestSlogSink_Events(t *testing.T) {
+	sink, records := newTestSlogSink(t, WithSlogLevel(EventNodeAction, slog.LevelInfo))
+	sink.recognition = func(_ *Context, recoID uint64) *RecognitionDetail {
+		return &RecognitionDetail{ID: int64(recoID), Algorithm: "OCR", Hit: true, Box: Rect{1, 2, 3, 4}}
+	}
+
+	sink.OnTaskerTask(nil, EventStatusStarting, TaskerTaskDetail{TaskID: 1, Entry: "Start"})
+	sink.OnNodeRecognition(nil, EventStatusSucceeded, NodeRecognitionDetail{TaskID: 1, RecognitionID: 7, Name: "A"})
+	sink.OnNodeAction(nil, EventStatusFailed, NodeActionDetail{TaskID: 1, ActionID: 8, Name: "A"})
+	sink.OnNodeRecognitionNode(nil, EventStatusFailed, NodeRecognitionNodeDetail{TaskID: 1, NodeID: 9, Name: "B"})
+	sink.OnNodeWaitFreezes(nil, EventStatusSucceeded, NodeWaitFreezesDetail{TaskID: 1, WaitFreezesID: 10, Name: "B", Phase: "post"})
+
+	require.Equal(t, []map[string]any{
+		{"level": "INFO", "msg": "Tasker.Task", "task_id": 1.0, "entry": "Start", "uuid": "", "status": "starting"},
+		{"level": "DEBUG", "msg": "Node.Recognition", "task_id": 1.0, "reco_id": 7.0, "node": "A",
+			"algorithm": "OCR", "hit": true, "box": []any{1.0, 2.0, 3.0, 4.0}, "status": "succeeded"},
+		{"level": "WARN", "msg": "Node.Action", "task_id": 1.0, "action_id": 8.0, "node": "A", "status": "failed"},
+		{"level": "DEBUG", "msg": "Node.RecognitionNode", "task_id": 1.0, "node_id": 9.0, "node": "B", "status": "failed"},
+		{"level": "DEBUG", "msg": "Node.WaitFreezes", "task_id": 1.0, "wf_id": 10.0, "node": "B", "phase": "post", "status": "succeeded"},
+	}, records())
+}
+
+func TestSlogSink_SkipsDisabledLevels(t *testing.T) {
+	sink, records := newTestSlogSink(t, WithSlogLevel(EventNodeRecognition, slog.LevelDebug-4))
+	sink.recognition = func(*Context, uint64) *RecognitionDetail {
+		t.Fatal("recognition detail fetched for a discarded record")
+		return nil
+	}
+
+	sink.OnNodeRecognition(nil, EventStatusSucceeded, NodeRecognitionDetail{TaskID: 1, RecognitionID: 7, Name: "A"})
+	require.Empty(t, records())
+}
+
+type slogTestAction struct{}
+
+func (a *slogTestAction) Run(ctx *Context, _ *CustomActionArg) bool {
+	ctx.Logger().Info("inside")
+	return false
+}
+
+type slogTestRecognition struct{}
+
+func (slogTestRecognition) Run(ctx *Context, _ *CustomRecognitionArg) (*CustomRecognitionResult, bool) {
+	return &CustomRecognitionResult{Box: Rect{5, 6, 7, 8}}, true
+}
+
+func TestSlogSink_WrapCustomRunners(t *testing.T) {
+	sink, records := newTestSlogSink(t)
+
+	ok := sink.WrapCustomAction(&slogTestAction{}).Run(&Context{}, &CustomActionArg{
+		TaskID:            3,
+		CurrentTaskName:   "Click",
+		CustomActionName:  "MyAction",
+		RecognitionDetail: &RecognitionDetail{ID: 11},
+		Box:               Rect{1, 1, 2, 2},
+	})
+	require.False(t, ok)
+	_, ok = sink.WrapCustomRecognition(slogTestRecognition{}).Run(&Context{}, &CustomRecognitionArg{
+		TaskID:                3,
+		CurrentTaskName:       "Find",
+		CustomRecognitionName: "MyReco",
+		Roi:                   Rect{0, 0, 10, 10},
+	})
+	require.True(t, ok)
+
+	action := map[string]any{"task_id": 3.0, "node": "Click", "name": "MyAction", "reco_id": 11.0, "box": []any{1.0, 1.0, 2.0, 2.0}}
+	reco := map[string]any{"task_id": 3.0, "node": "Find", "name": "MyReco", "roi": []any{0.0, 0.0, 10.0, 10.0}}
+	with := func(base map[string]any, kv ...any) map[string]any {
+		out := map[string]any{}
+		for k, v := range base {
+			out[k] = v
+		}
+		for i := 0; i < len(kv); i += 2 {
+			out[kv[i].(string)] = kv[i+1]
+		}
+		return out
+	}
+	require.Equal(t, []map[string]any{
+		with(action, "level", "DEBUG", "msg", "Custom.Action", "status", "starting"),
+		{"level": "INFO", "msg": "inside", "task_id": 3.0, "node": "Click"},
+		with(action, "level", "WARN", "msg", "Custom.Action", "status", "failed"),
+		with(reco, "level", "DEBUG", "msg", "Custom.Recognition", "status", "starting"),
+		with(reco, "level", "DEBUG", "msg", "Custom.Recognition", "status", "succeeded",
+			"hit", true, "box", []any{5.0, 6.0, 7.0, 8.0}),
+	}, records())
+}
+
+func TestContext_LoggerFallsBackToTasker(t *testing.T) {
+	handle := uintptr(0xC001)
+	store.TaskerStore.Lock()
+	store.TaskerStore.Set(handle, store.TaskerStoreValue{})
+	store.TaskerStore.Unlock()
+	t.Cleanup(func() {
+		store.TaskerStore.Lock()
+		store.TaskerStore.Del(handle)
+		store.TaskerStore.Unlock()
+	})
+
+	tasker := &Tasker{handle: handle}
+	ctx := &Context{tasker: tasker}
+	require.Same(t, slog.Default(), ctx.Logger())
+
+	logger := slog.New(slog.DiscardHandler)
+	tasker.SetLogger(logger)
+	require.Same(t, logger, ctx.Logger())
+}
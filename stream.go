@@ -0,0 +1,224 @@
+package maa
+
+import (
+	"context"
+	"errors"
+	"image"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrInvalidFPS = errors.New("fps must be positive")
+
+// Frame is a single screenshot produced by a FrameStream.
+type Frame struct {
+	// Seq is the 1-based sequence number of the captured frame.
+	// Gaps in Seq indicate frames dropped under backpressure.
+	Seq       uint64
+	Timestamp time.Time
+	Image     *image.RGBA
+
+	stream *FrameStream
+}
+
+// Release returns the frame buffer to the stream so it can be reused for later frames.
+// The frame must not be used after Release. Calling Release is optional; frames that
+// are never released are simply garbage collected.
+func (f *Frame) Release() {
+	if f == nil || f.stream == nil || f.Image == nil {
+		return
+	}
+	f.stream.putBuffer(f.Image)
+	f.Image = nil
+	f.stream = nil
+}
+
+// frameSource is the subset of *Controller used by FrameStream.
+type frameSource interface {
+	PostScreencap() *Job
+	CacheImageInto(dst *image.RGBA) (*image.RGBA, error)
+}
+
+const (
+	frameStreamPoolSize  = 3
+	frameStreamFPSWindow = 30
+)
+
+// FrameStream delivers screenshots from a controller at a bounded rate.
+type FrameStream struct {
+	frames chan *Frame
+	pool   chan *image.RGBA
+	done   chan struct{}
+
+	dropped  atomic.Uint64
+	captured atomic.Uint64
+
+	mu    sync.Mutex
+	meter fpsMeter
+	err   error
+}
+
+// Stream starts capturing screenshots at up to fps frames per second until ctx is done.
+//
+// Only one screencap is in flight at a time and the next one is posted no earlier
+// than 1/fps after the previous one, so tasks sharing the controller keep getting
+// their turn in the controller queue. When the consumer falls behind, the oldest
+// undelivered frame is dropped in favor of the newest one.
+func (c *Controller) Stream(ctx context.Context, fps float64) (*FrameStream, error) {
+	return newFrameStream(ctx, c, fps)
+}
+
+func newFrameStream(ctx context.Context, src frameSource, fps float64) (*FrameStream, error) {
+	if fps <= 0 {
+		return nil, ErrInvalidFPS
+	}
+	s := &FrameStream{
+		frames: make(chan *Frame, 1),
+		pool:   make(chan *image.RGBA, frameStreamPoolSize),
+		done:   make(chan struct{}),
+		meter:  fpsMeter{window: frameStreamFPSWindow},
+	}
+	go s.run(ctx, src, time.Duration(float64(time.Second)/fps))
+	return s, nil
+}
+
+// Frames returns the channel frames are delivered on.
+// It is closed once the stream stops.
+func (s *FrameStream) Frames() <-chan *Frame {
+	return s.frames
+}
+
+// Done returns a channel that is closed once the stream stops.
+func (s *FrameStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that stopped the stream, usually the context error.
+// It returns nil while the stream is running.
+func (s *FrameStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// FPS returns the achieved capture rate measured over recent frames.
+func (s *FrameStream) FPS() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.meter.rate()
+}
+
+// Captured returns the number of frames captured so far.
+func (s *FrameStream) Captured() uint64 {
+	return s.captured.Load()
+}
+
+// Dropped returns the number of frames dropped because the consumer fell behind.
+func (s *FrameStream) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *FrameStream) run(ctx context.Context, src frameSource, interval time.Duration) {
+	defer close(s.done)
+	defer close(s.frames)
+
+	var seq uint64
+	next := time.Now()
+	for {
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				s.stop(ctx.Err())
+				return
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			s.stop(err)
+			return
+		}
+		next = time.Now().Add(interval)
+
+		if !src.PostScreencap().Wait().Success() {
+			continue
+		}
+		img, err := src.CacheImageInto(s.getBuffer())
+		if err != nil || img == nil {
+			continue
+		}
+
+		seq++
+		now := time.Now()
+		s.captured.Add(1)
+		s.mu.Lock()
+		s.meter.observe(now)
+		s.mu.Unlock()
+
+		s.offer(&Frame{Seq: seq, Timestamp: now, Image: img, stream: s})
+	}
+}
+
+// offer delivers f without blocking, replacing the oldest undelivered frame if needed.
+func (s *FrameStream) offer(f *Frame) {
+	for {
+		select {
+		case s.frames <- f:
+			return
+		default:
+		}
+		select {
+		case old := <-s.frames:
+			s.dropped.Add(1)
+			old.Release()
+		default:
+		}
+	}
+}
+
+func (s *FrameStream) stop(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+func (s *FrameStream) getBuffer() *image.RGBA {
+	select {
+	case img := <-s.pool:
+		return img
+	default:
+		return nil
+	}
+}
+
+func (s *FrameStream) putBuffer(img *image.RGBA) {
+	select {
+	case s.pool <- img:
+	default:
+	}
+}
+
+// fpsMeter measures a rate over the last window observations.
+type fpsMeter struct {
+	window int
+	times  []time.Time
+}
+
+func (m *fpsMeter) observe(t time.Time) {
+	m.times = append(m.times, t)
+	if len(m.times) > m.window {
+		m.times = m.times[len(m.times)-m.window:]
+	}
+}
+
+func (m *fpsMeter) rate() float64 {
+	if len(m.times) < 2 {
+		return 0
+	}
+	elapsed := m.times[len(m.times)-1].Sub(m.times[0])
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(len(m.times)-1) / elapsed.Seconds()
+}
@@ -0,0 +1,95 @@
+package maa
+
+import (
+	"context"
+	"errors"
+	"image"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFrameSource struct {
+	screencaps atomic.Int64
+	allocs     atomic.Int64
+}
+
+func (f *fakeFrameSource) PostScreencap() *Job {
+	f.screencaps.Add(1)
+	done := func(int64) Status { return StatusSuccess }
+	return newJob(1, done, done)
+}
+
+func (f *fakeFrameSource) CacheImageInto(dst *image.RGBA) (*image.RGBA, error) {
+	if dst == nil {
+		f.allocs.Add(1)
+		dst = image.NewRGBA(image.Rect(0, 0, 8, 8))
+	}
+	return dst, nil
+}
+
+func TestNewFrameStream_InvalidFPS(t *testing.T) {
+	_, err := newFrameStream(context.Background(), &fakeFrameSource{}, 0)
+	require.ErrorIs(t, err, ErrInvalidFPS)
+}
+
+func TestFrameStream_DeliversAndStops(t *testing.T) {
+	src := &fakeFrameSource{}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := newFrameStream(ctx, src, 200)
+	require.NoError(t, err)
+
+	var lastSeq uint64
+	for i := 0; i < 5; i++ {
+		frame := <-stream.Frames()
+		require.NotNil(t, frame.Image)
+		require.Greater(t, frame.Seq, lastSeq)
+		lastSeq = frame.Seq
+		frame.Release()
+	}
+
+	cancel()
+	<-stream.Done()
+	for range stream.Frames() {
+	}
+	require.True(t, errors.Is(stream.Err(), context.Canceled))
+	require.Greater(t, stream.FPS(), 0.0)
+	require.LessOrEqual(t, src.allocs.Load(), int64(frameStreamPoolSize+2))
+}
+
+func TestFrameStream_DropsUnderBackpressure(t *testing.T) {
+	src := &fakeFrameSource{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := newFrameStream(ctx, src, 1000)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return stream.Dropped() > 0
+	}, time.Second, time.Millisecond)
+
+	frame := <-stream.Frames()
+	require.Greater(t, frame.Seq, uint64(1))
+
+	cancel()
+	<-stream.Done()
+	delivered := uint64(1)
+	for range stream.Frames() {
+		delivered++
+	}
+	require.Equal(t, stream.Captured(), stream.Dropped()+delivered)
+}
+
+func TestFpsMeter(t *testing.T) {
+	m := fpsMeter{window: 3}
+	require.Zero(t, m.rate())
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		m.observe(start.Add(time.Duration(i) * 100 * time.Millisecond))
+	}
+	require.Len(t, m.times, 3)
+	require.InDelta(t, 10.0, m.rate(), 0.001)
+}
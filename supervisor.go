@@ -0,0 +1,465 @@
+package maa
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"image"
+	"sync"
+	"time"
+)
+
+// SupervisorEventType identifies a lifecycle event emitted by a Supervisor.
+type SupervisorEventType int
+
+const (
+	SupervisorEventUnknown SupervisorEventType = iota
+	// SupervisorEventStarted is emitted once when Run begins supervising.
+	SupervisorEventStarted
+	// SupervisorEventHealthy is emitted when a probe succeeds after a failure or reconnect.
+	SupervisorEventHealthy
+	// SupervisorEventUnhealthy is emitted when a probe fails.
+	SupervisorEventUnhealthy
+	// SupervisorEventStaleFrame is emitted when the cached frame stopped changing.
+	SupervisorEventStaleFrame
+	// SupervisorEventReconnecting is emitted before each reconnect attempt.
+	SupervisorEventReconnecting
+	// SupervisorEventReconnected is emitted after a reconnect attempt succeeded.
+	SupervisorEventReconnected
+	// SupervisorEventReconnectFailed is emitted after a reconnect attempt failed.
+	SupervisorEventReconnectFailed
+	// SupervisorEventGaveUp is emitted when the maximum number of reconnect attempts is exhausted.
+	SupervisorEventGaveUp
+	// SupervisorEventStopped is emitted once when Run returns.
+	SupervisorEventStopped
+)
+
+// String returns the human-readable representation of the SupervisorEventType.
+func (t SupervisorEventType) String() string {
+	switch t {
+	case SupervisorEventStarted:
+		return "started"
+	case SupervisorEventHealthy:
+		return "healthy"
+	case SupervisorEventUnhealthy:
+		return "unhealthy"
+	case SupervisorEventStaleFrame:
+		return "stale_frame"
+	case SupervisorEventReconnecting:
+		return "reconnecting"
+	case SupervisorEventReconnected:
+		return "reconnected"
+	case SupervisorEventReconnectFailed:
+		return "reconnect_failed"
+	case SupervisorEventGaveUp:
+		return "gave_up"
+	case SupervisorEventStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// SupervisorEvent describes a single lifecycle event of a Supervisor.
+type SupervisorEvent struct {
+	Type SupervisorEventType
+	Time time.Time
+	// Attempt is the 1-based reconnect attempt number for reconnect events, otherwise 0.
+	Attempt int
+	// Err is the probe or reconnect error, if any.
+	Err error
+}
+
+// SupervisorEventSink receives lifecycle events from a Supervisor.
+// Events are delivered synchronously from the supervising goroutine.
+type SupervisorEventSink interface {
+	OnSupervisorEvent(sup *Supervisor, event SupervisorEvent)
+}
+
+// ControllerFactory builds a fresh controller. It is used by a Supervisor to
+// replace a controller that can no longer be recovered by PostConnect.
+type ControllerFactory func() (*Controller, error)
+
+var (
+	ErrSupervisorNilController = errors.New("supervisor controller is nil")
+	ErrSupervisorRunning       = errors.New("supervisor is already running")
+	ErrSupervisorGaveUp        = errors.New("supervisor gave up reconnecting")
+
+	errProbeScreencap    = errors.New("probe screencap failed")
+	errProbeDisconnected = errors.New("controller is not connected")
+	errProbeStaleFrame   = errors.New("controller frame is stale")
+)
+
+type supervisorConfig struct {
+	interval       time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxAttempts    int
+	staleThreshold int
+	probeUUID      bool
+	factory        ControllerFactory
+	tasker         *Tasker
+	sinks          []SupervisorEventSink
+}
+
+// SupervisorOption configures a Supervisor.
+type SupervisorOption func(*supervisorConfig)
+
+// WithSupervisorInterval sets the interval between health probes.
+// Defaults to 5 seconds.
+func WithSupervisorInterval(interval time.Duration) SupervisorOption {
+	return func(cfg *supervisorConfig) {
+		cfg.interval = interval
+	}
+}
+
+// WithSupervisorBackoff sets the initial and maximum delay between reconnect attempts.
+// The delay doubles after every failed attempt until it reaches max.
+// Defaults to 1 second and 1 minute.
+func WithSupervisorBackoff(initial, max time.Duration) SupervisorOption {
+	return func(cfg *supervisorConfig) {
+		cfg.initialBackoff = initial
+		cfg.maxBackoff = max
+	}
+}
+
+// WithSupervisorMaxAttempts limits the number of consecutive reconnect attempts.
+// Run returns ErrSupervisorGaveUp once the limit is exhausted. Zero means unlimited.
+func WithSupervisorMaxAttempts(n int) SupervisorOption {
+	return func(cfg *supervisorConfig) {
+		cfg.maxAttempts = n
+	}
+}
+
+// WithSupervisorStaleThreshold treats the controller as unhealthy once n consecutive
+// probes returned byte-identical frames. Zero disables stale frame detection, which
+// is the default since a static screen legitimately produces identical frames.
+func WithSupervisorStaleThreshold(n int) SupervisorOption {
+	return func(cfg *supervisorConfig) {
+		cfg.staleThreshold = n
+	}
+}
+
+// WithSupervisorProbeUUID sets whether probes also query the controller UUID.
+func WithSupervisorProbeUUID(enabled bool) SupervisorOption {
+	return func(cfg *supervisorConfig) {
+		cfg.probeUUID = enabled
+	}
+}
+
+// WithSupervisorFactory sets a factory used to rebuild the controller on reconnect
+// instead of calling PostConnect on the existing one.
+// Controllers created by the factory are owned by the Supervisor: a replaced one is
+// destroyed after its successor is connected and bound.
+func WithSupervisorFactory(factory ControllerFactory) SupervisorOption {
+	return func(cfg *supervisorConfig) {
+		cfg.factory = factory
+	}
+}
+
+// WithSupervisorTasker sets the tasker that a rebuilt controller is bound to.
+func WithSupervisorTasker(tasker *Tasker) SupervisorOption {
+	return func(cfg *supervisorConfig) {
+		cfg.tasker = tasker
+	}
+}
+
+// WithSupervisorSink adds a sink that receives lifecycle events.
+func WithSupervisorSink(sink SupervisorEventSink) SupervisorOption {
+	return func(cfg *supervisorConfig) {
+		if sink != nil {
+			cfg.sinks = append(cfg.sinks, sink)
+		}
+	}
+}
+
+// Supervisor watches a Controller with periodic health probes and reconnects it
+// with exponential backoff when it drops.
+type Supervisor struct {
+	cfg supervisorConfig
+
+	mu      sync.RWMutex
+	ctrl    *Controller
+	owned   bool
+	running bool
+
+	stale staleFrameDetector
+	frame *image.RGBA
+}
+
+// NewSupervisor creates a supervisor for ctrl.
+// The initial controller remains owned by the caller.
+func NewSupervisor(ctrl *Controller, opts ...SupervisorOption) (*Supervisor, error) {
+	if ctrl == nil {
+		return nil, ErrSupervisorNilController
+	}
+
+	cfg := supervisorConfig{
+		interval:       5 * time.Second,
+		initialBackoff: time.Second,
+		maxBackoff:     time.Minute,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if cfg.interval <= 0 {
+		cfg.interval = 5 * time.Second
+	}
+	if cfg.initialBackoff <= 0 {
+		cfg.initialBackoff = time.Second
+	}
+	if cfg.maxBackoff < cfg.initialBackoff {
+		cfg.maxBackoff = cfg.initialBackoff
+	}
+
+	return &Supervisor{
+		cfg:   cfg,
+		ctrl:  ctrl,
+		stale: staleFrameDetector{threshold: cfg.staleThreshold},
+	}, nil
+}
+
+// Controller returns the controller currently supervised.
+// It may change after a reconnect when a factory is configured.
+func (s *Supervisor) Controller() *Controller {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ctrl
+}
+
+// Run supervises the controller until ctx is done or reconnecting gives up.
+// It returns ctx.Err() when stopped by the context and ErrSupervisorGaveUp
+// when the reconnect attempts are exhausted.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return ErrSupervisorRunning
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	s.emit(SupervisorEvent{Type: SupervisorEventStarted})
+
+	err := s.loop(ctx)
+
+	s.emit(SupervisorEvent{Type: SupervisorEventStopped, Err: err})
+	return err
+}
+
+// Close destroys the controller currently owned by the supervisor, if any.
+// It must not be called while Run is in progress.
+func (s *Supervisor) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owned && s.ctrl != nil {
+		s.ctrl.Destroy()
+		s.ctrl = nil
+		s.owned = false
+	}
+}
+
+func (s *Supervisor) loop(ctx context.Context) error {
+	ticker := time.NewTicker(s.cfg.interval)
+	defer ticker.Stop()
+
+	healthy := true
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		err := s.probe()
+		if err == nil {
+			if !healthy {
+				s.emit(SupervisorEvent{Type: SupervisorEventHealthy})
+			}
+			healthy = true
+			continue
+		}
+
+		if errors.Is(err, errProbeStaleFrame) {
+			s.emit(SupervisorEvent{Type: SupervisorEventStaleFrame, Err: err})
+		} else {
+			s.emit(SupervisorEvent{Type: SupervisorEventUnhealthy, Err: err})
+		}
+		healthy = false
+
+		if err := s.reconnect(ctx); err != nil {
+			return err
+		}
+		s.emit(SupervisorEvent{Type: SupervisorEventHealthy})
+		healthy = true
+		ticker.Reset(s.cfg.interval)
+	}
+}
+
+func (s *Supervisor) probe() error {
+	ctrl := s.Controller()
+
+	if !ctrl.Connected() {
+		return errProbeDisconnected
+	}
+	if !ctrl.PostScreencap().Wait().Success() {
+		return errProbeScreencap
+	}
+	if s.cfg.probeUUID {
+		if _, err := ctrl.GetUUID(); err != nil {
+			return err
+		}
+	}
+	if s.cfg.staleThreshold > 0 {
+		frame, err := ctrl.CacheImageInto(s.frame)
+		if err != nil {
+			return err
+		}
+		s.frame = frame
+		if s.stale.observe(hashFrame(frame)) {
+			return errProbeStaleFrame
+		}
+	}
+	return nil
+}
+
+func (s *Supervisor) reconnect(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		if s.cfg.maxAttempts > 0 && attempt > s.cfg.maxAttempts {
+			s.emit(SupervisorEvent{Type: SupervisorEventGaveUp, Attempt: attempt - 1})
+			return ErrSupervisorGaveUp
+		}
+
+		s.emit(SupervisorEvent{Type: SupervisorEventReconnecting, Attempt: attempt})
+		err := s.reconnectOnce()
+		if err == nil {
+			s.stale.reset()
+			s.emit(SupervisorEvent{Type: SupervisorEventReconnected, Attempt: attempt})
+			return nil
+		}
+		s.emit(SupervisorEvent{Type: SupervisorEventReconnectFailed, Attempt: attempt, Err: err})
+
+		timer := time.NewTimer(supervisorBackoff(attempt, s.cfg.initialBackoff, s.cfg.maxBackoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Supervisor) reconnectOnce() error {
+	if s.cfg.factory == nil {
+		ctrl := s.Controller()
+		if !ctrl.PostConnect().Wait().Success() {
+			return errors.New("failed to reconnect controller")
+		}
+		return nil
+	}
+
+	ctrl, err := s.cfg.factory()
+	if err != nil {
+		return err
+	}
+	if ctrl == nil {
+		return ErrSupervisorNilController
+	}
+	if !ctrl.PostConnect().Wait().Success() {
+		ctrl.Destroy()
+		return errors.New("failed to connect rebuilt controller")
+	}
+	if s.cfg.tasker != nil {
+		if err := s.cfg.tasker.BindController(ctrl); err != nil {
+			ctrl.Destroy()
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	old, oldOwned := s.ctrl, s.owned
+	s.ctrl = ctrl
+	s.owned = true
+	s.frame = nil
+	s.mu.Unlock()
+
+	if oldOwned && old != nil {
+		old.Destroy()
+	}
+	return nil
+}
+
+func (s *Supervisor) emit(event SupervisorEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, sink := range s.cfg.sinks {
+		sink.OnSupervisorEvent(s, event)
+	}
+}
+
+// supervisorBackoff returns the delay after the given failed attempt (1-based).
+func supervisorBackoff(attempt int, initial, max time.Duration) time.Duration {
+	delay := initial
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max || delay <= 0 {
+			return max
+		}
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// hashFrame returns a cheap fingerprint of the frame pixels.
+func hashFrame(img *image.RGBA) uint64 {
+	if img == nil {
+		return 0
+	}
+	h := fnv.New64a()
+	width := img.Rect.Dx() * 4
+	for y := 0; y < img.Rect.Dy(); y++ {
+		offset := y * img.Stride
+		h.Write(img.Pix[offset : offset+width])
+	}
+	return h.Sum64()
+}
+
+// staleFrameDetector reports when the same frame hash has been observed
+// threshold times in a row.
+type staleFrameDetector struct {
+	threshold int
+	last      uint64
+	repeats   int
+	seen      bool
+}
+
+func (d *staleFrameDetector) observe(hash uint64) bool {
+	if d.threshold <= 0 {
+		return false
+	}
+	if d.seen && hash == d.last {
+		d.repeats++
+	} else {
+		d.last = hash
+		d.repeats = 1
+		d.seen = true
+	}
+	return d.repeats >= d.threshold
+}
+
+func (d *staleFrameDetector) reset() {
+	d.last = 0
+	d.repeats = 0
+	d.seen = false
+}
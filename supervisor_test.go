@@ -0,0 +1,84 @@
+package maa
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupervisorBackoff(t *testing.T) {
+	testCases := []struct {
+		name    string
+		attempt int
+		expect  time.Duration
+	}{
+		{name: "first attempt", attempt: 1, expect: time.Second},
+		{name: "second attempt", attempt: 2, expect: 2 * time.Second},
+		{name: "fourth attempt", attempt: 4, expect: 8 * time.Second},
+		{name: "capped", attempt: 10, expect: 30 * time.Second},
+		{name: "overflow", attempt: 200, expect: 30 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := supervisorBackoff(tc.attempt, time.Second, 30*time.Second)
+			require.Equal(t, tc.expect, got)
+		})
+	}
+}
+
+func TestHashFrame(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	b := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	require.Equal(t, hashFrame(a), hashFrame(b))
+
+	b.Set(1, 1, color.RGBA{R: 255, A: 255})
+	require.NotEqual(t, hashFrame(a), hashFrame(b))
+
+	// Sub-images must only hash their visible pixels.
+	sub := b.SubImage(image.Rect(2, 2, 4, 4)).(*image.RGBA)
+	other := a.SubImage(image.Rect(2, 2, 4, 4)).(*image.RGBA)
+	require.Equal(t, hashFrame(sub), hashFrame(other))
+
+	require.Zero(t, hashFrame(nil))
+}
+
+func TestStaleFrameDetector(t *testing.T) {
+	d := staleFrameDetector{threshold: 3}
+	require.False(t, d.observe(1))
+	require.False(t, d.observe(1))
+	require.True(t, d.observe(1))
+	require.False(t, d.observe(2))
+
+	d.reset()
+	require.False(t, d.observe(2))
+
+	disabled := staleFrameDetector{}
+	for i := 0; i < 10; i++ {
+		require.False(t, disabled.observe(1))
+	}
+}
+
+func TestNewSupervisor(t *testing.T) {
+	_, err := NewSupervisor(nil)
+	require.ErrorIs(t, err, ErrSupervisorNilController)
+
+	sup, err := NewSupervisor(
+		&Controller{},
+		WithSupervisorInterval(0),
+		WithSupervisorBackoff(2*time.Second, time.Second),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, sup.cfg.interval)
+	require.Equal(t, 2*time.Second, sup.cfg.initialBackoff)
+	require.Equal(t, 2*time.Second, sup.cfg.maxBackoff)
+}
+
+func TestSupervisorEventType_String(t *testing.T) {
+	require.Equal(t, "stale_frame", SupervisorEventStaleFrame.String())
+	require.Equal(t, "reconnected", SupervisorEventReconnected.String())
+	require.Equal(t, "unknown", SupervisorEventType(99).String())
+}
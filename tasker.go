@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"log/slog"
 	"unsafe"
 
 	"github.com/MaaXYZ/maa-framework-go/v4/internal/buffer"
@@ -43,14 +44,17 @@ func NewTasker() (*Tasker, error) {
 func (t *Tasker) Destroy() {
 	store.TaskerStore.Lock()
 	value := store.TaskerStore.Get(t.handle)
+	store.TaskerStore.Del(t.handle)
+	store.TaskerStore.Unlock()
+
+	// Unregistering waits for the async sink handlers still running, so it
+	// happens before the native tasker is freed and without the store lock.
 	for _, id := range value.SinkIDToEventCallbackID {
 		unregisterEventCallback(id)
 	}
 	for _, id := range value.ContextSinkIDToEventCallbackID {
 		unregisterEventCallback(id)
 	}
-	store.TaskerStore.Del(t.handle)
-	store.TaskerStore.Unlock()
 
 	native.MaaTaskerDestroy(t.handle)
 }
@@ -597,8 +601,9 @@ func (t *Tasker) GetWaitFreezesDetail(wfId int64) (*WaitFreezesDetail, error) {
 }
 
 // AddSink adds an event listener and returns the sink ID for later removal.
-func (t *Tasker) AddSink(sink TaskerEventSink) int64 {
-	id := registerEventCallback(sink)
+// By default the sink is called on the framework thread; see WithSinkAsync.
+func (t *Tasker) AddSink(sink TaskerEventSink, opts ...SinkOption) int64 {
+	id := registerEventCallback(sink, opts...)
 	sinkId := native.MaaTaskerAddSink(
 		t.handle,
 		_MaaEventCallbackAgent,
@@ -614,29 +619,35 @@ func (t *Tasker) AddSink(sink TaskerEventSink) int64 {
 
 // RemoveSink removes an event listener by sink ID.
 func (t *Tasker) RemoveSink(sinkId int64) {
+	var id uint64
 	store.TaskerStore.Update(t.handle, func(v *store.TaskerStoreValue) {
-		unregisterEventCallback(v.SinkIDToEventCallbackID[sinkId])
+		id = v.SinkIDToEventCallbackID[sinkId]
 		delete(v.SinkIDToEventCallbackID, sinkId)
 	})
+	// Unregistering waits for the async handler, so it happens without the store lock.
+	unregisterEventCallback(id)
 
 	native.MaaTaskerRemoveSink(t.handle, sinkId)
 }
 
 // ClearSinks clears all instance event listeners.
 func (t *Tasker) ClearSinks() {
+	var ids map[int64]uint64
 	store.TaskerStore.Update(t.handle, func(v *store.TaskerStoreValue) {
-		for _, id := range v.SinkIDToEventCallbackID {
-			unregisterEventCallback(id)
-		}
+		ids = v.SinkIDToEventCallbackID
 		v.SinkIDToEventCallbackID = make(map[int64]uint64)
 	})
+	for _, id := range ids {
+		unregisterEventCallback(id)
+	}
 
 	native.MaaTaskerClearSinks(t.handle)
 }
 
 // AddContextSink adds a context event listener and returns the sink ID for later removal.
-func (t *Tasker) AddContextSink(sink ContextEventSink) int64 {
-	id := registerEventCallback(sink)
+// By default the sink is called on the framework thread; see WithSinkAsync.
+func (t *Tasker) AddContextSink(sink ContextEventSink, opts ...SinkOption) int64 {
+	id := registerEventCallback(sink, opts...)
 	sinkId := native.MaaTaskerAddContextSink(
 		t.handle,
 		_MaaEventCallbackAgent,
@@ -652,26 +663,62 @@ func (t *Tasker) AddContextSink(sink ContextEventSink) int64 {
 
 // RemoveContextSink removes a context event listener by sink ID.
 func (t *Tasker) RemoveContextSink(sinkId int64) {
+	var id uint64
 	store.TaskerStore.Update(t.handle, func(v *store.TaskerStoreValue) {
-		unregisterEventCallback(v.ContextSinkIDToEventCallbackID[sinkId])
+		id = v.ContextSinkIDToEventCallbackID[sinkId]
 		delete(v.ContextSinkIDToEventCallbackID, sinkId)
 	})
+	unregisterEventCallback(id)
 
 	native.MaaTaskerRemoveContextSink(t.handle, sinkId)
 }
 
 // ClearContextSinks clears all context event listeners.
 func (t *Tasker) ClearContextSinks() {
+	var ids map[int64]uint64
 	store.TaskerStore.Update(t.handle, func(v *store.TaskerStoreValue) {
-		for _, id := range v.ContextSinkIDToEventCallbackID {
-			unregisterEventCallback(id)
-		}
+		ids = v.ContextSinkIDToEventCallbackID
 		v.ContextSinkIDToEventCallbackID = make(map[int64]uint64)
 	})
+	for _, id := range ids {
+		unregisterEventCallback(id)
+	}
 
 	native.MaaTaskerClearContextSinks(t.handle)
 }
 
+// SinkDropped returns the number of events the async sink sinkId dropped
+// because its queue was full.
+func (t *Tasker) SinkDropped(sinkId int64) uint64 {
+	store.TaskerStore.Lock()
+	id := store.TaskerStore.Get(t.handle).SinkIDToEventCallbackID[sinkId]
+	store.TaskerStore.Unlock()
+	return sinkDropped(id)
+}
+
+// ContextSinkDropped returns the number of events the async context sink
+// sinkId dropped because its queue was full.
+func (t *Tasker) ContextSinkDropped(sinkId int64) uint64 {
+	store.TaskerStore.Lock()
+	id := store.TaskerStore.Get(t.handle).ContextSinkIDToEventCallbackID[sinkId]
+	store.TaskerStore.Unlock()
+	return sinkDropped(id)
+}
+
+// SetLogger sets the logger returned by Context.Logger for contexts of this tasker.
+func (t *Tasker) SetLogger(logger *slog.Logger) {
+	store.TaskerStore.Update(t.handle, func(v *store.TaskerStoreValue) {
+		v.Logger = logger
+	})
+}
+
+// Logger returns the logger set with SetLogger, or nil.
+func (t *Tasker) Logger() *slog.Logger {
+	store.TaskerStore.Lock()
+	defer store.TaskerStore.Unlock()
+	return store.TaskerStore.Get(t.handle).Logger
+}
+
 // TaskerEventSink is the interface for receiving tasker-level events.
 type TaskerEventSink interface {
 	OnTaskerTask(tasker *Tasker, event EventStatus, detail TaskerTaskDetail)
@@ -706,6 +753,12 @@ type ContextEventSink interface {
 	OnNodeAction(ctx *Context, event EventStatus, detail NodeActionDetail)
 }
 
+// NodeWaitFreezesEventSink is implemented by a ContextEventSink that also wants
+// Node.WaitFreezes events. It is optional so existing sinks keep compiling.
+type NodeWaitFreezesEventSink interface {
+	OnNodeWaitFreezes(ctx *Context, event EventStatus, detail NodeWaitFreezesDetail)
+}
+
 // contextEventSinkAdapter is a lightweight adapter that makes it easy to register
 // a single-event handler via a callback function.
 type contextEventSinkAdapter struct {
@@ -715,6 +768,7 @@ type contextEventSinkAdapter struct {
 	onNodeNextList        func(*Context, EventStatus, NodeNextListDetail)
 	onNodeRecognition     func(*Context, EventStatus, NodeRecognitionDetail)
 	onNodeAction          func(*Context, EventStatus, NodeActionDetail)
+	onNodeWaitFreezes     func(*Context, EventStatus, NodeWaitFreezesDetail)
 }
 
 // OnNodePipelineNode implements ContextEventSink by forwarding
@@ -771,6 +825,15 @@ func (a *contextEventSinkAdapter) OnNodeAction(ctx *Context, status EventStatus,
 	a.onNodeAction(ctx, status, detail)
 }
 
+// OnNodeWaitFreezes implements NodeWaitFreezesEventSink by forwarding
+// Node.WaitFreezes events to the registered callback, if any.
+func (a *contextEventSinkAdapter) OnNodeWaitFreezes(ctx *Context, status EventStatus, detail NodeWaitFreezesDetail) {
+	if a == nil || a.onNodeWaitFreezes == nil {
+		return
+	}
+	a.onNodeWaitFreezes(ctx, status, detail)
+}
+
 // OnNodePipelineNodeInContext registers a callback for Node.PipelineNode events and returns the sink ID.
 func (t *Tasker) OnNodePipelineNodeInContext(fn func(*Context, EventStatus, NodePipelineNodeDetail)) int64 {
 	sink := &contextEventSinkAdapter{onNodePipelineNode: fn}
@@ -806,3 +869,9 @@ func (t *Tasker) OnNodeActionInContext(fn func(*Context, EventStatus, NodeAction
 	sink := &contextEventSinkAdapter{onNodeAction: fn}
 	return t.AddContextSink(sink)
 }
+
+// OnNodeWaitFreezesInContext registers a callback for Node.WaitFreezes events and returns the sink ID.
+func (t *Tasker) OnNodeWaitFreezesInContext(fn func(*Context, EventStatus, NodeWaitFreezesDetail)) int64 {
+	sink := &contextEventSinkAdapter{onNodeWaitFreezes: fn}
+	return t.AddContextSink(sink)
+}
@@ -0,0 +1,363 @@
+package maa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrTaskerGroupEmpty         = errors.New("tasker group has no members")
+	ErrTaskerGroupNoResource    = errors.New("tasker group member has no resource")
+	ErrTaskerGroupNoController  = errors.New("tasker group member has no controller")
+	ErrTaskerGroupDuplicateName = errors.New("duplicate tasker group member name")
+	ErrTaskerGroupUnknownMember = errors.New("unknown tasker group member")
+	ErrTaskerGroupRunning       = errors.New("tasker group is already running")
+	ErrTaskerGroupStopped       = errors.New("tasker group was stopped")
+)
+
+// TaskerGroupMember describes one device of a TaskerGroup.
+type TaskerGroupMember struct {
+	// Name identifies the member in overrides and results. It must be unique.
+	Name       string
+	Controller *Controller
+	// Resource is bound to the member instead of the shared group resource.
+	Resource *Resource
+}
+
+type taskerGroupConfig struct {
+	resource    *Resource
+	concurrency int
+}
+
+// TaskerGroupOption configures a TaskerGroup.
+type TaskerGroupOption func(*taskerGroupConfig)
+
+// WithTaskerGroupResource sets the resource shared by every member that has no
+// resource of its own.
+func WithTaskerGroupResource(res *Resource) TaskerGroupOption {
+	return func(cfg *taskerGroupConfig) {
+		cfg.resource = res
+	}
+}
+
+// WithTaskerGroupConcurrency limits how many members run a task at the same time.
+// Zero, the default, runs every member at once.
+func WithTaskerGroupConcurrency(n int) TaskerGroupOption {
+	return func(cfg *taskerGroupConfig) {
+		cfg.concurrency = n
+	}
+}
+
+// TaskerGroupTask is a task broadcast to the members of a TaskerGroup.
+type TaskerGroupTask struct {
+	Entry string
+	// Override is the pipeline override used by every member, as accepted by Tasker.PostTask.
+	Override any
+	// Overrides replaces Override for the members it names.
+	Overrides map[string]any
+}
+
+// TaskerGroupMemberResult is the outcome of a TaskerGroupTask on one member.
+type TaskerGroupMemberResult struct {
+	Name   string
+	Status Status
+	// Detail is nil when the task was never posted or its detail could not be read.
+	Detail *TaskDetail
+	// Err is set when the task could not be posted or was skipped because the
+	// group was stopped before the member got a slot.
+	Err error
+}
+
+// TaskerGroupResult aggregates the outcome of a TaskerGroupTask on every member,
+// in member order.
+type TaskerGroupResult struct {
+	Entry   string
+	Members []TaskerGroupMemberResult
+}
+
+// Success reports whether the task succeeded on every member.
+func (r *TaskerGroupResult) Success() bool {
+	for _, m := range r.Members {
+		if !m.Status.Success() {
+			return false
+		}
+	}
+	return true
+}
+
+// Failed returns the results of the members the task did not succeed on.
+func (r *TaskerGroupResult) Failed() []TaskerGroupMemberResult {
+	var failed []TaskerGroupMemberResult
+	for _, m := range r.Members {
+		if !m.Status.Success() {
+			failed = append(failed, m)
+		}
+	}
+	return failed
+}
+
+// Err returns an error describing every member the task did not succeed on, or nil.
+func (r *TaskerGroupResult) Err() error {
+	var errs []error
+	for _, m := range r.Failed() {
+		if m.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.Name, m.Err))
+		} else {
+			errs = append(errs, fmt.Errorf("%s: task %s", m.Name, m.Status))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type taskerGroupMember struct {
+	name   string
+	ctrl   *Controller
+	tasker *Tasker
+}
+
+// TaskerGroup runs the same entry on many devices. Each member has its own
+// Tasker bound to its controller and either its own or the shared resource.
+type TaskerGroup struct {
+	cfg     taskerGroupConfig
+	members []*taskerGroupMember
+
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc
+}
+
+// NewTaskerGroup creates a tasker for every member and binds it to the member
+// controller and resource.
+// The group owns the taskers and the member controllers: Destroy releases both.
+// Resources remain owned by the caller.
+func NewTaskerGroup(members []TaskerGroupMember, opts ...TaskerGroupOption) (*TaskerGroup, error) {
+	if len(members) == 0 {
+		return nil, ErrTaskerGroupEmpty
+	}
+
+	var cfg taskerGroupConfig
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	names := make(map[string]bool, len(members))
+	for _, m := range members {
+		switch {
+		case names[m.Name]:
+			return nil, fmt.Errorf("%w: %q", ErrTaskerGroupDuplicateName, m.Name)
+		case m.Controller == nil:
+			return nil, fmt.Errorf("%w: %q", ErrTaskerGroupNoController, m.Name)
+		case m.Resource == nil && cfg.resource == nil:
+			return nil, fmt.Errorf("%w: %q", ErrTaskerGroupNoResource, m.Name)
+		}
+		names[m.Name] = true
+	}
+
+	g := &TaskerGroup{cfg: cfg}
+	for _, m := range members {
+		res := m.Resource
+		if res == nil {
+			res = cfg.resource
+		}
+		tasker, err := newGroupTasker(res, m.Controller)
+		if err != nil {
+			for _, created := range g.members {
+				created.tasker.Destroy()
+			}
+			return nil, fmt.Errorf("failed to create tasker for %q: %w", m.Name, err)
+		}
+		g.members = append(g.members, &taskerGroupMember{name: m.Name, ctrl: m.Controller, tasker: tasker})
+	}
+	return g, nil
+}
+
+func newGroupTasker(res *Resource, ctrl *Controller) (*Tasker, error) {
+	tasker, err := NewTasker()
+	if err != nil {
+		return nil, err
+	}
+	if err := tasker.BindResource(res); err != nil {
+		tasker.Destroy()
+		return nil, err
+	}
+	if err := tasker.BindController(ctrl); err != nil {
+		tasker.Destroy()
+		return nil, err
+	}
+	return tasker, nil
+}
+
+// Names returns the member names in member order.
+func (g *TaskerGroup) Names() []string {
+	names := make([]string, len(g.members))
+	for i, m := range g.members {
+		names[i] = m.name
+	}
+	return names
+}
+
+// Tasker returns the tasker of the named member, or nil.
+// It can be used to add sinks or register custom components.
+func (g *TaskerGroup) Tasker(name string) *Tasker {
+	if m := g.member(name); m != nil {
+		return m.tasker
+	}
+	return nil
+}
+
+// Controller returns the controller of the named member, or nil.
+func (g *TaskerGroup) Controller(name string) *Controller {
+	if m := g.member(name); m != nil {
+		return m.ctrl
+	}
+	return nil
+}
+
+func (g *TaskerGroup) member(name string) *taskerGroupMember {
+	for _, m := range g.members {
+		if m.name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Run posts task to every member, at most the configured concurrency at a time,
+// waits for all of them and returns the aggregated result.
+//
+// When ctx is done or Stop is called, every member is stopped with PostStop and
+// the members still waiting for a slot are skipped with the cause as Err.
+// The returned error is only set when the task could not be started at all.
+func (g *TaskerGroup) Run(ctx context.Context, task TaskerGroupTask) (*TaskerGroupResult, error) {
+	for name := range task.Overrides {
+		if g.member(name) == nil {
+			return nil, fmt.Errorf("%w: %q", ErrTaskerGroupUnknownMember, name)
+		}
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	g.mu.Lock()
+	if g.cancel != nil {
+		g.mu.Unlock()
+		return nil, ErrTaskerGroupRunning
+	}
+	g.cancel = cancel
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		g.cancel = nil
+		g.mu.Unlock()
+	}()
+
+	stopped := make(chan struct{})
+	stopAfter := context.AfterFunc(runCtx, func() {
+		defer close(stopped)
+		g.stopAll()
+	})
+	defer func() {
+		if !stopAfter() {
+			<-stopped
+		}
+	}()
+
+	limit := g.cfg.concurrency
+	if limit <= 0 || limit > len(g.members) {
+		limit = len(g.members)
+	}
+	slots := make(chan struct{}, limit)
+
+	result := &TaskerGroupResult{
+		Entry:   task.Entry,
+		Members: make([]TaskerGroupMemberResult, len(g.members)),
+	}
+	var wg sync.WaitGroup
+	for i, m := range g.members {
+		override := task.Override
+		if o, ok := task.Overrides[m.name]; ok {
+			override = o
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result.Members[i] = g.runMember(runCtx, slots, m, task.Entry, override)
+		}()
+	}
+	wg.Wait()
+	return result, nil
+}
+
+func (g *TaskerGroup) runMember(
+	ctx context.Context,
+	slots chan struct{},
+	m *taskerGroupMember,
+	entry string,
+	override any,
+) TaskerGroupMemberResult {
+	result := TaskerGroupMemberResult{Name: m.name}
+
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		result.Err = context.Cause(ctx)
+		return result
+	}
+	// select picks randomly when both cases are ready, so a stopped group
+	// could still get a slot here.
+	if ctx.Err() != nil {
+		result.Err = context.Cause(ctx)
+		return result
+	}
+
+	job := m.tasker.PostTask(entry, override).Wait()
+	result.Status = job.Status()
+	if err := job.Error(); err != nil {
+		result.Err = err
+		return result
+	}
+	if detail, err := job.GetDetail(); err == nil {
+		result.Detail = detail
+	}
+	return result
+}
+
+// Stop stops a running Run: members that are running are stopped with PostStop
+// and members that have not started yet are skipped with ErrTaskerGroupStopped.
+// It does nothing when the group is not running.
+func (g *TaskerGroup) Stop() {
+	g.mu.Lock()
+	cancel := g.cancel
+	g.mu.Unlock()
+	if cancel != nil {
+		cancel(ErrTaskerGroupStopped)
+	}
+}
+
+func (g *TaskerGroup) stopAll() {
+	var wg sync.WaitGroup
+	for _, m := range g.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.tasker.PostStop().Wait()
+		}()
+	}
+	wg.Wait()
+}
+
+// Destroy destroys the member taskers and controllers.
+// It must not be called while Run is in progress.
+func (g *TaskerGroup) Destroy() {
+	for _, m := range g.members {
+		m.tasker.Destroy()
+		if m.ctrl != nil {
+			m.ctrl.Destroy()
+		}
+	}
+	g.members = nil
+}
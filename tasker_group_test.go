@@ -0,0 +1,199 @@
+package maa
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeGroupMembers returns members with connected blank controllers on fake,
+// and a resource loading the entries "Daily" and "Loop" for them to share.
+func newFakeGroupMembers(t *testing.T, fake *maafake.Backend, names []string) ([]TaskerGroupMember, *Resource) {
+	t.Helper()
+	require.NoError(t, fake.AddBundle("group", map[string]any{"Daily": map[string]any{}, "Loop": map[string]any{}}))
+	res, err := NewResource()
+	require.NoError(t, err)
+	t.Cleanup(res.Destroy)
+	require.True(t, res.PostBundle("group").Wait().Success())
+
+	members := make([]TaskerGroupMember, len(names))
+	for i, name := range names {
+		ctrl, err := NewBlankController()
+		require.NoError(t, err)
+		require.True(t, ctrl.PostConnect().Wait().Success())
+		members[i] = TaskerGroupMember{Name: name, Controller: ctrl}
+	}
+	return members, res
+}
+
+func newFakeTaskerGroup(t *testing.T, fake *maafake.Backend, names []string, opts ...TaskerGroupOption) *TaskerGroup {
+	t.Helper()
+	members, res := newFakeGroupMembers(t, fake, names)
+	g, err := NewTaskerGroup(members, append(opts, WithTaskerGroupResource(res))...)
+	require.NoError(t, err)
+	t.Cleanup(g.Destroy)
+	return g
+}
+
+func TestTaskerGroup_Run(t *testing.T) {
+	fake := useFakeBackend(t)
+	var (
+		mu      sync.Mutex
+		running int
+		peak    int
+		tags    = make(map[int64]string)
+	)
+	fake.RunTask = func(task *maafake.Task) bool {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+
+		var node struct {
+			Tag  string `json:"tag"`
+			Fail bool   `json:"fail"`
+		}
+		data, _ := task.NodeJSON(task.Entry)
+		require.NoError(t, json.Unmarshal([]byte(data), &node))
+		mu.Lock()
+		running--
+		tags[task.ID] = node.Tag
+		mu.Unlock()
+		return task.RunNode(task.DefaultNode(task.Entry)) && !node.Fail
+	}
+	g := newFakeTaskerGroup(t, fake, []string{"emu-0", "emu-1", "emu-2", "emu-3"}, WithTaskerGroupConcurrency(2))
+	require.Equal(t, []string{"emu-0", "emu-1", "emu-2", "emu-3"}, g.Names())
+
+	result, err := g.Run(context.Background(), TaskerGroupTask{
+		Entry:    "Daily",
+		Override: map[string]any{"Daily": map[string]any{"tag": "shared"}},
+		Overrides: map[string]any{
+			"emu-1": map[string]any{"Daily": map[string]any{"tag": "own"}},
+			"emu-2": map[string]any{"Daily": map[string]any{"tag": "shared", "fail": true}},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 2, peak)
+	require.Equal(t, "Daily", result.Entry)
+	require.Len(t, result.Members, 4)
+	for i, m := range result.Members {
+		require.Equal(t, g.Names()[i], m.Name)
+		require.NotNil(t, m.Detail)
+		require.Equal(t, "Daily", m.Detail.Entry)
+		want := "shared"
+		if m.Name == "emu-1" {
+			want = "own"
+		}
+		require.Equal(t, want, tags[m.Detail.ID], m.Name)
+	}
+	require.False(t, result.Success())
+	require.Len(t, result.Failed(), 1)
+	require.Equal(t, "emu-2", result.Failed()[0].Name)
+	require.EqualError(t, result.Err(), "emu-2: task failure")
+}
+
+func TestTaskerGroup_StopSkipsWaitingMembers(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		stop  func(g *TaskerGroup, cancel context.CancelFunc)
+		cause error
+	}{
+		{"Stop", func(g *TaskerGroup, _ context.CancelFunc) { g.Stop() }, ErrTaskerGroupStopped},
+		{"Context", func(_ *TaskerGroup, cancel context.CancelFunc) { cancel() }, context.Canceled},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := useFakeBackend(t)
+			started := make(chan int64, 3)
+			var posted atomic.Int32
+			fake.RunTask = func(task *maafake.Task) bool {
+				posted.Add(1)
+				started <- task.ID
+				for !task.Stopping() {
+					time.Sleep(time.Millisecond)
+				}
+				return false
+			}
+			g := newFakeTaskerGroup(t, fake, []string{"a", "b", "c"}, WithTaskerGroupConcurrency(1))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var result atomic.Pointer[TaskerGroupResult]
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				r, err := g.Run(ctx, TaskerGroupTask{Entry: "Loop"})
+				require.NoError(t, err)
+				result.Store(r)
+			}()
+
+			first := <-started
+			_, err := g.Run(ctx, TaskerGroupTask{Entry: "Loop"})
+			require.ErrorIs(t, err, ErrTaskerGroupRunning)
+
+			tc.stop(g, cancel)
+			<-done
+
+			var skipped int
+			for _, m := range result.Load().Members {
+				if m.Detail != nil {
+					require.Equal(t, first, m.Detail.ID)
+					require.Equal(t, StatusFailure, m.Status)
+					continue
+				}
+				skipped++
+				require.ErrorIs(t, m.Err, tc.cause)
+			}
+			require.Equal(t, 2, skipped)
+			require.EqualValues(t, 1, posted.Load())
+
+			g.Stop()
+		})
+	}
+}
+
+func TestNewTaskerGroup_Errors(t *testing.T) {
+	ctrl := &Controller{}
+
+	_, err := NewTaskerGroup(nil)
+	require.ErrorIs(t, err, ErrTaskerGroupEmpty)
+
+	_, err = NewTaskerGroup([]TaskerGroupMember{{Name: "a", Controller: ctrl}})
+	require.ErrorIs(t, err, ErrTaskerGroupNoResource)
+
+	_, err = NewTaskerGroup([]TaskerGroupMember{{Name: "a", Resource: &Resource{}}})
+	require.ErrorIs(t, err, ErrTaskerGroupNoController)
+
+	_, err = NewTaskerGroup([]TaskerGroupMember{
+		{Name: "a", Controller: ctrl},
+		{Name: "a", Controller: ctrl},
+	}, WithTaskerGroupResource(&Resource{}))
+	require.ErrorIs(t, err, ErrTaskerGroupDuplicateName)
+
+	g := newFakeTaskerGroup(t, useFakeBackend(t), []string{"a"})
+	_, err = g.Run(context.Background(), TaskerGroupTask{Overrides: map[string]any{"b": nil}})
+	require.ErrorIs(t, err, ErrTaskerGroupUnknownMember)
+}
+
+func TestNewTaskerGroup_CreateFailureReleasesTaskers(t *testing.T) {
+	fake := useFakeBackend(t)
+	members, res := newFakeGroupMembers(t, fake, []string{"a", "b", "c"})
+	for _, m := range members[:2] {
+		t.Cleanup(m.Controller.Destroy)
+	}
+	// The tasker of c cannot bind a destroyed controller.
+	members[2].Controller.Destroy()
+	objects := fake.Objects()
+
+	g, err := NewTaskerGroup(members, WithTaskerGroupResource(res))
+	require.ErrorContains(t, err, `failed to create tasker for "c"`)
+	require.Nil(t, g)
+	require.Equal(t, objects, fake.Objects(), "the taskers of a and b are destroyed")
+}
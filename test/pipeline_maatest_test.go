@@ -0,0 +1,19 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/maatest"
+)
+
+func TestPipelineSmokingTable(t *testing.T) {
+	maatest.Run(t, []maatest.Case{
+		{
+			Name:       "Wilderness",
+			Controller: maatest.Replay("./data_set/PipelineSmoking/MaaRecording.jsonl"),
+			Bundles:    []string{"./data_set/PipelineSmoking/resource"},
+			Entry:      "Wilderness",
+			Hit:        []string{"Wilderness"},
+		},
+	})
+}
@@ -18,6 +18,12 @@ type AdbDevice struct {
 	Config          string
 }
 
+// AdbConfig parses the device config into a typed adb.Config.
+// The result can be adjusted and passed to NewAdbControllerWithConfig.
+func (d *AdbDevice) AdbConfig() (*adb.Config, error) {
+	return adb.ParseConfig(d.Config)
+}
+
 // DesktopWindow represents a single desktop window with various properties about its information.
 type DesktopWindow struct {
 	Handle     unsafe.Pointer
@@ -0,0 +1,541 @@
+package maa
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// TraceNodeKind identifies which context event opened a TraceNode.
+type TraceNodeKind string
+
+const (
+	// TraceNodePipeline is a node run by the pipeline (Node.PipelineNode).
+	TraceNodePipeline TraceNodeKind = "pipeline"
+	// TraceNodeRecognition is a recognition-only node, e.g. from Context.RunRecognition (Node.RecognitionNode).
+	TraceNodeRecognition TraceNodeKind = "recognition"
+	// TraceNodeAction is an action-only node, e.g. from Context.RunAction (Node.ActionNode).
+	TraceNodeAction TraceNodeKind = "action"
+)
+
+// TraceTask is the trace of a single task.
+type TraceTask struct {
+	TaskID uint64 `json:"task_id"`
+	Entry  string `json:"entry,omitempty"`
+	UUID   string `json:"uuid,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+	// Status is the EventStatus string of the last event seen for the task.
+	Status string    `json:"status"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end,omitzero"`
+
+	Nodes []*TraceNode `json:"nodes"`
+}
+
+// TraceNode is the trace of a node run.
+type TraceNode struct {
+	Kind   TraceNodeKind `json:"kind"`
+	NodeID uint64        `json:"node_id,omitempty"`
+	Name   string        `json:"name"`
+	Status string        `json:"status"`
+	Start  time.Time     `json:"start"`
+	End    time.Time     `json:"end,omitzero"`
+
+	// NextLists are the next-list evaluations of the node, in order.
+	NextLists []*TraceNextList `json:"next_lists,omitempty"`
+	// Recognitions are recognitions run outside a next-list, as for TraceNodeRecognition.
+	Recognitions []*TraceRecognition `json:"recognitions,omitempty"`
+	Action       *TraceAction        `json:"action,omitempty"`
+}
+
+// TraceNextList is the trace of a next-list evaluation.
+type TraceNextList struct {
+	Name   string     `json:"name"`
+	List   []NextItem `json:"list"`
+	Status string     `json:"status"`
+	Start  time.Time  `json:"start"`
+	End    time.Time  `json:"end,omitzero"`
+
+	// Recognitions are the candidates tried, in order.
+	Recognitions []*TraceRecognition `json:"recognitions,omitempty"`
+}
+
+// TraceRecognition is the trace of a recognition.
+// Algorithm, Hit, Box and Results are filled from Tasker.GetRecognitionDetail when the task ends.
+type TraceRecognition struct {
+	RecoID uint64    `json:"reco_id"`
+	Name   string    `json:"name"`
+	Status string    `json:"status"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end,omitzero"`
+
+	Algorithm string          `json:"algorithm,omitempty"`
+	Hit       bool            `json:"hit"`
+	Box       *Rect           `json:"box,omitempty"`
+	Results   json.RawMessage `json:"results,omitempty"`
+	// Detail is the full recognition detail. Raw and Draws are only kept with WithTraceImages.
+	Detail *RecognitionDetail `json:"-"`
+
+	// SubTasks are tasks run by a custom recognition through its Context.
+	SubTasks []*TraceTask `json:"sub_tasks,omitempty"`
+}
+
+// TraceAction is the trace of an action.
+// Action, Box, Success and Result are filled from Tasker.GetActionDetail when the task ends.
+type TraceAction struct {
+	ActionID uint64    `json:"action_id"`
+	Name     string    `json:"name"`
+	Status   string    `json:"status"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end,omitzero"`
+
+	Action  string          `json:"action,omitempty"`
+	Box     *Rect           `json:"box,omitempty"`
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Detail  *ActionDetail   `json:"-"`
+
+	// SubTasks are tasks run by a custom action through its Context.
+	SubTasks []*TraceTask `json:"sub_tasks,omitempty"`
+}
+
+// Duration returns the time between the first and the last event of the task,
+// or 0 if it has not ended.
+func (t *TraceTask) Duration() time.Duration { return traceDuration(t.Start, t.End) }
+
+// Duration returns the time between the first and the last event of the node.
+func (n *TraceNode) Duration() time.Duration { return traceDuration(n.Start, n.End) }
+
+// Duration returns the time between the first and the last event of the next-list.
+func (l *TraceNextList) Duration() time.Duration { return traceDuration(l.Start, l.End) }
+
+// Duration returns the time between the first and the last event of the recognition.
+func (r *TraceRecognition) Duration() time.Duration { return traceDuration(r.Start, r.End) }
+
+// Duration returns the time between the first and the last event of the action.
+func (a *TraceAction) Duration() time.Duration { return traceDuration(a.Start, a.End) }
+
+func traceDuration(start, end time.Time) time.Duration {
+	if end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+type traceConfig struct {
+	details bool
+	images  bool
+	onDone  func(*TraceTask)
+}
+
+// TraceOption configures a TraceCollector.
+type TraceOption func(*traceConfig)
+
+// WithTraceDetails sets whether recognitions and actions are completed with
+// Tasker.GetRecognitionDetail and Tasker.GetActionDetail when a task ends.
+// Defaults to true.
+func WithTraceDetails(enabled bool) TraceOption {
+	return func(cfg *traceConfig) {
+		cfg.details = enabled
+	}
+}
+
+// WithTraceImages keeps the Raw and Draws images of recognition details.
+// They are dropped by default to bound memory use.
+func WithTraceImages(enabled bool) TraceOption {
+	return func(cfg *traceConfig) {
+		cfg.images = enabled
+	}
+}
+
+// WithTraceTaskDone sets a callback invoked with the completed trace of every
+// task, after the details have been filled.
+func WithTraceTaskDone(fn func(*TraceTask)) TraceOption {
+	return func(cfg *traceConfig) {
+		cfg.onDone = fn
+	}
+}
+
+// TraceCollector records the events of a tasker and assembles them into a tree
+// per task: task → nodes → next-lists → recognitions → actions.
+// It implements both TaskerEventSink and ContextEventSink; use Attach to register it.
+//
+// Tasks run through a Context from a custom recognition or action are nested
+// under that recognition or action.
+type TraceCollector struct {
+	cfg traceConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	open     []*traceTaskState
+	finished []*TraceTask
+}
+
+type traceTaskState struct {
+	task *TraceTask
+	// sub is set for tasks nested under a recognition or action.
+	sub    bool
+	node   *TraceNode
+	next   *TraceNextList
+	recos  map[uint64]*TraceRecognition
+	reco   *TraceRecognition
+	action *TraceAction
+}
+
+// NewTraceCollector creates an empty trace collector.
+func NewTraceCollector(opts ...TraceOption) *TraceCollector {
+	cfg := traceConfig{details: true}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	return &TraceCollector{
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+// Attach registers the collector on tasker as both a tasker sink and a context sink
+// and returns the two sink IDs.
+func (c *TraceCollector) Attach(tasker *Tasker) (sinkID, contextSinkID int64) {
+	return tasker.AddSink(c), tasker.AddContextSink(c)
+}
+
+// Tasks returns the traces of the tasks that have ended, oldest first.
+// They are not modified afterwards.
+func (c *TraceCollector) Tasks() []*TraceTask {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*TraceTask(nil), c.finished...)
+}
+
+// Task returns the trace of an ended task.
+func (c *TraceCollector) Task(taskID uint64) (*TraceTask, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, task := range c.finished {
+		if task.TaskID == taskID {
+			return task, true
+		}
+	}
+	return nil, false
+}
+
+// Reset drops every recorded task.
+func (c *TraceCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.open = nil
+	c.finished = nil
+}
+
+// MarshalJSON encodes the ended tasks followed by the running ones as {"tasks": [...]}.
+func (c *TraceCollector) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tasks := make([]*TraceTask, 0, len(c.finished)+len(c.open))
+	tasks = append(tasks, c.finished...)
+	for _, state := range c.open {
+		if !state.sub {
+			tasks = append(tasks, state.task)
+		}
+	}
+	return json.Marshal(struct {
+		Tasks []*TraceTask `json:"tasks"`
+	}{tasks})
+}
+
+// WriteJSON writes the indented JSON encoding of the collector to w.
+func (c *TraceCollector) WriteJSON(w io.Writer) error {
+	data, err := c.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var indented any
+	if err := json.Unmarshal(data, &indented); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(indented)
+}
+
+// OnTaskerTask implements TaskerEventSink.
+func (c *TraceCollector) OnTaskerTask(tasker *Tasker, status EventStatus, detail TaskerTaskDetail) {
+	now := c.now()
+
+	c.mu.Lock()
+	state := c.state(detail.TaskID, now, false)
+	task := state.task
+	task.Entry = detail.Entry
+	task.UUID = detail.UUID
+	task.Hash = detail.Hash
+	task.Status = status.String()
+	if status == EventStatusStarting {
+		c.mu.Unlock()
+		return
+	}
+	task.End = now
+	c.closeTask(state)
+	c.mu.Unlock()
+
+	// Details are queried outside the lock; the task is no longer reachable from events.
+	if c.cfg.details && tasker != nil {
+		c.fillDetails(tasker, task)
+	}
+
+	c.mu.Lock()
+	c.finished = append(c.finished, task)
+	c.mu.Unlock()
+
+	if c.cfg.onDone != nil {
+		c.cfg.onDone(task)
+	}
+}
+
+// OnNodePipelineNode implements ContextEventSink.
+func (c *TraceCollector) OnNodePipelineNode(ctx *Context, status EventStatus, detail NodePipelineNodeDetail) {
+	c.onNode(TraceNodePipeline, detail.TaskID, detail.NodeID, detail.Name, status)
+}
+
+// OnNodeRecognitionNode implements ContextEventSink.
+func (c *TraceCollector) OnNodeRecognitionNode(ctx *Context, status EventStatus, detail NodeRecognitionNodeDetail) {
+	c.onNode(TraceNodeRecognition, detail.TaskID, detail.NodeID, detail.Name, status)
+}
+
+// OnNodeActionNode implements ContextEventSink.
+func (c *TraceCollector) OnNodeActionNode(ctx *Context, status EventStatus, detail NodeActionNodeDetail) {
+	c.onNode(TraceNodeAction, detail.TaskID, detail.NodeID, detail.Name, status)
+}
+
+// OnNodeNextList implements ContextEventSink.
+func (c *TraceCollector) OnNodeNextList(ctx *Context, status EventStatus, detail NodeNextListDetail) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.state(detail.TaskID, now, true)
+	if status == EventStatusStarting || state.next == nil {
+		next := &TraceNextList{
+			Name:  detail.Name,
+			List:  append([]NextItem(nil), detail.List...),
+			Start: now,
+		}
+		node := state.nodeFor(detail.Name, now)
+		node.NextLists = append(node.NextLists, next)
+		state.next = next
+	}
+	state.next.Status = status.String()
+	if status != EventStatusStarting {
+		state.next.End = now
+		state.next = nil
+	}
+}
+
+// OnNodeRecognition implements ContextEventSink.
+func (c *TraceCollector) OnNodeRecognition(ctx *Context, status EventStatus, detail NodeRecognitionDetail) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.state(detail.TaskID, now, true)
+	reco := state.recos[detail.RecognitionID]
+	if reco == nil && detail.RecognitionID != 0 && state.reco != nil && state.reco.RecoID == 0 && state.reco.Name == detail.Name {
+		// The Starting event did not carry the id yet.
+		reco = state.reco
+		reco.RecoID = detail.RecognitionID
+		state.recos[detail.RecognitionID] = reco
+	}
+	if reco == nil {
+		reco = &TraceRecognition{RecoID: detail.RecognitionID, Name: detail.Name, Start: now}
+		if state.next != nil {
+			state.next.Recognitions = append(state.next.Recognitions, reco)
+		} else {
+			node := state.nodeFor(detail.Name, now)
+			node.Recognitions = append(node.Recognitions, reco)
+		}
+		state.recos[detail.RecognitionID] = reco
+	}
+	reco.Status = status.String()
+	if status == EventStatusStarting {
+		state.reco = reco
+		return
+	}
+	reco.End = now
+	delete(state.recos, detail.RecognitionID)
+	if state.reco == reco {
+		state.reco = nil
+	}
+}
+
+// OnNodeAction implements ContextEventSink.
+func (c *TraceCollector) OnNodeAction(ctx *Context, status EventStatus, detail NodeActionDetail) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.state(detail.TaskID, now, true)
+	action := state.action
+	if action == nil || status == EventStatusStarting {
+		action = &TraceAction{ActionID: detail.ActionID, Name: detail.Name, Start: now}
+		state.nodeFor(detail.Name, now).Action = action
+		state.action = action
+	}
+	if action.ActionID == 0 {
+		action.ActionID = detail.ActionID
+	}
+	action.Status = status.String()
+	if status != EventStatusStarting {
+		action.End = now
+		state.action = nil
+	}
+}
+
+func (c *TraceCollector) onNode(kind TraceNodeKind, taskID, nodeID uint64, name string, status EventStatus) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.state(taskID, now, true)
+	node := state.node
+	if status == EventStatusStarting || node == nil || node.Name != name || !node.End.IsZero() {
+		node = &TraceNode{Kind: kind, Name: name, Start: now}
+		state.task.Nodes = append(state.task.Nodes, node)
+		state.node = node
+		state.next = nil
+		state.action = nil
+	}
+	if nodeID != 0 {
+		node.NodeID = nodeID
+	}
+	node.Status = status.String()
+	if status != EventStatusStarting {
+		node.End = now
+		if state.sub {
+			state.task.Status = node.Status
+			state.task.End = now
+		}
+	}
+}
+
+// nodeFor returns the open node, creating an implicit one for events that
+// arrive without a node event, e.g. when the collector was attached mid-node.
+func (s *traceTaskState) nodeFor(name string, now time.Time) *TraceNode {
+	if s.node == nil || !s.node.End.IsZero() {
+		s.node = &TraceNode{Kind: TraceNodePipeline, Name: name, Start: now, Status: EventStatusStarting.String()}
+		s.task.Nodes = append(s.task.Nodes, s.node)
+	}
+	return s.node
+}
+
+// state returns the open state of taskID, creating it if needed.
+// A task first seen through a context event while another task is running is a
+// sub-task and is nested under the recognition or action that is running.
+// Must be called with c.mu held.
+func (c *TraceCollector) state(taskID uint64, now time.Time, fromContext bool) *traceTaskState {
+	for _, state := range c.open {
+		if state.task.TaskID == taskID {
+			return state
+		}
+	}
+
+	task := &TraceTask{TaskID: taskID, Status: EventStatusStarting.String(), Start: now}
+	state := &traceTaskState{task: task, recos: make(map[uint64]*TraceRecognition)}
+
+	var parent *traceTaskState
+	if fromContext {
+		for i := len(c.open) - 1; i >= 0; i-- {
+			if c.open[i].action != nil || c.open[i].reco != nil {
+				parent = c.open[i]
+				break
+			}
+		}
+	}
+	switch {
+	case parent == nil:
+	case parent.action != nil:
+		state.sub = true
+		parent.action.SubTasks = append(parent.action.SubTasks, task)
+	default:
+		state.sub = true
+		parent.reco.SubTasks = append(parent.reco.SubTasks, task)
+	}
+	c.open = append(c.open, state)
+	return state
+}
+
+// closeTask removes state and the sub-tasks opened after it from the open list.
+// Must be called with c.mu held.
+func (c *TraceCollector) closeTask(state *traceTaskState) {
+	for i, s := range c.open {
+		if s == state {
+			end := i + 1
+			for end < len(c.open) && c.open[end].sub {
+				end++
+			}
+			c.open = append(c.open[:i], c.open[end:]...)
+			return
+		}
+	}
+}
+
+func (c *TraceCollector) fillDetails(tasker *Tasker, task *TraceTask) {
+	for _, node := range task.Nodes {
+		for _, next := range node.NextLists {
+			for _, reco := range next.Recognitions {
+				c.fillRecognition(tasker, reco)
+			}
+		}
+		for _, reco := range node.Recognitions {
+			c.fillRecognition(tasker, reco)
+		}
+		if node.Action != nil {
+			c.fillAction(tasker, node.Action)
+		}
+	}
+}
+
+func (c *TraceCollector) fillRecognition(tasker *Tasker, reco *TraceRecognition) {
+	if reco.RecoID != 0 {
+		if detail, err := tasker.GetRecognitionDetail(int64(reco.RecoID)); err == nil && detail != nil {
+			if !c.cfg.images {
+				detail.Raw = nil
+				detail.Draws = nil
+			}
+			box := detail.Box
+			reco.Algorithm = detail.Algorithm
+			reco.Hit = detail.Hit
+			reco.Box = &box
+			reco.Results = rawJSONOrNil(detail.DetailJson)
+			reco.Detail = detail
+		}
+	}
+	for _, sub := range reco.SubTasks {
+		c.fillDetails(tasker, sub)
+	}
+}
+
+func (c *TraceCollector) fillAction(tasker *Tasker, action *TraceAction) {
+	if action.ActionID != 0 {
+		if detail, err := tasker.GetActionDetail(int64(action.ActionID)); err == nil && detail != nil {
+			box := detail.Box
+			action.Action = detail.Action
+			action.Box = &box
+			action.Success = detail.Success
+			action.Result = rawJSONOrNil(detail.DetailJson)
+			action.Detail = detail
+		}
+	}
+	for _, sub := range action.SubTasks {
+		c.fillDetails(tasker, sub)
+	}
+}
+
+func rawJSONOrNil(s string) json.RawMessage {
+	if s == "" || !json.Valid([]byte(s)) {
+		return nil
+	}
+	return json.RawMessage(s)
+}
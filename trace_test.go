@@ -0,0 +1,158 @@
+package maa
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/MaaXYZ/maa-framework-go/v4/maafake"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTraceCollector(opts ...TraceOption) *TraceCollector {
+	c := NewTraceCollector(opts...)
+	clock := time.Unix(0, 0)
+	c.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	return c
+}
+
+func TestTraceCollector_Tree(t *testing.T) {
+	var done *TraceTask
+	c := newTestTraceCollector(WithTraceDetails(false), WithTraceTaskDone(func(task *TraceTask) { done = task }))
+	tasker := &Tasker{}
+
+	c.OnTaskerTask(tasker, EventStatusStarting, TaskerTaskDetail{TaskID: 1, Entry: "Start"})
+	c.OnNodePipelineNode(nil, EventStatusStarting, NodePipelineNodeDetail{TaskID: 1, Name: "Start"})
+	c.OnNodeNextList(nil, EventStatusStarting, NodeNextListDetail{TaskID: 1, Name: "Start", List: []NextItem{{Name: "A"}, {Name: "B"}}})
+	c.OnNodeRecognition(nil, EventStatusStarting, NodeRecognitionDetail{TaskID: 1, RecognitionID: 11, Name: "A"})
+	c.OnNodeRecognition(nil, EventStatusFailed, NodeRecognitionDetail{TaskID: 1, RecognitionID: 11, Name: "A"})
+	c.OnNodeRecognition(nil, EventStatusStarting, NodeRecognitionDetail{TaskID: 1, RecognitionID: 12, Name: "B"})
+	c.OnNodeRecognition(nil, EventStatusSucceeded, NodeRecognitionDetail{TaskID: 1, RecognitionID: 12, Name: "B"})
+	c.OnNodeNextList(nil, EventStatusSucceeded, NodeNextListDetail{TaskID: 1, Name: "Start"})
+	c.OnNodeAction(nil, EventStatusStarting, NodeActionDetail{TaskID: 1, ActionID: 21, Name: "B"})
+
+	// A custom action runs a sub-task through its context.
+	c.OnNodePipelineNode(nil, EventStatusStarting, NodePipelineNodeDetail{TaskID: 2, Name: "Sub"})
+	c.OnNodePipelineNode(nil, EventStatusSucceeded, NodePipelineNodeDetail{TaskID: 2, NodeID: 31, Name: "Sub"})
+
+	c.OnNodeAction(nil, EventStatusSucceeded, NodeActionDetail{TaskID: 1, ActionID: 21, Name: "B"})
+	c.OnNodePipelineNode(nil, EventStatusSucceeded, NodePipelineNodeDetail{TaskID: 1, NodeID: 41, Name: "Start"})
+	require.Empty(t, c.Tasks())
+	c.OnTaskerTask(tasker, EventStatusSucceeded, TaskerTaskDetail{TaskID: 1, Entry: "Start"})
+
+	tasks := c.Tasks()
+	require.Len(t, tasks, 1)
+	task := tasks[0]
+	require.Same(t, task, done)
+	require.Equal(t, "succeeded", task.Status)
+	require.Equal(t, 13*time.Millisecond, task.Duration())
+
+	require.Len(t, task.Nodes, 1)
+	node := task.Nodes[0]
+	require.Equal(t, TraceNodePipeline, node.Kind)
+	require.EqualValues(t, 41, node.NodeID)
+	require.Len(t, node.NextLists, 1)
+
+	recos := node.NextLists[0].Recognitions
+	require.Len(t, recos, 2)
+	require.Equal(t, "failed", recos[0].Status)
+	require.Equal(t, "succeeded", recos[1].Status)
+	require.EqualValues(t, 12, recos[1].RecoID)
+	require.Equal(t, time.Millisecond, recos[1].Duration())
+
+	action := node.Action
+	require.NotNil(t, action)
+	require.EqualValues(t, 21, action.ActionID)
+	require.Equal(t, "succeeded", action.Status)
+	require.Len(t, action.SubTasks, 1)
+	require.Equal(t, "Sub", action.SubTasks[0].Nodes[0].Name)
+	require.Equal(t, "succeeded", action.SubTasks[0].Status)
+
+	data, err := json.Marshal(c)
+	require.NoError(t, err)
+	var decoded struct {
+		Tasks []struct {
+			TaskID uint64 `json:"task_id"`
+			Nodes  []struct {
+				NextLists []struct {
+					Recognitions []struct {
+						RecoID uint64 `json:"reco_id"`
+					} `json:"recognitions"`
+				} `json:"next_lists"`
+				Action struct {
+					SubTasks []json.RawMessage `json:"sub_tasks"`
+				} `json:"action"`
+			} `json:"nodes"`
+		} `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Len(t, decoded.Tasks, 1)
+	require.EqualValues(t, 12, decoded.Tasks[0].Nodes[0].NextLists[0].Recognitions[1].RecoID)
+	require.Len(t, decoded.Tasks[0].Nodes[0].Action.SubTasks, 1)
+}
+
+func TestTraceCollector_RunningTaskIsSerialized(t *testing.T) {
+	c := newTestTraceCollector(WithTraceDetails(false))
+	c.OnNodeRecognitionNode(nil, EventStatusStarting, NodeRecognitionNodeDetail{TaskID: 5, Name: "Direct"})
+	c.OnNodeRecognition(nil, EventStatusStarting, NodeRecognitionDetail{TaskID: 5, Name: "Direct"})
+	c.OnNodeRecognition(nil, EventStatusSucceeded, NodeRecognitionDetail{TaskID: 5, RecognitionID: 9, Name: "Direct"})
+
+	data, err := json.Marshal(c)
+	require.NoError(t, err)
+	var decoded struct {
+		Tasks []*TraceTask `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Len(t, decoded.Tasks, 1)
+	node := decoded.Tasks[0].Nodes[0]
+	require.Equal(t, TraceNodeRecognition, node.Kind)
+	require.Len(t, node.Recognitions, 1)
+	require.EqualValues(t, 9, node.Recognitions[0].RecoID)
+	require.Equal(t, "succeeded", node.Recognitions[0].Status)
+
+	c.Reset()
+	data, err = json.Marshal(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"tasks":[]}`, string(data))
+}
+
+func TestTraceCollector_FillsDetails(t *testing.T) {
+	fake := useFakeBackend(t)
+	fake.RunTask = func(task *maafake.Task) bool {
+		task.RunNode(maafake.Node{Name: "A", Recognition: "OCR", Miss: true})
+		return task.RunNode(maafake.Node{Name: "B", Recognition: "TemplateMatch", Box: Rect{1, 2, 3, 4}, Detail: `{"best":null}`, Action: "Click"})
+	}
+	tasker := newFakeTasker(t, fake, map[string]any{"Start": map[string]any{}})
+
+	c := NewTraceCollector()
+	c.Attach(tasker)
+	require.True(t, tasker.PostTask("Start").Wait().Success())
+
+	tasks := c.Tasks()
+	require.Len(t, tasks, 1)
+	task := tasks[0]
+	var recos []*TraceRecognition
+	var action *TraceAction
+	for _, node := range task.Nodes {
+		recos = append(recos, node.Recognitions...)
+		if node.Action != nil {
+			action = node.Action
+		}
+	}
+	require.Len(t, recos, 2)
+	require.Equal(t, "OCR", recos[0].Algorithm)
+	require.False(t, recos[0].Hit)
+	require.Equal(t, "TemplateMatch", recos[1].Algorithm)
+	require.True(t, recos[1].Hit)
+	require.Equal(t, &Rect{1, 2, 3, 4}, recos[1].Box)
+	require.JSONEq(t, `{"best":null}`, string(recos[1].Results))
+	require.Nil(t, recos[1].Detail.Raw, "images are dropped by default")
+
+	require.NotNil(t, action)
+	require.Equal(t, "Click", action.Action)
+	require.True(t, action.Success)
+	require.Equal(t, &Rect{1, 2, 3, 4}, action.Box)
+}
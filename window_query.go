@@ -0,0 +1,232 @@
+package maa
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+var (
+	ErrWindowNotFound             = errors.New("no desktop window matches the query")
+	ErrProcessNameResolverMissing = errors.New("process name query requires a process name resolver")
+)
+
+// WindowQuery describes which desktop windows to select.
+// Zero-valued fields match every window; all set fields must match.
+type WindowQuery struct {
+	// Title matches DesktopWindow.WindowName.
+	Title *regexp.Regexp
+	// Class matches DesktopWindow.ClassName.
+	Class *regexp.Regexp
+	// ProcessName matches the name of the owning process, case-insensitively.
+	// It requires a resolver set with WithProcessNameResolver.
+	ProcessName string
+	// Handle matches DesktopWindow.Handle exactly.
+	Handle unsafe.Pointer
+}
+
+// DesktopWindowEnumerator lists desktop windows.
+// It allows window queries to be driven by something other than FindDesktopWindows.
+type DesktopWindowEnumerator interface {
+	EnumDesktopWindows() ([]*DesktopWindow, error)
+}
+
+// DesktopWindowEnumeratorFunc adapts a function to DesktopWindowEnumerator.
+type DesktopWindowEnumeratorFunc func() ([]*DesktopWindow, error)
+
+// EnumDesktopWindows implements DesktopWindowEnumerator.
+func (f DesktopWindowEnumeratorFunc) EnumDesktopWindows() ([]*DesktopWindow, error) {
+	return f()
+}
+
+// ProcessNameResolver returns the name of the process owning a window, e.g. "game.exe".
+type ProcessNameResolver func(w *DesktopWindow) (string, error)
+
+type windowQueryConfig struct {
+	enumerator   DesktopWindowEnumerator
+	resolver     ProcessNameResolver
+	pollInterval time.Duration
+}
+
+// WindowQueryOption configures QueryDesktopWindows and WaitForWindow.
+type WindowQueryOption func(*windowQueryConfig)
+
+// WithWindowEnumerator sets the enumerator used to list windows.
+// Defaults to FindDesktopWindows.
+func WithWindowEnumerator(enumerator DesktopWindowEnumerator) WindowQueryOption {
+	return func(cfg *windowQueryConfig) {
+		cfg.enumerator = enumerator
+	}
+}
+
+// WithProcessNameResolver sets the resolver used for WindowQuery.ProcessName.
+func WithProcessNameResolver(resolver ProcessNameResolver) WindowQueryOption {
+	return func(cfg *windowQueryConfig) {
+		cfg.resolver = resolver
+	}
+}
+
+// WithWindowPollInterval sets how often WaitForWindow enumerates windows.
+// Defaults to 500 milliseconds.
+func WithWindowPollInterval(interval time.Duration) WindowQueryOption {
+	return func(cfg *windowQueryConfig) {
+		cfg.pollInterval = interval
+	}
+}
+
+func newWindowQueryConfig(opts []WindowQueryOption) windowQueryConfig {
+	cfg := windowQueryConfig{pollInterval: 500 * time.Millisecond}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if cfg.enumerator == nil {
+		cfg.enumerator = DesktopWindowEnumeratorFunc(FindDesktopWindows)
+	}
+	if cfg.pollInterval <= 0 {
+		cfg.pollInterval = 500 * time.Millisecond
+	}
+	return cfg
+}
+
+// QueryDesktopWindows returns the windows matching query, best match first.
+//
+// Matches are ranked by how precisely they fit the query: a title or class pattern
+// matching the whole string beats a partial match, and windows with a non-empty
+// title come first. Ties are broken by title, class name and handle so the order
+// is deterministic.
+func QueryDesktopWindows(query WindowQuery, opts ...WindowQueryOption) ([]*DesktopWindow, error) {
+	cfg := newWindowQueryConfig(opts)
+	return queryDesktopWindows(query, cfg)
+}
+
+// FindDesktopWindow returns the best window matching query, or ErrWindowNotFound.
+func FindDesktopWindow(query WindowQuery, opts ...WindowQueryOption) (*DesktopWindow, error) {
+	matches, err := QueryDesktopWindows(query, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, ErrWindowNotFound
+	}
+	return matches[0], nil
+}
+
+// WaitForWindow polls until a window matches query and returns the best match.
+// It returns ctx.Err() if ctx is done first; use context.WithTimeout to bound the wait.
+func WaitForWindow(ctx context.Context, query WindowQuery, opts ...WindowQueryOption) (*DesktopWindow, error) {
+	cfg := newWindowQueryConfig(opts)
+
+	ticker := time.NewTicker(cfg.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		matches, err := queryDesktopWindows(query, cfg)
+		if errors.Is(err, ErrProcessNameResolverMissing) {
+			return nil, err
+		}
+		if len(matches) > 0 {
+			return matches[0], nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Match reports whether w satisfies query. resolver may be nil when query.ProcessName is empty.
+func (q WindowQuery) Match(w *DesktopWindow, resolver ProcessNameResolver) (bool, error) {
+	if w == nil {
+		return false, nil
+	}
+	if q.Handle != nil && w.Handle != q.Handle {
+		return false, nil
+	}
+	if q.Title != nil && !q.Title.MatchString(w.WindowName) {
+		return false, nil
+	}
+	if q.Class != nil && !q.Class.MatchString(w.ClassName) {
+		return false, nil
+	}
+	if q.ProcessName != "" {
+		if resolver == nil {
+			return false, ErrProcessNameResolverMissing
+		}
+		name, err := resolver(w)
+		if err != nil {
+			return false, nil
+		}
+		if !strings.EqualFold(name, q.ProcessName) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func queryDesktopWindows(query WindowQuery, cfg windowQueryConfig) ([]*DesktopWindow, error) {
+	windows, err := cfg.enumerator.EnumDesktopWindows()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*DesktopWindow
+	for _, w := range windows {
+		ok, err := query.Match(w, cfg.resolver)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, w)
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b *DesktopWindow) int {
+		if sa, sb := query.rank(a), query.rank(b); sa != sb {
+			return sb - sa
+		}
+		if c := strings.Compare(a.WindowName, b.WindowName); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.ClassName, b.ClassName); c != 0 {
+			return c
+		}
+		ha, hb := uintptr(a.Handle), uintptr(b.Handle)
+		switch {
+		case ha < hb:
+			return -1
+		case ha > hb:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return matches, nil
+}
+
+// rank scores how precisely a matching window fits the query. Higher is better.
+func (q WindowQuery) rank(w *DesktopWindow) int {
+	score := 0
+	if q.Title != nil && fullMatch(q.Title, w.WindowName) {
+		score += 4
+	}
+	if q.Class != nil && fullMatch(q.Class, w.ClassName) {
+		score += 2
+	}
+	if w.WindowName != "" {
+		score++
+	}
+	return score
+}
+
+func fullMatch(re *regexp.Regexp, s string) bool {
+	loc := re.FindStringIndex(s)
+	return loc != nil && loc[0] == 0 && loc[1] == len(s)
+}
@@ -0,0 +1,155 @@
+package maa
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+var fakeWindowHandles [4]byte
+
+func fakeWindow(i int, class, title string) *DesktopWindow {
+	return &DesktopWindow{
+		Handle:     unsafe.Pointer(&fakeWindowHandles[i]),
+		ClassName:  class,
+		WindowName: title,
+	}
+}
+
+func staticWindows(windows ...*DesktopWindow) WindowQueryOption {
+	return WithWindowEnumerator(DesktopWindowEnumeratorFunc(func() ([]*DesktopWindow, error) {
+		return windows, nil
+	}))
+}
+
+func windowTitles(windows []*DesktopWindow) []string {
+	titles := make([]string, len(windows))
+	for i, w := range windows {
+		titles[i] = w.WindowName
+	}
+	return titles
+}
+
+func TestQueryDesktopWindows(t *testing.T) {
+	windows := []*DesktopWindow{
+		fakeWindow(0, "UnityWndClass", "Game - Launcher"),
+		fakeWindow(1, "UnityWndClass", "Game"),
+		fakeWindow(2, "Chrome_WidgetWin_1", "Game wiki"),
+		fakeWindow(3, "UnityWndClass", ""),
+	}
+
+	testCases := []struct {
+		name   string
+		query  WindowQuery
+		expect []string
+	}{
+		{
+			name:   "title ranks full match first",
+			query:  WindowQuery{Title: regexp.MustCompile(`Game`)},
+			expect: []string{"Game", "Game - Launcher", "Game wiki"},
+		},
+		{
+			name:   "class",
+			query:  WindowQuery{Class: regexp.MustCompile(`^UnityWndClass$`)},
+			expect: []string{"Game", "Game - Launcher", ""},
+		},
+		{
+			name: "title and class",
+			query: WindowQuery{
+				Title: regexp.MustCompile(`wiki`),
+				Class: regexp.MustCompile(`Chrome`),
+			},
+			expect: []string{"Game wiki"},
+		},
+		{
+			name:   "handle",
+			query:  WindowQuery{Handle: windows[3].Handle},
+			expect: []string{""},
+		},
+		{
+			name:   "no match",
+			query:  WindowQuery{Title: regexp.MustCompile(`Notepad`)},
+			expect: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := QueryDesktopWindows(tc.query, staticWindows(windows...))
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, windowTitles(got))
+		})
+	}
+}
+
+func TestFindDesktopWindow(t *testing.T) {
+	windows := []*DesktopWindow{
+		fakeWindow(0, "Main", "Game - Launcher"),
+		fakeWindow(1, "Main", "Game"),
+	}
+
+	w, err := FindDesktopWindow(WindowQuery{Title: regexp.MustCompile(`Game`)}, staticWindows(windows...))
+	require.NoError(t, err)
+	require.Same(t, windows[1], w)
+
+	_, err = FindDesktopWindow(WindowQuery{Class: regexp.MustCompile(`Other`)}, staticWindows(windows...))
+	require.ErrorIs(t, err, ErrWindowNotFound)
+}
+
+func TestQueryDesktopWindows_ProcessName(t *testing.T) {
+	windows := []*DesktopWindow{
+		fakeWindow(0, "A", "first"),
+		fakeWindow(1, "B", "second"),
+	}
+	query := WindowQuery{ProcessName: "GAME.exe"}
+
+	_, err := QueryDesktopWindows(query, staticWindows(windows...))
+	require.ErrorIs(t, err, ErrProcessNameResolverMissing)
+
+	resolver := func(w *DesktopWindow) (string, error) {
+		if w.ClassName == "B" {
+			return "game.exe", nil
+		}
+		return "", errors.New("access denied")
+	}
+	got, err := QueryDesktopWindows(query, staticWindows(windows...), WithProcessNameResolver(resolver))
+	require.NoError(t, err)
+	require.Equal(t, []string{"second"}, windowTitles(got))
+}
+
+func TestWaitForWindow(t *testing.T) {
+	var polls atomic.Int32
+	enumerator := DesktopWindowEnumeratorFunc(func() ([]*DesktopWindow, error) {
+		if polls.Add(1) < 3 {
+			return nil, nil
+		}
+		return []*DesktopWindow{fakeWindow(0, "Main", "Game")}, nil
+	})
+
+	w, err := WaitForWindow(context.Background(),
+		WindowQuery{Title: regexp.MustCompile(`^Game$`)},
+		WithWindowEnumerator(enumerator),
+		WithWindowPollInterval(time.Millisecond),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "Game", w.WindowName)
+	require.EqualValues(t, 3, polls.Load())
+}
+
+func TestWaitForWindow_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := WaitForWindow(ctx,
+		WindowQuery{Title: regexp.MustCompile(`Game`)},
+		staticWindows(),
+		WithWindowPollInterval(time.Millisecond),
+	)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}